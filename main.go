@@ -18,18 +18,9 @@ import (
 )
 
 // shutdownTimeout bounds how long in-flight requests get to finish once a
-// shutdown signal arrives, before the server is forced closed.
-//
-// This is best-effort for an open /wait SSE connection specifically: webapp.Wait only
-// returns early on its own request context being canceled (the client disconnecting) or
-// on a session becoming available at its SessionWaitCheckIntervalSeconds ticker -- it
-// does not watch this shutdown deadline itself, and Echo's graceful Shutdown does not
-// cancel in-flight request contexts, only stop accepting new ones and wait for existing
-// ones to finish. So if SessionWaitCheckIntervalSeconds is configured larger than
-// shutdownTimeout, a currently-waiting client can keep its connection open past
-// shutdownTimeout (Shutdown then just returns a logged error while the process exits
-// anyway, which closes the socket at the OS level). Keeping
-// SessionWaitCheckIntervalSeconds well under this value avoids relying on that.
+// shutdown signal arrives, before the server is forced closed. Open /wait and /clock
+// SSE streams don't count against it: Shutdown ends them as it begins (see
+// webapp.WebAppX), so it's only ordinary requests it ever waits on.
 const shutdownTimeout = 10 * time.Second
 
 // defaultVersion is shown if the embedded VERSION file is ever empty, so the frontend
@@ -61,8 +52,20 @@ func main() {
 	wx.Server.POST("/reset", wx.Reset)
 	wx.Server.POST("/switch", wx.Switch)
 	wx.Server.POST("/revert", wx.RevertMove)
+	wx.Server.POST("/redo", wx.Redo)
+	wx.Server.GET("/replay", wx.Replay)
+	wx.Server.POST("/hint", wx.Hint)
 	wx.Server.GET("/wait", wx.Wait)
+	wx.Server.GET("/clock", wx.Clock)
+	wx.Server.GET("/analyze", wx.Analyze)
+	wx.Server.GET("/play/:code", wx.Play)
+	wx.Server.GET("/daily", wx.Daily)
 	wx.Server.GET("/", wx.InitHTMX)
+	wx.Server.GET("/admin", wx.Admin, wx.RequireAdmin)
+	wx.Server.POST("/admin/login", wx.AdminLogin)
+	wx.Server.GET("/admin/sessions", wx.AdminSessions, wx.RequireAdmin)
+	wx.Server.DELETE("/admin/sessions/:handle", wx.AdminEvict, wx.RequireAdmin)
+	wx.Server.PUT("/admin/capacity", wx.AdminCapacity, wx.RequireAdmin)
 
 	// Buffered so the goroutine can always send, whether main() is still waiting on it
 	// (a Start failure) or has already moved on to a normal signal-triggered shutdown.
@@ -101,6 +104,11 @@ func main() {
 		slog.Error(fmt.Sprintf("error during shutdown: %v", err), utils.FuncAttrKey, utils.Caller())
 	}
 
+	// After Shutdown, so no request still in flight saves to a closed store.
+	if err := wx.Sessions.Close(); err != nil {
+		slog.Error(fmt.Sprintf("failed to close the session store: %v", err), utils.FuncAttrKey, utils.Caller())
+	}
+
 	if err := wx.LogCloser.Close(); err != nil {
 		slog.Error(fmt.Sprintf("failed to close log file: %v", err), utils.FuncAttrKey, utils.Caller())
 	}
@@ -1,8 +1,13 @@
 package main
 
 import (
+	"bufio"
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/cookiejar"
@@ -10,6 +15,8 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -31,20 +38,26 @@ func newTestConfigFile(t *testing.T, override func(*utils.Config)) string {
 	dir := t.TempDir()
 
 	config := utils.Config{
-		Port:                            "0",
-		Cheat:                           false,
-		Dim:                             3,
-		ToggleSequence:                  []bool{true, true, false},
-		AvailableToggleSequence:         []int{0, 4, 8},
-		MaxSessions:                     10,
-		SessionTTLSeconds:               1800,
-		SessionIdleTimeoutSeconds:       300,
-		SessionWaitCheckIntervalSeconds: 2,
-		MaxWaitingConnections:           50,
-		LogFilePath:                     filepath.Join(dir, "test.log"),
-		LogMaxSizeMB:                    5,
-		LogMaxBackups:                   5,
-		LogLevel:                        "DEBUG",
+		Port:           "0",
+		Cheat:          false,
+		Rows:           3,
+		Cols:           3,
+		ToggleSequence: []bool{true, true, false, false, false},
+		Patterns: []utils.Pattern{
+			{Name: "0", Offsets: [][2]int{{0, 0}}},
+			{Name: "4", Offsets: [][2]int{{1, 0}, {0, 1}, {-1, 0}, {0, -1}}},
+			{Name: "8", Offsets: [][2]int{{1, 1}, {-1, -1}, {1, -1}, {-1, 1}}},
+			{Name: "knight", Offsets: [][2]int{{1, 2}, {2, 1}, {-1, 2}, {-2, 1}, {1, -2}, {2, -1}, {-1, -2}, {-2, -1}}},
+			{Name: "hex", Offsets: [][2]int{{1, 0}, {1, -1}, {0, -1}, {-1, 0}, {-1, 1}, {0, 1}}},
+		},
+		MaxSessions:               10,
+		SessionTTLSeconds:         1800,
+		SessionIdleTimeoutSeconds: 300,
+		MaxWaitingConnections:     50,
+		LogFilePath:               filepath.Join(dir, "test.log"),
+		LogMaxSizeMB:              5,
+		LogMaxBackups:             5,
+		LogLevel:                  "DEBUG",
 		// Generous by default so ordinary tests firing several quick requests never
 		// get throttled; TestRateLimitBlocksExcessRequests overrides this deliberately.
 		RateLimitRequestsPerSecond: 1000,
@@ -71,23 +84,50 @@ func newTestConfigFile(t *testing.T, override func(*utils.Config)) string {
 func newTestServer(t *testing.T, override func(*utils.Config)) *httptest.Server {
 	t.Helper()
 
-	wx := webapp.NewWebApp(newTestConfigFile(t, override))
+	srv, _ := startTestServer(t, newTestConfigFile(t, override))
+	return srv
+}
+
+// startTestServer serves the app from the config file at configPath -- which a test
+// can start more than one server from, to stand in for a restart.
+func startTestServer(t *testing.T, configPath string) (*httptest.Server, *webapp.WebAppX) {
+	t.Helper()
+
+	wx := webapp.NewWebApp(configPath)
 	wx.Version = "test"
 	wx.Server.POST("/reset", wx.Reset)
 	wx.Server.POST("/switch", wx.Switch)
 	wx.Server.POST("/revert", wx.RevertMove)
+	wx.Server.POST("/redo", wx.Redo)
+	wx.Server.GET("/replay", wx.Replay)
+	wx.Server.POST("/hint", wx.Hint)
 	wx.Server.GET("/wait", wx.Wait)
+	wx.Server.GET("/clock", wx.Clock)
+	wx.Server.GET("/analyze", wx.Analyze)
+	wx.Server.GET("/play/:code", wx.Play)
+	wx.Server.GET("/daily", wx.Daily)
 	wx.Server.GET("/", wx.InitHTMX)
+	wx.Server.GET("/admin", wx.Admin, wx.RequireAdmin)
+	wx.Server.POST("/admin/login", wx.AdminLogin)
+	wx.Server.GET("/admin/sessions", wx.AdminSessions, wx.RequireAdmin)
+	wx.Server.DELETE("/admin/sessions/:handle", wx.AdminEvict, wx.RequireAdmin)
+	wx.Server.PUT("/admin/capacity", wx.AdminCapacity, wx.RequireAdmin)
 
 	srv := httptest.NewServer(wx.Server)
 	// t.Cleanup runs LIFO, so registering LogCloser first means srv.Close() -- which
 	// waits for in-flight requests to finish -- runs before the log file closes,
 	// rather than after. Registered in the opposite order, a request still logging
 	// during shutdown could write to an already-closed file.
+	// The session store likewise closes after srv, so no request saves to it closed.
 	t.Cleanup(func() { _ = wx.LogCloser.Close() })
+	t.Cleanup(func() { _ = wx.Sessions.Close() })
 	t.Cleanup(srv.Close)
+	// Shutting the app down ends its session janitor and any SSE stream a test left
+	// open, before srv.Close waits on them. httptest serves it on a server of its own,
+	// so this only runs Echo's shutdown hooks.
+	t.Cleanup(func() { _ = wx.Server.Shutdown(context.Background()) })
 
-	return srv
+	return srv, wx
 }
 
 func newClient(t *testing.T) *http.Client {
@@ -175,7 +215,8 @@ func TestFullGamePlayFlow(t *testing.T) {
 	}
 
 	form := url.Values{}
-	form.Set("dim", "4")
+	form.Set("rows", "4")
+	form.Set("cols", "4")
 	form.Add("neighborhood", "0")
 	form.Add("neighborhood", "4")
 	form.Set("cheat", "1")
@@ -184,7 +225,7 @@ func TestFullGamePlayFlow(t *testing.T) {
 		t.Fatalf("POST /reset = %d, want 200", status)
 	}
 	if !strings.Contains(body, `value="4"`) {
-		t.Fatalf("POST /reset did not apply the new Dim=4, got: %s", body)
+		t.Fatalf("POST /reset did not apply the new 4x4 size, got: %s", body)
 	}
 
 	status, body = mustPostForm(t, client, srv.URL+"/revert", nil)
@@ -308,7 +349,7 @@ func TestResetErrorIsEscaped(t *testing.T) {
 	mustGet(t, client, srv.URL+"/")
 
 	form := url.Values{}
-	form.Set("dim", "<script>alert(1)</script>")
+	form.Set("rows", "<script>alert(1)</script>")
 	_, body := mustPostForm(t, client, srv.URL+"/reset", form)
 
 	if strings.Contains(body, "<script>alert(1)</script>") {
@@ -323,7 +364,6 @@ func TestCapacityAndSSEWait(t *testing.T) {
 	srv := newTestServer(t, func(c *utils.Config) {
 		c.MaxSessions = 1
 		c.SessionIdleTimeoutSeconds = 1
-		c.SessionWaitCheckIntervalSeconds = 1
 	})
 
 	clientA := newClient(t)
@@ -400,240 +440,1375 @@ func TestRevertMoveSuccessPath(t *testing.T) {
 	}
 }
 
-// TestHandlersRenderWaitingPageAtCapacity checks that Reset, Switch, and RevertMove --
-// not just InitHTMX -- fall back to the waiting page (via withSession's shared
-// "handled" branch) rather than dereferencing a nil session when a client has no slot.
-func TestHandlersRenderWaitingPageAtCapacity(t *testing.T) {
-	srv := newTestServer(t, func(c *utils.Config) {
-		c.MaxSessions = 1
-	})
+// TestUndoRedoFollowsClicks checks /revert undoes the clicks actually made -- including
+// a cell clicked twice, which has already dropped out of the move history -- and that
+// /redo plays them back onto the same board.
+func TestUndoRedoFollowsClicks(t *testing.T) {
+	srv := newTestServer(t, nil)
+	client := newClient(t)
 
-	clientA := newClient(t)
-	mustGet(t, clientA, srv.URL+"/") // takes the only slot
+	mustGet(t, client, srv.URL+"/")
 
-	clientB := newClient(t)
-	mustGet(t, clientB, srv.URL+"/") // gets a waiting-room cookie, no session
+	stateOf := regexp.MustCompile(`data-state="(\d)"`)
+	var boards []string
+	for _, cell := range []string{"row=0&col=0", "row=1&col=1", "row=1&col=1"} {
+		_, body := mustPostForm(t, client, srv.URL+"/switch?"+cell, nil)
+		boards = append(boards, fmt.Sprint(stateOf.FindAllStringSubmatch(body, -1)))
+	}
 
-	for _, tc := range []struct {
-		name string
-		do   func() (int, string)
-	}{
-		{"Reset", func() (int, string) { return mustPostForm(t, clientB, srv.URL+"/reset", nil) }},
-		{"Switch", func() (int, string) { return mustPostForm(t, clientB, srv.URL+"/switch?row=0&col=0", nil) }},
-		{"RevertMove", func() (int, string) { return mustPostForm(t, clientB, srv.URL+"/revert", nil) }},
-	} {
-		t.Run(tc.name, func(t *testing.T) {
-			status, body := tc.do()
-			if status != http.StatusOK {
-				t.Fatalf("%s while waiting = %d, want 200", tc.name, status)
-			}
-			if !strings.Contains(body, "All Tables Are Busy") {
-				t.Fatalf("%s while waiting should render the waiting page, got: %s", tc.name, body)
-			}
-		})
+	_, body := mustPostForm(t, client, srv.URL+"/revert", nil)
+	if strings.Contains(body, "Not allowed") || fmt.Sprint(stateOf.FindAllStringSubmatch(body, -1)) != boards[1] {
+		t.Fatalf("POST /revert should undo the second click of (1,1), got: %s", body)
+	}
+	if !strings.Contains(body, `id="trivia-history" disabled>[0 4]`) || !strings.Contains(body, "Redo (1)") {
+		t.Fatalf("after one undo, history should be [0 4] with one move to redo, got: %s", body)
 	}
-}
 
-// TestWaitReturnsOnClientDisconnect is a regression test for a goroutine/connection
-// leak: Wait must notice ctx.Done() (the client going away) and return promptly rather
-// than blocking forever on its ticker waiting for a session slot that may never free.
-func TestWaitReturnsOnClientDisconnect(t *testing.T) {
-	srv := newTestServer(t, func(c *utils.Config) {
-		c.MaxSessions = 1
-		c.SessionIdleTimeoutSeconds = c.SessionTTLSeconds // never idles out mid-test
-		c.SessionWaitCheckIntervalSeconds = 30            // long enough that only disconnect ends the test
-	})
+	_, body = mustPostForm(t, client, srv.URL+"/redo", nil)
+	if strings.Contains(body, "Not allowed") || fmt.Sprint(stateOf.FindAllStringSubmatch(body, -1)) != boards[2] {
+		t.Fatalf("POST /redo should replay the undone click, got: %s", body)
+	}
 
-	clientA := newClient(t)
-	mustGet(t, clientA, srv.URL+"/") // takes the only slot
+	_, body = mustPostForm(t, client, srv.URL+"/redo", nil)
+	if !strings.Contains(body, "Nothing to redo") {
+		t.Fatalf("POST /redo with nothing undone should be rejected, got: %s", body)
+	}
+}
 
-	clientB := newClient(t)
-	mustGet(t, clientB, srv.URL+"/") // gets a waiting-room cookie
+// TestReplay checks /replay steps through the current game from the board as dealt --
+// undos included -- and autoplays by polling the next step.
+func TestReplay(t *testing.T) {
+	srv := newTestServer(t, nil)
+	client := newClient(t)
 
-	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
-	defer cancel()
+	stateOf := regexp.MustCompile(`data-state="(\d)"`)
+	boardOf := func(body string) string { return fmt.Sprint(stateOf.FindAllStringSubmatch(body, -1)) }
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/wait", nil)
-	if err != nil {
-		t.Fatalf("failed to build /wait request: %v", err)
+	_, body := mustGet(t, client, srv.URL+"/")
+	boards := []string{boardOf(body)}
+	for _, req := range []string{"/switch?row=0&col=0", "/switch?row=1&col=1", "/revert"} {
+		_, body = mustPostForm(t, client, srv.URL+req, nil)
+		boards = append(boards, boardOf(body))
 	}
 
-	done := make(chan struct{})
-	go func() {
-		resp, doErr := clientB.Do(req) //nolint:bodyclose // asserted and closed below once the goroutine hands off
-		if doErr == nil {
-			_ = resp.Body.Close()
+	for step, want := range boards {
+		_, body := mustGet(t, client, fmt.Sprintf("%s/replay?step=%d", srv.URL, step))
+		if got := boardOf(body); got != want {
+			t.Fatalf("GET /replay?step=%d shows %s, want %s", step, got, want)
 		}
-		close(done)
-	}()
+		if !strings.Contains(body, fmt.Sprintf("Move %d of 3", step)) || strings.Contains(body, "hx-post=\"/switch") {
+			t.Fatalf("GET /replay?step=%d should show a read-only board at move %d of 3, got: %s", step, step, body)
+		}
+	}
 
-	select {
-	case <-done:
-		// The client-side context deadline tore down the connection; the server's own
-		// ctx.Done() branch should have returned rather than leaking the goroutine.
-	case <-time.After(5 * time.Second):
-		t.Fatal("GET /wait did not return promptly after the client disconnected")
+	_, body = mustGet(t, client, srv.URL+"/replay?step=3")
+	if !strings.Contains(body, "Undid row 1, column 1") {
+		t.Fatalf("GET /replay?step=3 should describe the undo, got: %s", body)
+	}
+
+	_, body = mustGet(t, client, srv.URL+"/replay?step=1&play=1")
+	if !regexp.MustCompile(`hx-get="/replay\?step=2&amp;play=1" hx-trigger="load delay:\d+ms"`).MatchString(body) {
+		t.Fatalf("GET /replay with play=1 should poll the next step, got: %s", body)
+	}
+
+	_, body = mustGet(t, client, srv.URL+"/replay?step=99&play=1")
+	if boardOf(body) != boards[3] || strings.Contains(body, "hx-trigger=\"load") {
+		t.Fatalf("GET /replay past the end should show the end and stop playing, got: %s", body)
+	}
+
+	_, body = mustGet(t, client, srv.URL+"/replay?step=-1")
+	if !strings.Contains(body, "Params error") {
+		t.Fatalf("GET /replay with a negative step should be rejected, got: %s", body)
 	}
 }
 
-// TestWaitConnectionCapIsEnforced is a regression test for an unbounded-connections
-// DoS vector: without a cap, any number of clients could hold an open /wait SSE
-// connection regardless of MaxSessions, since Wait() never checked a session actually
-// existed for the presented cookie.
-func TestWaitConnectionCapIsEnforced(t *testing.T) {
+// TestCheatSolutionTracksCurrentBoard is the end-to-end regression test for the cheat
+// panel going stale after the first click: after a real move, playing exactly what
+// "Winning Combination" shows must still win.
+func TestCheatSolutionTracksCurrentBoard(t *testing.T) {
 	srv := newTestServer(t, func(c *utils.Config) {
-		c.MaxSessions = 1
-		c.SessionIdleTimeoutSeconds = c.SessionTTLSeconds // never idles out mid-test
-		c.SessionWaitCheckIntervalSeconds = 10            // long enough to stay parked for the test
-		c.MaxWaitingConnections = 2
+		c.Cheat = true
 	})
+	client := newClient(t)
 
-	clientA := newClient(t)
-	mustGet(t, clientA, srv.URL+"/") // takes the only slot
+	mustGet(t, client, srv.URL+"/")
+	_, body := mustPostForm(t, client, srv.URL+"/switch?row=1&col=1", nil)
 
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	for _, pos := range cheatSolution(t, body) {
+		_, body = mustPostForm(t, client, fmt.Sprintf("%s/switch?row=%d&col=%d", srv.URL, pos/3, pos%3), nil)
+	}
 
-	// Two clients park in /wait, filling MaxWaitingConnections.
-	for i := 0; i < 2; i++ {
-		client := newClient(t)
-		mustGet(t, client, srv.URL+"/") // gets a waiting-room cookie
+	if !strings.Contains(body, "YOU WIN") {
+		t.Fatalf("playing the cheat panel's solution did not win, got: %s", body)
+	}
+}
 
-		req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/wait", nil)
+// cheatSolution extracts the positions listed in the trivia panel's "Winning
+// Combination" textarea (rendered as a Go slice, e.g. "[0 4 8]").
+func cheatSolution(t *testing.T, body string) []int {
+	t.Helper()
+
+	_, after, found := strings.Cut(body, `id="trivia-cheat" disabled>`)
+	if !found {
+		t.Fatalf("response has no cheat panel, got: %s", body)
+	}
+	raw, _, _ := strings.Cut(after, "</textarea>")
+	raw = strings.Trim(strings.TrimSpace(raw), "[]")
+
+	var positions []int
+	for _, field := range strings.Fields(raw) {
+		pos, err := strconv.Atoi(field)
 		if err != nil {
-			t.Fatalf("failed to build /wait request: %v", err)
+			t.Fatalf("cheat panel content %q is not a list of positions: %v", raw, err)
 		}
-		go func() {
-			resp, err := client.Do(req) //nolint:bodyclose // best-effort background request, torn down by cancel() + srv.Close()
-			if err == nil {
-				_ = resp.Body.Close()
-			}
-		}()
+		positions = append(positions, pos)
 	}
+	return positions
+}
 
-	time.Sleep(300 * time.Millisecond) // let both background /wait goroutines actually start
+// TestHintHighlightsAProductiveCell plays a whole game from hints alone: each /hint
+// must highlight exactly one cell, and pressing it must eventually win, with the
+// trivia panel counting every hint along the way.
+func TestHintHighlightsAProductiveCell(t *testing.T) {
+	srv := newTestServer(t, nil)
+	client := newClient(t)
 
-	clientC := newClient(t)
-	mustGet(t, clientC, srv.URL+"/") // gets its own waiting-room cookie
+	_, body := mustGet(t, client, srv.URL+"/")
+	if !strings.Contains(body, `id="trivia-hints" value="0"`) {
+		t.Fatalf("a fresh game should report 0 hints used, got: %s", body)
+	}
 
-	status, _ := mustGet(t, clientC, srv.URL+"/wait")
-	if status != http.StatusServiceUnavailable {
-		t.Fatalf("a third /wait connection beyond MaxWaitingConnections=2 should be rejected, got status %d", status)
+	hints := 0
+	for !strings.Contains(body, "YOU WIN") {
+		if hints > 9 {
+			t.Fatalf("a 3x3 board took more than 9 hints to solve, got: %s", body)
+		}
+
+		_, body = mustPostForm(t, client, srv.URL+"/hint", nil)
+		hints++
+
+		if strings.Count(body, "grid-square hint") != 1 {
+			t.Fatalf("POST /hint should highlight exactly one cell, got: %s", body)
+		}
+		if !strings.Contains(body, fmt.Sprintf(`id="trivia-hints" value="%d"`, hints)) {
+			t.Fatalf("POST /hint should count %d hints used, got: %s", hints, body)
+		}
+
+		_, after, _ := strings.Cut(body, "grid-square hint")
+		_, query, _ := strings.Cut(after, `hx-post="/switch?`)
+		query, _, _ = strings.Cut(query, `"`)
+		_, body = mustPostForm(t, client, srv.URL+"/switch?"+strings.ReplaceAll(query, "&amp;", "&"), nil)
+	}
+
+	_, body = mustPostForm(t, client, srv.URL+"/hint", nil)
+	if !strings.Contains(body, "already solved") {
+		t.Fatalf("POST /hint on a won board should be refused, got: %s", body)
 	}
 }
 
-func TestRateLimitBlocksExcessRequests(t *testing.T) {
-	srv := newTestServer(t, func(c *utils.Config) {
-		c.RateLimitRequestsPerSecond = 1
-		c.RateLimitBurst = 1
-	})
+// TestResetWithCustomPattern plays with a config-declared pattern the old hardcoded
+// 0/4/8 list couldn't express, and checks an undeclared one is refused.
+func TestResetWithCustomPattern(t *testing.T) {
+	srv := newTestServer(t, nil)
 	client := newClient(t)
 
-	sawOK := false
-	sawThrottled := false
+	mustGet(t, client, srv.URL+"/")
 
-	for i := 0; i < 20 && !sawThrottled; i++ {
-		status, _ := mustGet(t, client, srv.URL+"/")
-		switch status {
-		case http.StatusOK:
-			sawOK = true
-		case http.StatusTooManyRequests:
-			sawThrottled = true
-		default:
-			t.Fatalf("GET / = %d, want 200 or 429", status)
-		}
-	}
+	form := url.Values{}
+	form.Set("rows", "4")
+	form.Set("cols", "4")
+	form.Add("neighborhood", "0")
+	form.Add("neighborhood", "knight")
+	_, body := mustPostForm(t, client, srv.URL+"/reset", form)
 
-	if !sawOK {
-		t.Fatal("expected at least one request to succeed before throttling kicked in")
+	if strings.Contains(body, "Params error") {
+		t.Fatalf("POST /reset with a configured custom pattern should succeed, got: %s", body)
 	}
-	if !sawThrottled {
-		t.Fatal("expected the rate limiter to eventually respond with 429 Too Many Requests")
+	if !regexp.MustCompile(`id="config-neighborhood-knight"\s+checked`).MatchString(body) {
+		t.Fatalf("POST /reset with the knight pattern should leave its checkbox checked, got: %s", body)
+	}
+
+	form.Set("neighborhood", "camel")
+	_, body = mustPostForm(t, client, srv.URL+"/reset", form)
+	if !strings.Contains(body, "is not a configured pattern") {
+		t.Fatalf("POST /reset with an undeclared pattern should be rejected, got: %s", body)
 	}
 }
 
-// TestSessionExpiryNotice exercises the UX gap where a session gets silently purged
-// while its owner is away: the owner should be told, not just handed a blank fresh
-// board with no explanation.
-func TestSessionExpiryNotice(t *testing.T) {
-	srv := newTestServer(t, func(c *utils.Config) {
-		c.MaxSessions = 1
-		c.SessionIdleTimeoutSeconds = 1
-	})
+// TestResetRejectsDegenerateConfiguration checks that a configuration which can never
+// deal an unsolved board is refused with a validation error instead of faked.
+func TestResetRejectsDegenerateConfiguration(t *testing.T) {
+	srv := newTestServer(t, nil)
+	client := newClient(t)
 
-	clientA := newClient(t)
-	clientB := newClient(t)
+	mustGet(t, client, srv.URL+"/")
 
-	_, bodyA := mustGet(t, clientA, srv.URL+"/")
-	if strings.Contains(bodyA, "SYSTEM MESSAGE") {
-		t.Fatalf("a first-ever visit should not show an expiry notice, got: %s", bodyA)
+	form := url.Values{}
+	form.Set("rows", "2")
+	form.Set("cols", "2")
+	form.Add("neighborhood", "0")
+	form.Add("neighborhood", "4")
+	form.Add("neighborhood", "8")
+	_, body := mustPostForm(t, client, srv.URL+"/reset", form)
+
+	if !strings.Contains(body, "can never produce an unsolved board") {
+		t.Fatalf("POST /reset with a degenerate configuration should be rejected, got: %s", body)
 	}
+}
 
-	time.Sleep(1500 * time.Millisecond) // let client A's session go idle
+// TestResetRectangularBoard deals a non-square board and checks rows and columns stay
+// the right way round end to end: the rendered board's shape, and a click on the last
+// column being accepted rather than bounds-checked against the row count.
+func TestResetRectangularBoard(t *testing.T) {
+	srv := newTestServer(t, nil)
+	client := newClient(t)
 
-	// A second client claims the only slot, evicting A's now-idle session.
-	_, bodyB := mustGet(t, clientB, srv.URL+"/")
-	if strings.Contains(bodyB, "SYSTEM MESSAGE") {
-		t.Fatalf("a brand new client should never see an expiry notice, got: %s", bodyB)
+	mustGet(t, client, srv.URL+"/")
+
+	form := url.Values{}
+	form.Set("rows", "4")
+	form.Set("cols", "7")
+	form.Add("neighborhood", "0")
+	form.Add("neighborhood", "4")
+	_, body := mustPostForm(t, client, srv.URL+"/reset", form)
+
+	if strings.Contains(body, "Params error") {
+		t.Fatalf("POST /reset with a 4x7 board should succeed, got: %s", body)
+	}
+	if n := strings.Count(body, "hx-post=\"/switch?row="); n != 28 {
+		t.Fatalf("POST /reset with a 4x7 board rendered %d cells, want 28", n)
+	}
+	if !strings.Contains(body, "/switch?row=3&amp;col=6") || strings.Contains(body, "/switch?row=6") {
+		t.Fatalf("POST /reset with a 4x7 board rendered the wrong shape, got: %s", body)
 	}
 
-	time.Sleep(1500 * time.Millisecond) // let client B's session go idle too
+	_, body = mustPostForm(t, client, srv.URL+"/switch?row=3&col=6", nil)
+	if strings.Contains(body, "out of bounds") {
+		t.Fatalf("POST /switch on the last cell of a 4x7 board was rejected, got: %s", body)
+	}
 
-	// Client A comes back with its now-stale cookie; its old session is gone, so it
-	// gets a fresh one and should be told about it.
-	_, bodyA = mustGet(t, clientA, srv.URL+"/")
-	if !strings.Contains(bodyA, "SYSTEM MESSAGE") {
-		t.Fatalf("client A should see the expiry notice after its session was purged, got: %s", bodyA)
+	_, body = mustPostForm(t, client, srv.URL+"/switch?row=4&col=0", nil)
+	if !strings.Contains(body, "out of bounds") {
+		t.Fatalf("POST /switch past the last row of a 4x7 board should be rejected, got: %s", body)
 	}
 }
 
-// TestTrimmedVersionFallsBackWhenEmpty is a direct test of trimmedVersion()'s
-// defaultVersion fallback -- the real embedded VERSION file is never empty in this
-// repo, so nothing else exercises this branch.
-func TestTrimmedVersionFallsBackWhenEmpty(t *testing.T) {
-	prev := rawVersion
-	defer func() { rawVersion = prev }()
+// TestResetTorusTopology deals a wrap-around board and checks the choice sticks to the
+// session: it's re-rendered as the checked option, and its analysis differs from the
+// same-sized bounded board's.
+func TestResetTorusTopology(t *testing.T) {
+	srv := newTestServer(t, nil)
+	client := newClient(t)
 
-	for _, tc := range []struct {
-		name string
-		raw  string
-		want string
-	}{
-		{"empty", "", defaultVersion},
-		{"whitespace only", "   \n\t", defaultVersion},
-		{"real version", "1.2.3\n", "1.2.3"},
-	} {
-		t.Run(tc.name, func(t *testing.T) {
-			rawVersion = tc.raw
-			if got := trimmedVersion(); got != tc.want {
-				t.Errorf("trimmedVersion() with rawVersion=%q = %q, want %q", tc.raw, got, tc.want)
-			}
-		})
+	mustGet(t, client, srv.URL+"/")
+
+	form := url.Values{}
+	form.Set("rows", "3")
+	form.Set("cols", "3")
+	form.Add("neighborhood", "0")
+	form.Add("neighborhood", "4")
+	form.Set("topology", "torus")
+	_, body := mustPostForm(t, client, srv.URL+"/reset", form)
+
+	if strings.Contains(body, "Params error") {
+		t.Fatalf("POST /reset with a torus topology should succeed, got: %s", body)
+	}
+	if !regexp.MustCompile(`id="config-topology-torus"\s+checked`).MatchString(body) {
+		t.Fatalf("POST /reset with a torus topology should leave it checked, got: %s", body)
 	}
-}
 
-// TestVersionMatchesChangelog guards against VERSION and CHANGELOG.md silently
-// drifting apart -- nothing else (the build, CI) checks that the embedded version and
-// the changelog's most recent entry agree.
-func TestVersionMatchesChangelog(t *testing.T) {
-	versionBytes, err := os.ReadFile("VERSION")
-	if err != nil {
-		t.Fatalf("failed to read VERSION: %v", err)
+	_, body = mustPostForm(t, client, srv.URL+"/switch?row=0&col=0", nil)
+	if !regexp.MustCompile(`id="config-topology-torus"\s+checked`).MatchString(body) {
+		t.Fatalf("the session's torus topology was lost after a move, got: %s", body)
 	}
-	version := strings.TrimSpace(string(versionBytes))
 
-	changelogBytes, err := os.ReadFile("CHANGELOG.md")
-	if err != nil {
-		t.Fatalf("failed to read CHANGELOG.md: %v", err)
+	_, bounded := mustGet(t, client, srv.URL+"/analyze?rows=3&cols=3&neighborhood=0&neighborhood=4&topology=bounded")
+	_, torus := mustGet(t, client, srv.URL+"/analyze?rows=3&cols=3&neighborhood=0&neighborhood=4&topology=torus")
+	if bounded == torus {
+		t.Fatalf("GET /analyze gave identical results for the bounded and torus 3x3 boards: %s", torus)
 	}
 
-	// Skips a non-version heading (e.g. a future "## Unreleased" convention) rather than
-	// taking the very first "## " line unconditionally -- a version heading always
-	// starts with a digit, which "Unreleased" (or similar) never would.
-	var latest string
-	for _, line := range strings.Split(string(changelogBytes), "\n") {
-		heading, isHeading := strings.CutPrefix(line, "## ")
-		if !isHeading {
+	form.Set("topology", "klein")
+	_, body = mustPostForm(t, client, srv.URL+"/reset", form)
+	if !strings.Contains(body, "is not one of") {
+		t.Fatalf("POST /reset with an unknown topology should be rejected, got: %s", body)
+	}
+}
+
+// TestResetHexLattice deals a hex board and checks it's laid out as one: the choice is
+// re-rendered checked, the board is marked for the offset-row layout, and its analysis
+// differs from the same offsets read on a square board.
+func TestResetHexLattice(t *testing.T) {
+	srv := newTestServer(t, nil)
+	client := newClient(t)
+
+	mustGet(t, client, srv.URL+"/")
+
+	form := url.Values{}
+	form.Set("rows", "3")
+	form.Set("cols", "3")
+	form.Set("lattice", "hex")
+	form.Add("neighborhood", "0")
+	form.Add("neighborhood", "hex")
+	_, body := mustPostForm(t, client, srv.URL+"/reset", form)
+
+	if strings.Contains(body, "Params error") {
+		t.Fatalf("POST /reset with a hex lattice should succeed, got: %s", body)
+	}
+	if !regexp.MustCompile(`id="config-lattice-hex"\s+checked`).MatchString(body) {
+		t.Fatalf("POST /reset with a hex lattice should leave it checked, got: %s", body)
+	}
+	if !strings.Contains(body, `class="grid-hex"`) {
+		t.Fatalf("a hex board should be rendered with the hex layout, got: %s", body)
+	}
+
+	_, square := mustGet(t, client, srv.URL+"/analyze?rows=3&cols=3&lattice=square&neighborhood=0&neighborhood=hex")
+	_, hex := mustGet(t, client, srv.URL+"/analyze?rows=3&cols=3&lattice=hex&neighborhood=0&neighborhood=hex")
+	if square == hex {
+		t.Fatalf("GET /analyze gave identical results for the square and hex 3x3 boards: %s", hex)
+	}
+
+	form.Set("lattice", "triangle")
+	_, body = mustPostForm(t, client, srv.URL+"/reset", form)
+	if !strings.Contains(body, "is not one of") {
+		t.Fatalf("POST /reset with an unknown lattice should be rejected, got: %s", body)
+	}
+}
+
+// TestResetGraphBoard switches to one of the shipped graph boards: it's rendered as
+// positioned nodes instead of rows, and a press lands on a node by its index.
+func TestResetGraphBoard(t *testing.T) {
+	graphsFile, err := filepath.Abs("graphs.json")
+	if err != nil {
+		t.Fatalf("failed to resolve graphs.json: %v", err)
+	}
+	srv := newTestServer(t, func(c *utils.Config) { c.GraphsFile = graphsFile })
+	client := newClient(t)
+
+	_, body := mustGet(t, client, srv.URL+"/")
+	if !strings.Contains(body, `<option value="petersen">petersen</option>`) {
+		t.Fatalf("the configuration panel should offer every configured graph, got: %s", body)
+	}
+
+	form := url.Values{}
+	form.Set("rows", "3")
+	form.Set("cols", "3")
+	form.Add("neighborhood", "0")
+	form.Add("neighborhood", "4")
+	form.Set("graph", "petersen")
+	_, body = mustPostForm(t, client, srv.URL+"/reset", form)
+
+	if strings.Contains(body, "Params error") {
+		t.Fatalf("POST /reset with a graph board should succeed, got: %s", body)
+	}
+	if !strings.Contains(body, `class="grid-graph"`) || strings.Count(body, `class="grid-square grid-node`) != 10 {
+		t.Fatalf("the Petersen graph should render as 10 positioned nodes, got: %s", body)
+	}
+	if !strings.Contains(body, `<option value="petersen" selected>`) {
+		t.Fatalf("POST /reset with a graph board should leave it selected, got: %s", body)
+	}
+
+	_, body = mustPostForm(t, client, srv.URL+"/switch?row=0&col=9", nil)
+	if strings.Contains(body, "Params error") || strings.Contains(body, "out of bounds") {
+		t.Fatalf("POST /switch on the last node should be accepted, got: %s", body)
+	}
+	_, body = mustPostForm(t, client, srv.URL+"/switch?row=0&col=10", nil)
+	if !strings.Contains(body, "out of bounds") {
+		t.Fatalf("POST /switch past the last node should be rejected, got: %s", body)
+	}
+
+	form.Set("graph", "moebius")
+	_, body = mustPostForm(t, client, srv.URL+"/reset", form)
+	if !strings.Contains(body, "not a configured graph") {
+		t.Fatalf("POST /reset with an unknown graph should be rejected, got: %s", body)
+	}
+}
+
+// TestResetMultiStateBoard plays a 3-state board: cells render all three states'
+// colours via data-state, a press advances rather than flips, and Undo steps it back.
+func TestResetMultiStateBoard(t *testing.T) {
+	srv := newTestServer(t, nil)
+	client := newClient(t)
+
+	mustGet(t, client, srv.URL+"/")
+
+	form := url.Values{}
+	form.Set("rows", "3")
+	form.Set("cols", "3")
+	form.Add("neighborhood", "0")
+	form.Set("states", "3")
+	_, body := mustPostForm(t, client, srv.URL+"/reset", form)
+
+	if strings.Contains(body, "Params error") {
+		t.Fatalf("POST /reset with 3 states should succeed, got: %s", body)
+	}
+	if !strings.Contains(body, `<option value="3" selected>`) {
+		t.Fatalf("POST /reset with 3 states should leave it selected, got: %s", body)
+	}
+
+	stateOf := regexp.MustCompile(`data-state="(\d)"`)
+	before := stateOf.FindAllStringSubmatch(body, -1)
+	if len(before) != 9 {
+		t.Fatalf("expected 9 cells, got %d: %s", len(before), body)
+	}
+
+	_, body = mustPostForm(t, client, srv.URL+"/switch?row=0&col=0", nil)
+	after := stateOf.FindAllStringSubmatch(body, -1)
+	was, _ := strconv.Atoi(before[0][1])
+	if now, _ := strconv.Atoi(after[0][1]); now != (was+1)%3 {
+		t.Fatalf("pressing a 3-state cell took it from %d to %d, want %d", was, now, (was+1)%3)
+	}
+
+	_, body = mustPostForm(t, client, srv.URL+"/revert", nil)
+	reverted := stateOf.FindAllStringSubmatch(body, -1)
+	if reverted[0][1] != before[0][1] {
+		t.Fatalf("Undo on a 3-state cell left it at %s, want %s", reverted[0][1], before[0][1])
+	}
+
+	form.Set("states", "4")
+	_, body = mustPostForm(t, client, srv.URL+"/reset", form)
+	if !strings.Contains(body, "is not one of") {
+		t.Fatalf("POST /reset with an unsupported number of states should be rejected, got: %s", body)
+	}
+
+	form.Set("states", "3")
+	form.Set("rows", "20")
+	form.Set("cols", "20")
+	_, body = mustPostForm(t, client, srv.URL+"/reset", form)
+	if !strings.Contains(body, "at most 256 cells") {
+		t.Fatalf("POST /reset with a 3-state board over the cell cap should be rejected, got: %s", body)
+	}
+}
+
+// TestResetLargeBoard checks that the size fields go up to 50 on a two-state board,
+// and a press on the packed board renders like any other. A board that size is too big
+// to redeal for a difficulty or rate by its optimal solution, so its rating is shown as
+// an estimate. What it costs depends on the machine, not on anything under test, so the
+// client waits as long as the test itself may run instead of its usual 10 seconds.
+func TestResetLargeBoard(t *testing.T) {
+	srv := newTestServer(t, nil)
+	client := newClient(t)
+	client.Timeout = 0
+
+	mustGet(t, client, srv.URL+"/")
+
+	form := url.Values{}
+	form.Set("rows", "50")
+	form.Set("cols", "50")
+	form.Add("neighborhood", "0")
+	form.Add("neighborhood", "4")
+	form.Set("difficulty", "hard")
+	_, body := mustPostForm(t, client, srv.URL+"/reset", form)
+	if strings.Contains(body, "Params error") {
+		t.Fatalf("POST /reset with a 50x50 board should succeed, got: %s", body)
+	}
+	if !strings.Contains(body, ", estimated)") {
+		t.Fatalf("a 50x50 board's rating should be shown as an estimate, got: %s", body)
+	}
+
+	_, body = mustPostForm(t, client, srv.URL+"/switch?row=49&col=49", nil)
+	if strings.Contains(body, "Params error") || !strings.Contains(body, "row=49&amp;col=49") {
+		t.Fatalf("pressing the last cell of a 50x50 board should render it, got: %s", body)
+	}
+
+	form.Set("rows", "51")
+	_, body = mustPostForm(t, client, srv.URL+"/reset", form)
+	if !strings.Contains(body, "Params error") {
+		t.Fatalf("POST /reset with 51 rows should be rejected, got: %s", body)
+	}
+}
+
+// TestResetDifficulty checks a requested difficulty is dealt, kept selected, shown in
+// the trivia panel and carried by the puzzle code -- and that an unknown one is refused.
+func TestResetDifficulty(t *testing.T) {
+	srv := newTestServer(t, nil)
+	alice, bob := newClient(t), newClient(t)
+
+	mustGet(t, alice, srv.URL+"/")
+
+	form := url.Values{}
+	form.Set("rows", "5")
+	form.Set("cols", "5")
+	form.Add("neighborhood", "0")
+	form.Add("neighborhood", "4")
+	form.Set("difficulty", "hard")
+	_, body := mustPostForm(t, alice, srv.URL+"/reset", form)
+	if strings.Contains(body, "Params error") {
+		t.Fatalf("POST /reset with difficulty=hard should succeed, got: %s", body)
+	}
+	if !strings.Contains(body, `<option value="hard" selected>hard</option>`) {
+		t.Fatalf("POST /reset should keep difficulty=hard selected, got: %s", body)
+	}
+	if !regexp.MustCompile(`id="trivia-difficulty" value="hard \(0\.\d\d\)"`).MatchString(body) {
+		t.Fatalf("a hard board should be rated hard in the trivia panel, got: %s", body)
+	}
+
+	_, body = mustGet(t, bob, srv.URL+"/play/"+puzzleCode(t, body))
+	if !strings.Contains(body, `<option value="hard" selected>hard</option>`) {
+		t.Fatalf("a hard board's puzzle code should deal it as hard, got: %s", body)
+	}
+
+	form.Set("difficulty", "brutal")
+	_, body = mustPostForm(t, alice, srv.URL+"/reset", form)
+	if !strings.Contains(body, "Params error") {
+		t.Fatalf("POST /reset with an unknown difficulty should be rejected, got: %s", body)
+	}
+}
+
+// TestResetTarget checks a picture target is kept through /reset and the puzzle code,
+// shown next to the board, and won by exactly what the cheat panel suggests -- and that
+// a picture too big for the board is rejected.
+func TestResetTarget(t *testing.T) {
+	srv := newTestServer(t, func(c *utils.Config) {
+		c.Cheat = true
+	})
+	alice, bob := newClient(t), newClient(t)
+
+	mustGet(t, alice, srv.URL+"/")
+
+	form := url.Values{}
+	form.Set("rows", "5")
+	form.Set("cols", "5")
+	form.Add("neighborhood", "0")
+	form.Add("neighborhood", "4")
+	form.Set("target", "heart")
+	form.Set("cheat", "1")
+	_, body := mustPostForm(t, alice, srv.URL+"/reset", form)
+	if strings.Contains(body, "Params error") {
+		t.Fatalf("POST /reset with target=heart should succeed, got: %s", body)
+	}
+	if !strings.Contains(body, `<option value="heart" selected>heart</option>`) {
+		t.Fatalf("POST /reset should keep target=heart selected, got: %s", body)
+	}
+	if !strings.Contains(body, "<legend>Target</legend>") {
+		t.Fatalf("a target board should show its target next to it, got: %s", body)
+	}
+
+	_, bobBody := mustGet(t, bob, srv.URL+"/play/"+puzzleCode(t, body))
+	if !strings.Contains(bobBody, `<option value="heart" selected>heart</option>`) {
+		t.Fatalf("a target board's puzzle code should deal it with its target, got: %s", bobBody)
+	}
+
+	for _, pos := range cheatSolution(t, body) {
+		_, body = mustPostForm(t, alice, fmt.Sprintf("%s/switch?row=%d&col=%d", srv.URL, pos/5, pos%5), nil)
+	}
+	if !strings.Contains(body, "YOU WIN") {
+		t.Fatalf("playing the cheat panel's solution should reach the target, got: %s", body)
+	}
+
+	form.Set("rows", "3")
+	form.Set("cols", "3")
+	_, body = mustPostForm(t, alice, srv.URL+"/reset", form)
+	if !strings.Contains(body, "Params error") {
+		t.Fatalf("POST /reset with a picture bigger than the board should be rejected, got: %s", body)
+	}
+}
+
+// TestSwitchLitOnly checks a lit-only board disables its unlit cells, rejects a press on
+// one anyway, keeps the rule through its puzzle code, and is won by playing the cheat
+// panel's solution in order.
+func TestSwitchLitOnly(t *testing.T) {
+	srv := newTestServer(t, func(c *utils.Config) {
+		c.Cheat = true
+	})
+	alice, bob := newClient(t), newClient(t)
+
+	mustGet(t, alice, srv.URL+"/")
+
+	form := url.Values{}
+	form.Set("rows", "4")
+	form.Set("cols", "4")
+	form.Add("neighborhood", "0")
+	form.Add("neighborhood", "4")
+	form.Set("litonly", "1")
+	form.Set("cheat", "1")
+	_, body := mustPostForm(t, alice, srv.URL+"/reset", form)
+	if strings.Contains(body, "Params error") {
+		t.Fatalf("POST /reset with litonly=1 should succeed, got: %s", body)
+	}
+	if !regexp.MustCompile(`id="config-litonly" value="1"\s+checked`).MatchString(body) {
+		t.Fatalf("POST /reset should keep lit-only ticked, got: %s", body)
+	}
+
+	off := regexp.MustCompile(`aria-label="Row (\d+), column (\d+), off"\s+disabled`).FindStringSubmatch(body)
+	if off == nil {
+		t.Fatalf("a lit-only board should disable its unlit cells, got: %s", body)
+	}
+	solution := cheatSolution(t, body)
+
+	_, body = mustPostForm(t, alice, fmt.Sprintf("%s/switch?row=%s&col=%s", srv.URL, off[1], off[2]), nil)
+	if !strings.Contains(body, "only lit cells can be switched") {
+		t.Fatalf("switching an unlit cell on a lit-only board should be rejected, got: %s", body)
+	}
+
+	_, bobBody := mustGet(t, bob, srv.URL+"/play/"+puzzleCode(t, body))
+	if !regexp.MustCompile(`id="config-litonly" value="1"\s+checked`).MatchString(bobBody) {
+		t.Fatalf("a lit-only board's puzzle code should deal it lit-only, got: %s", bobBody)
+	}
+
+	for _, pos := range solution {
+		_, body = mustPostForm(t, alice, fmt.Sprintf("%s/switch?row=%d&col=%d", srv.URL, pos/4, pos%4), nil)
+		if strings.Contains(body, "Params error") {
+			t.Fatalf("the cheat panel's solution %v pressed an unlit cell at %d, got: %s", solution, pos, body)
+		}
+	}
+	if !strings.Contains(body, "YOU WIN") {
+		t.Fatalf("playing the cheat panel's solution in order should win, got: %s", body)
+	}
+
+	form.Set("states", "3")
+	_, body = mustPostForm(t, alice, srv.URL+"/reset", form)
+	if !strings.Contains(body, "Params error") {
+		t.Fatalf("POST /reset with a lit-only 3-state board should be rejected, got: %s", body)
+	}
+}
+
+// TestMoveBudget checks a game's move limit counts down in the trivia panel, ends the
+// game once it's spent -- undo doesn't give presses back -- and that a par-based one
+// leaves exactly enough for the optimal solution.
+func TestMoveBudget(t *testing.T) {
+	srv := newTestServer(t, func(c *utils.Config) {
+		c.Cheat = true
+	})
+	client := newClient(t)
+
+	mustGet(t, client, srv.URL+"/")
+
+	form := url.Values{}
+	form.Set("rows", "3")
+	form.Set("cols", "3")
+	form.Add("neighborhood", "0")
+	form.Add("neighborhood", "4")
+	form.Set("cheat", "1")
+	form.Set("budget", "2")
+	_, body := mustPostForm(t, client, srv.URL+"/reset", form)
+	if !strings.Contains(body, `id="trivia-budget" value="2 of 2"`) {
+		t.Fatalf("a 2-move budget should show 2 moves left, got: %s", body)
+	}
+	if !strings.Contains(body, `id="config-budget" value="2"`) {
+		t.Fatalf("POST /reset should keep the budget in the configuration panel, got: %s", body)
+	}
+
+	// Pressing the same cell twice is back where it started: not won, and out of moves.
+	mustPostForm(t, client, srv.URL+"/switch?row=0&col=0", nil)
+	_, body = mustPostForm(t, client, srv.URL+"/switch?row=0&col=0", nil)
+	if !strings.Contains(body, `value="0 of 2"`) || !strings.Contains(body, "OUT OF MOVES") {
+		t.Fatalf("spending the whole budget without winning should show OUT OF MOVES, got: %s", body)
+	}
+
+	_, body = mustPostForm(t, client, srv.URL+"/revert", nil)
+	if !strings.Contains(body, "OUT OF MOVES") {
+		t.Fatalf("undo shouldn't give presses back, got: %s", body)
+	}
+	_, body = mustPostForm(t, client, srv.URL+"/switch?row=1&col=1", nil)
+	if !strings.Contains(body, "out of moves") {
+		t.Fatalf("POST /switch past the budget should be rejected, got: %s", body)
+	}
+
+	form.Set("budget", "par")
+	_, body = mustPostForm(t, client, srv.URL+"/reset", form)
+	solution := cheatSolution(t, body)
+	if want := fmt.Sprintf(`id="trivia-budget" value="%d of %d"`, len(solution), len(solution)); !strings.Contains(body, want) {
+		t.Fatalf("a par budget should allow exactly the optimal solution's %d moves, got: %s", len(solution), body)
+	}
+	for _, pos := range solution {
+		_, body = mustPostForm(t, client, fmt.Sprintf("%s/switch?row=%d&col=%d", srv.URL, pos/3, pos%3), nil)
+	}
+	if !strings.Contains(body, "YOU WIN") || strings.Contains(body, "OUT OF MOVES") {
+		t.Fatalf("winning on the last move of the budget should be a win, got: %s", body)
+	}
+
+	form.Set("budget", "lots")
+	_, body = mustPostForm(t, client, srv.URL+"/reset", form)
+	if !strings.Contains(body, "Params error") {
+		t.Fatalf("POST /reset with an invalid budget should be rejected, got: %s", body)
+	}
+}
+
+// TestClockIsOnePerSession checks a session gets one /clock stream at a time: opening
+// another ends the first, so a client can't hold open any number of them.
+func TestClockIsOnePerSession(t *testing.T) {
+	srv := newTestServer(t, nil)
+	client := newClient(t)
+
+	mustGet(t, client, srv.URL+"/")
+	form := url.Values{}
+	form.Set("rows", "3")
+	form.Set("cols", "3")
+	form.Add("neighborhood", "0")
+	form.Add("neighborhood", "4")
+	form.Set("timelimit", "600")
+	mustPostForm(t, client, srv.URL+"/reset", form)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	openClock := func() *http.Response {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/clock", nil)
+		if err != nil {
+			t.Fatalf("failed to build /clock request: %v", err)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("GET /clock failed: %v", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("GET /clock = %d, want 200", resp.StatusCode)
+		}
+		return resp
+	}
+
+	first := openClock()
+	defer func() { _ = first.Body.Close() }()
+	second := openClock()
+	defer func() { _ = second.Body.Close() }()
+
+	// The first stream ends well before the 5s deadline; the second keeps ticking.
+	if _, err := io.ReadAll(first.Body); err != nil {
+		t.Fatalf("the first /clock stream should have been ended by the second, got: %v", err)
+	}
+	line, err := bufio.NewReader(second.Body).ReadString('\n')
+	if err != nil || !strings.HasPrefix(line, "event: tick") {
+		t.Fatalf("the second /clock stream should keep ticking, got %q, %v", line, err)
+	}
+}
+
+// TestTimedGame checks a game's clock is the server's: a countdown that runs out is
+// pushed over /clock as a lost game, which then refuses presses, while a game won in
+// time keeps its solve time and stops following the clock.
+func TestTimedGame(t *testing.T) {
+	srv := newTestServer(t, func(c *utils.Config) {
+		c.Cheat = true
+	})
+	client := newClient(t)
+
+	mustGet(t, client, srv.URL+"/")
+
+	form := url.Values{}
+	form.Set("rows", "3")
+	form.Set("cols", "3")
+	form.Add("neighborhood", "0")
+	form.Add("neighborhood", "4")
+	form.Set("cheat", "1")
+	form.Set("timelimit", "1")
+	_, body := mustPostForm(t, client, srv.URL+"/reset", form)
+	if !strings.Contains(body, `sse-connect="/clock"`) || !strings.Contains(body, `id="trivia-clock" sse-swap="tick">0:01<`) {
+		t.Fatalf("a 1-second game should follow its countdown over /clock, got: %s", body)
+	}
+	if !strings.Contains(body, `id="config-timelimit" value="1"`) {
+		t.Fatalf("POST /reset should keep the time limit in the configuration panel, got: %s", body)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/clock", nil)
+	if err != nil {
+		t.Fatalf("failed to build /clock request: %v", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("GET /clock failed: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	sse, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("reading /clock response failed (did it never send the timeout event?): %v", err)
+	}
+	if !strings.Contains(string(sse), "event: timeout") || !strings.Contains(string(sse), "OUT OF TIME") {
+		t.Fatalf("expected an SSE 'timeout' event with the lost game once the second ran out, got: %s", sse)
+	}
+
+	_, body = mustPostForm(t, client, srv.URL+"/switch?row=0&col=0", nil)
+	if !strings.Contains(body, "out of time") || strings.Contains(body, `sse-connect="/clock"`) {
+		t.Fatalf("POST /switch after the countdown should be rejected, with no clock left to follow, got: %s", body)
+	}
+	if status, _ := mustGet(t, client, srv.URL+"/clock"); status != http.StatusNoContent {
+		t.Fatalf("GET /clock with no countdown running = %d, want 204 so the browser stops reconnecting", status)
+	}
+
+	form.Set("timelimit", "600")
+	_, body = mustPostForm(t, client, srv.URL+"/reset", form)
+	for _, pos := range cheatSolution(t, body) {
+		_, body = mustPostForm(t, client, fmt.Sprintf("%s/switch?row=%d&col=%d", srv.URL, pos/3, pos%3), nil)
+	}
+	if !strings.Contains(body, "YOU WIN") || !strings.Contains(body, "Solved In") || !strings.Contains(body, `id="trivia-first-move"`) {
+		t.Fatalf("winning in time should show the solve and first-move times, got: %s", body)
+	}
+	if strings.Contains(body, `sse-connect="/clock"`) {
+		t.Fatalf("a won game should stop following its countdown, got: %s", body)
+	}
+
+	form.Set("timelimit", "forever")
+	_, body = mustPostForm(t, client, srv.URL+"/reset", form)
+	if !strings.Contains(body, "Params error") {
+		t.Fatalf("POST /reset with an invalid time limit should be rejected, got: %s", body)
+	}
+}
+
+// TestTimedGameRefusesUndoAndRedo checks that once a game's time has run out, undo and
+// redo are refused just as presses are -- either would change the lost board. The
+// moves before the deadline only give them something to work on: were they too slow to
+// make it, undo and redo would still have to say the game is out of time, rather than
+// that there's nothing to undo or redo.
+func TestTimedGameRefusesUndoAndRedo(t *testing.T) {
+	srv := newTestServer(t, nil)
+	client := newClient(t)
+
+	mustGet(t, client, srv.URL+"/")
+
+	form := url.Values{}
+	form.Set("rows", "3")
+	form.Set("cols", "3")
+	form.Add("neighborhood", "0")
+	form.Add("neighborhood", "4")
+	form.Set("timelimit", "1")
+	mustPostForm(t, client, srv.URL+"/reset", form)
+	mustPostForm(t, client, srv.URL+"/switch?row=0&col=0", nil)
+	mustPostForm(t, client, srv.URL+"/switch?row=1&col=1", nil)
+	mustPostForm(t, client, srv.URL+"/revert", nil)
+
+	time.Sleep(1100 * time.Millisecond)
+
+	for _, path := range []string{"/revert", "/redo"} {
+		_, body := mustPostForm(t, client, srv.URL+path, nil)
+		if !strings.Contains(body, "out of time") {
+			t.Fatalf("POST %s after the countdown should be rejected, got: %s", path, body)
+		}
+	}
+}
+
+// TestSessionsSurviveRestart checks a game in progress is still there, move for move,
+// for a client coming back to a server restarted on the same session store.
+func TestSessionsSurviveRestart(t *testing.T) {
+	configPath := newTestConfigFile(t, func(c *utils.Config) {
+		c.SessionStorePath = filepath.Join(t.TempDir(), "sessions", "journal")
+	})
+	srv, wx := startTestServer(t, configPath)
+	client := newClient(t)
+
+	mustGet(t, client, srv.URL+"/")
+
+	form := url.Values{}
+	form.Set("rows", "4")
+	form.Set("cols", "4")
+	form.Add("neighborhood", "0")
+	form.Add("neighborhood", "4")
+	form.Set("budget", "30")
+	mustPostForm(t, client, srv.URL+"/reset", form)
+	mustPostForm(t, client, srv.URL+"/switch?row=1&col=2", nil)
+	mustPostForm(t, client, srv.URL+"/switch?row=3&col=0", nil)
+	_, before := mustPostForm(t, client, srv.URL+"/revert", nil)
+
+	srv.Close()
+	if err := wx.Sessions.Close(); err != nil {
+		t.Fatalf("closing the session store failed: %v", err)
+	}
+
+	restarted, _ := startTestServer(t, configPath)
+	from, _ := url.Parse(srv.URL)
+	to, _ := url.Parse(restarted.URL)
+	client.Jar.SetCookies(to, client.Jar.Cookies(from))
+
+	_, after := mustGet(t, client, restarted.URL+"/")
+	if strings.Contains(after, "SYSTEM MESSAGE") {
+		t.Fatalf("a restored session shouldn't be reported expired, got: %s", after)
+	}
+	for _, want := range []string{
+		`id="trivia-history" disabled>[6]<`,
+		`id="trivia-budget" value="28 of 30"`,
+		"Redo (1)",
+		`id="trivia-code" value="` + puzzleCode(t, before) + `"`,
+	} {
+		if !strings.Contains(after, want) {
+			t.Fatalf("the restored game should match the one played before the restart (%s), got: %s", want, after)
+		}
+	}
+}
+
+// TestPlayPuzzleCode checks a board's puzzle code deals exactly that board again, with
+// its configuration, for another client as well as for its own -- and that a bad code
+// is reported rather than dealt.
+func TestPlayPuzzleCode(t *testing.T) {
+	srv := newTestServer(t, nil)
+	alice, bob := newClient(t), newClient(t)
+
+	mustGet(t, alice, srv.URL+"/")
+
+	form := url.Values{}
+	form.Set("rows", "4")
+	form.Set("cols", "5")
+	form.Add("neighborhood", "0")
+	form.Add("neighborhood", "4")
+	form.Set("topology", "torus")
+	form.Set("states", "3")
+	_, body := mustPostForm(t, alice, srv.URL+"/reset", form)
+
+	code := puzzleCode(t, body)
+	stateOf := regexp.MustCompile(`data-state="(\d)"`)
+	dealt := fmt.Sprint(stateOf.FindAllStringSubmatch(body, -1))
+
+	mustPostForm(t, alice, srv.URL+"/switch?row=0&col=0", nil)
+
+	for _, client := range []*http.Client{bob, alice} {
+		status, body := mustGet(t, client, srv.URL+"/play/"+strings.ToLower(code))
+		if status != http.StatusOK || strings.Contains(body, "Params error") {
+			t.Fatalf("GET /play/%s = %d, got: %s", code, status, body)
+		}
+		if got := fmt.Sprint(stateOf.FindAllStringSubmatch(body, -1)); got != dealt {
+			t.Fatalf("GET /play/%s dealt %s, want the original %s", code, got, dealt)
+		}
+		if puzzleCode(t, body) != code {
+			t.Fatalf("GET /play/%s shows code %s, want the same one", code, puzzleCode(t, body))
+		}
+		if !strings.Contains(body, `id="config-rows" value="4"`) || !regexp.MustCompile(`id="config-topology-torus"\s+checked`).MatchString(body) {
+			t.Fatalf("GET /play/%s should show the puzzle's configuration, got: %s", code, body)
+		}
+		if !strings.Contains(body, `id="trivia-history" disabled>[]`) {
+			t.Fatalf("GET /play/%s should start with no moves, got: %s", code, body)
+		}
+	}
+
+	_, body = mustGet(t, bob, srv.URL+"/play/NOTACODE")
+	if !strings.Contains(body, "invalid puzzle code") {
+		t.Fatalf("GET /play with a bad code should be rejected, got: %s", body)
+	}
+}
+
+// puzzleCode extracts the trivia panel's puzzle code.
+func puzzleCode(t *testing.T, body string) string {
+	t.Helper()
+
+	match := regexp.MustCompile(`id="trivia-code" value="([A-Z2-7]+)"`).FindStringSubmatch(body)
+	if match == nil {
+		t.Fatalf("response has no puzzle code, got: %s", body)
+	}
+	return match[1]
+}
+
+// TestDailyPuzzle checks /daily deals every client the same board, and that solving it
+// is recorded in the status header.
+func TestDailyPuzzle(t *testing.T) {
+	srv := newTestServer(t, func(c *utils.Config) {
+		c.Cheat = true
+	})
+	alice, bob := newClient(t), newClient(t)
+
+	_, body := mustGet(t, alice, srv.URL+"/")
+	if !strings.Contains(body, "not solved yet") {
+		t.Fatalf("a fresh session should show today's daily as not solved yet, got: %s", body)
+	}
+
+	_, body = mustGet(t, alice, srv.URL+"/daily")
+	_, bobBody := mustGet(t, bob, srv.URL+"/daily")
+	if puzzleCode(t, body) != puzzleCode(t, bobBody) {
+		t.Fatalf("GET /daily dealt two clients different boards: %s and %s", puzzleCode(t, body), puzzleCode(t, bobBody))
+	}
+	if !strings.Contains(body, "in progress") {
+		t.Fatalf("GET /daily should show today's daily in progress, got: %s", body)
+	}
+
+	solution := cheatSolution(t, body)
+	for _, pos := range solution {
+		_, body = mustPostForm(t, alice, fmt.Sprintf("%s/switch?row=%d&col=%d", srv.URL, pos/3, pos%3), nil)
+	}
+	if want := fmt.Sprintf("solved in %d moves", len(solution)); !strings.Contains(body, want) {
+		t.Fatalf("solving the daily should show %q, got: %s", want, body)
+	}
+
+	// Still recorded after moving on to another board.
+	_, body = mustPostForm(t, alice, srv.URL+"/reset", url.Values{"rows": {"3"}, "cols": {"3"}, "neighborhood": {"0", "4"}})
+	if !strings.Contains(body, "solved in") {
+		t.Fatalf("the daily's result should outlast a reset, got: %s", body)
+	}
+	_, bobBody = mustGet(t, bob, srv.URL+"/")
+	if strings.Contains(bobBody, "solved in") {
+		t.Fatalf("one client's daily result leaked into another's session, got: %s", bobBody)
+	}
+}
+
+func TestAnalyzePreview(t *testing.T) {
+	srv := newTestServer(t, nil)
+	client := newClient(t)
+
+	status, body := mustGet(t, client, srv.URL+"/analyze?rows=5&cols=5&neighborhood=0&neighborhood=4")
+	if status != http.StatusOK {
+		t.Fatalf("GET /analyze = %d, want 200", status)
+	}
+	if !strings.Contains(body, "<dd>23</dd>") || !strings.Contains(body, "25%") {
+		t.Fatalf("GET /analyze for classic 5x5 should report rank 23 and 25%% solvable, got: %s", body)
+	}
+
+	_, body = mustGet(t, client, srv.URL+"/analyze?rows=2&cols=2&neighborhood=0&neighborhood=4&neighborhood=8")
+	if !strings.Contains(body, "Degenerate") {
+		t.Fatalf("GET /analyze for 2x2 {0,4,8} should flag it degenerate, got: %s", body)
+	}
+
+	_, body = mustGet(t, client, srv.URL+"/analyze?rows=3&cols=51&neighborhood=0")
+	if !strings.Contains(body, "analysis-error") {
+		t.Fatalf("GET /analyze with an out-of-range side should render an error, got: %s", body)
+	}
+}
+
+// TestAnalyzeNeedsSession checks /analyze, like the game endpoints, won't work out a
+// configuration for a client that hasn't got a session.
+func TestAnalyzeNeedsSession(t *testing.T) {
+	srv := newTestServer(t, func(c *utils.Config) {
+		c.MaxSessions = 1
+	})
+
+	mustGet(t, newClient(t), srv.URL+"/") // takes the only slot
+
+	status, body := mustGet(t, newClient(t), srv.URL+"/analyze?rows=5&cols=5&neighborhood=0&neighborhood=4")
+	if status != http.StatusOK {
+		t.Fatalf("GET /analyze = %d, want 200", status)
+	}
+	if !strings.Contains(body, "analysis-error") || strings.Contains(body, "<dd>23</dd>") {
+		t.Fatalf("GET /analyze without a session should render an error, not the analysis, got: %s", body)
+	}
+}
+
+// TestWaitQueuesClientsInOrder checks the waiting room is first come, first served:
+// each waiting client is shown its place in line, and a slot that frees up goes to the
+// client at the head of it, not to whichever waiter happens to check first.
+func TestWaitQueuesClientsInOrder(t *testing.T) {
+	srv := newTestServer(t, func(c *utils.Config) {
+		c.MaxSessions = 1
+		c.SessionIdleTimeoutSeconds = 1
+	})
+
+	mustGet(t, newClient(t), srv.URL+"/") // takes the only slot, then idles out
+
+	first, second := newClient(t), newClient(t)
+	if _, body := mustGet(t, first, srv.URL+"/"); !strings.Contains(body, "You&#39;re number 1 of 1 in line.") {
+		t.Fatalf("the first waiting client should be shown it's first in line, got: %s", body)
+	}
+	if _, body := mustGet(t, second, srv.URL+"/"); !strings.Contains(body, `sse-swap="position">You&#39;re number 2 of 2 in line.<`) {
+		t.Fatalf("the second waiting client should be shown it's second in line, got: %s", body)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/wait", nil)
+	if err != nil {
+		t.Fatalf("failed to build /wait request: %v", err)
+	}
+	resp, err := second.Do(req)
+	if err != nil {
+		t.Fatalf("GET /wait failed: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	events := bufio.NewScanner(resp.Body)
+
+	nextEvent := func() (event, data string) {
+		t.Helper()
+		for events.Scan() {
+			line := events.Text()
+			switch {
+			case strings.HasPrefix(line, "event: "):
+				event = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				data += strings.TrimPrefix(line, "data: ")
+			case line == "" && event != "":
+				return event, data
+			}
+		}
+		t.Fatalf("/wait stream ended early: %v", events.Err())
+		return "", ""
+	}
+
+	if event, data := nextEvent(); event != "position" || data != "You're number 2 of 2 in line." {
+		t.Fatalf("/wait's first event = %s %q, want the second client's place in line", event, data)
+	}
+
+	// The first client, streaming or not, is next in line: once the slot's owner idles
+	// out, the second client's stream must not take the slot from it.
+	time.Sleep(1500 * time.Millisecond)
+	if _, body := mustGet(t, first, srv.URL+"/"); strings.Contains(body, "All Tables Are Busy") {
+		t.Fatalf("the first client in line should get the freed slot, got: %s", body)
+	}
+	if event, data := nextEvent(); event != "position" || data != "You're number 1 of 1 in line." {
+		t.Fatalf("/wait's next event = %s %q, want the second client moved up to first", event, data)
+	}
+}
+
+// TestHandlersRenderWaitingPageAtCapacity checks that Reset, Switch, RevertMove, Redo, Replay and Hint --
+// not just InitHTMX -- fall back to the waiting page (via withSession's shared
+// "handled" branch) rather than dereferencing a nil session when a client has no slot.
+func TestHandlersRenderWaitingPageAtCapacity(t *testing.T) {
+	srv := newTestServer(t, func(c *utils.Config) {
+		c.MaxSessions = 1
+	})
+
+	clientA := newClient(t)
+	mustGet(t, clientA, srv.URL+"/") // takes the only slot
+
+	clientB := newClient(t)
+	mustGet(t, clientB, srv.URL+"/") // gets a waiting-room cookie, no session
+
+	for _, tc := range []struct {
+		name string
+		do   func() (int, string)
+	}{
+		{"Reset", func() (int, string) { return mustPostForm(t, clientB, srv.URL+"/reset", nil) }},
+		{"Switch", func() (int, string) { return mustPostForm(t, clientB, srv.URL+"/switch?row=0&col=0", nil) }},
+		{"RevertMove", func() (int, string) { return mustPostForm(t, clientB, srv.URL+"/revert", nil) }},
+		{"Redo", func() (int, string) { return mustPostForm(t, clientB, srv.URL+"/redo", nil) }},
+		{"Replay", func() (int, string) { return mustGet(t, clientB, srv.URL+"/replay") }},
+		{"Hint", func() (int, string) { return mustPostForm(t, clientB, srv.URL+"/hint", nil) }},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			status, body := tc.do()
+			if status != http.StatusOK {
+				t.Fatalf("%s while waiting = %d, want 200", tc.name, status)
+			}
+			if !strings.Contains(body, "All Tables Are Busy") {
+				t.Fatalf("%s while waiting should render the waiting page, got: %s", tc.name, body)
+			}
+		})
+	}
+}
+
+// TestWaitReturnsOnClientDisconnect is a regression test for a goroutine/connection
+// leak: Wait must notice ctx.Done() (the client going away) and return promptly rather
+// than blocking forever waiting for a session slot that may never free.
+func TestWaitReturnsOnClientDisconnect(t *testing.T) {
+	srv := newTestServer(t, func(c *utils.Config) {
+		c.MaxSessions = 1
+		c.SessionIdleTimeoutSeconds = c.SessionTTLSeconds // never idles out mid-test
+	})
+
+	clientA := newClient(t)
+	mustGet(t, clientA, srv.URL+"/") // takes the only slot
+
+	clientB := newClient(t)
+	mustGet(t, clientB, srv.URL+"/") // gets a waiting-room cookie
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/wait", nil)
+	if err != nil {
+		t.Fatalf("failed to build /wait request: %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		resp, doErr := clientB.Do(req) //nolint:bodyclose // asserted and closed below once the goroutine hands off
+		if doErr == nil {
+			_ = resp.Body.Close()
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		// The client-side context deadline tore down the connection; the server's own
+		// ctx.Done() branch should have returned rather than leaking the goroutine.
+	case <-time.After(5 * time.Second):
+		t.Fatal("GET /wait did not return promptly after the client disconnected")
+	}
+}
+
+// TestShutdownEndsOpenWaitStreams checks a graceful shutdown doesn't have to wait out a
+// parked /wait stream: it's ended as shutdown begins, however long it might otherwise
+// have waited for a slot.
+func TestShutdownEndsOpenWaitStreams(t *testing.T) {
+	srv, wx := startTestServer(t, newTestConfigFile(t, func(c *utils.Config) {
+		c.MaxSessions = 1
+		c.SessionIdleTimeoutSeconds = c.SessionTTLSeconds // never idles out mid-test
+	}))
+
+	mustGet(t, newClient(t), srv.URL+"/") // takes the only slot
+	client := newClient(t)
+	mustGet(t, client, srv.URL+"/") // gets a waiting-room cookie
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/wait", nil)
+	if err != nil {
+		t.Fatalf("failed to build /wait request: %v", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("GET /wait failed: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if err := wx.Server.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown() failed: %v", err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("/wait didn't end cleanly on shutdown: %v", err)
+	}
+	if strings.Contains(string(body), "event: ready") {
+		t.Fatalf("/wait let the client in on shutdown, got: %s", body)
+	}
+}
+
+// TestWaitConnectionCapIsEnforced is a regression test for an unbounded-connections
+// DoS vector: without a cap, any number of clients could hold an open /wait SSE
+// connection regardless of MaxSessions, since Wait() never checked a session actually
+// existed for the presented cookie.
+func TestWaitConnectionCapIsEnforced(t *testing.T) {
+	srv := newTestServer(t, func(c *utils.Config) {
+		c.MaxSessions = 1
+		c.SessionIdleTimeoutSeconds = c.SessionTTLSeconds // never idles out mid-test
+		c.MaxWaitingConnections = 2
+	})
+
+	clientA := newClient(t)
+	mustGet(t, clientA, srv.URL+"/") // takes the only slot
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Two clients park in /wait, filling MaxWaitingConnections.
+	for i := 0; i < 2; i++ {
+		client := newClient(t)
+		mustGet(t, client, srv.URL+"/") // gets a waiting-room cookie
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/wait", nil)
+		if err != nil {
+			t.Fatalf("failed to build /wait request: %v", err)
+		}
+		go func() {
+			resp, err := client.Do(req) //nolint:bodyclose // best-effort background request, torn down by cancel() + srv.Close()
+			if err == nil {
+				// Wait pushes the client's place in line straight away, so Do returns
+				// with the stream still open: hold it open until the test's done.
+				<-ctx.Done()
+				_ = resp.Body.Close()
+			}
+		}()
+	}
+
+	time.Sleep(300 * time.Millisecond) // let both background /wait goroutines actually start
+
+	clientC := newClient(t)
+	mustGet(t, clientC, srv.URL+"/") // gets its own waiting-room cookie
+
+	status, _ := mustGet(t, clientC, srv.URL+"/wait")
+	if status != http.StatusServiceUnavailable {
+		t.Fatalf("a third /wait connection beyond MaxWaitingConnections=2 should be rejected, got status %d", status)
+	}
+}
+
+func TestRateLimitBlocksExcessRequests(t *testing.T) {
+	srv := newTestServer(t, func(c *utils.Config) {
+		c.RateLimitRequestsPerSecond = 1
+		c.RateLimitBurst = 1
+	})
+	client := newClient(t)
+
+	sawOK := false
+	sawThrottled := false
+
+	for i := 0; i < 20 && !sawThrottled; i++ {
+		status, _ := mustGet(t, client, srv.URL+"/")
+		switch status {
+		case http.StatusOK:
+			sawOK = true
+		case http.StatusTooManyRequests:
+			sawThrottled = true
+		default:
+			t.Fatalf("GET / = %d, want 200 or 429", status)
+		}
+	}
+
+	if !sawOK {
+		t.Fatal("expected at least one request to succeed before throttling kicked in")
+	}
+	if !sawThrottled {
+		t.Fatal("expected the rate limiter to eventually respond with 429 Too Many Requests")
+	}
+}
+
+// TestSessionExpiryNotice exercises the UX gap where a session gets silently purged
+// while its owner is away: the owner should be told, not just handed a blank fresh
+// board with no explanation.
+func TestSessionExpiryNotice(t *testing.T) {
+	srv := newTestServer(t, func(c *utils.Config) {
+		c.MaxSessions = 1
+		c.SessionIdleTimeoutSeconds = 1
+	})
+
+	clientA := newClient(t)
+	clientB := newClient(t)
+
+	_, bodyA := mustGet(t, clientA, srv.URL+"/")
+	if strings.Contains(bodyA, "SYSTEM MESSAGE") {
+		t.Fatalf("a first-ever visit should not show an expiry notice, got: %s", bodyA)
+	}
+
+	time.Sleep(1500 * time.Millisecond) // let client A's session go idle
+
+	// A second client claims the only slot, evicting A's now-idle session.
+	_, bodyB := mustGet(t, clientB, srv.URL+"/")
+	if strings.Contains(bodyB, "SYSTEM MESSAGE") {
+		t.Fatalf("a brand new client should never see an expiry notice, got: %s", bodyB)
+	}
+
+	time.Sleep(1500 * time.Millisecond) // let client B's session go idle too
+
+	// Client A comes back with its now-stale cookie; its old session is gone, so it
+	// gets a fresh one and should be told about it.
+	_, bodyA = mustGet(t, clientA, srv.URL+"/")
+	if !strings.Contains(bodyA, "SYSTEM MESSAGE") {
+		t.Fatalf("client A should see the expiry notice after its session was purged, got: %s", bodyA)
+	}
+}
+
+// TestTrimmedVersionFallsBackWhenEmpty is a direct test of trimmedVersion()'s
+// defaultVersion fallback -- the real embedded VERSION file is never empty in this
+// repo, so nothing else exercises this branch.
+func TestTrimmedVersionFallsBackWhenEmpty(t *testing.T) {
+	prev := rawVersion
+	defer func() { rawVersion = prev }()
+
+	for _, tc := range []struct {
+		name string
+		raw  string
+		want string
+	}{
+		{"empty", "", defaultVersion},
+		{"whitespace only", "   \n\t", defaultVersion},
+		{"real version", "1.2.3\n", "1.2.3"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rawVersion = tc.raw
+			if got := trimmedVersion(); got != tc.want {
+				t.Errorf("trimmedVersion() with rawVersion=%q = %q, want %q", tc.raw, got, tc.want)
+			}
+		})
+	}
+}
+
+// TestVersionMatchesChangelog guards against VERSION and CHANGELOG.md silently
+// drifting apart -- nothing else (the build, CI) checks that the embedded version and
+// the changelog's most recent entry agree.
+func TestVersionMatchesChangelog(t *testing.T) {
+	versionBytes, err := os.ReadFile("VERSION")
+	if err != nil {
+		t.Fatalf("failed to read VERSION: %v", err)
+	}
+	version := strings.TrimSpace(string(versionBytes))
+
+	changelogBytes, err := os.ReadFile("CHANGELOG.md")
+	if err != nil {
+		t.Fatalf("failed to read CHANGELOG.md: %v", err)
+	}
+
+	// Skips a non-version heading (e.g. a future "## Unreleased" convention) rather than
+	// taking the very first "## " line unconditionally -- a version heading always
+	// starts with a digit, which "Unreleased" (or similar) never would.
+	var latest string
+	for _, line := range strings.Split(string(changelogBytes), "\n") {
+		heading, isHeading := strings.CutPrefix(line, "## ")
+		if !isHeading {
 			continue
 		}
 		heading = strings.TrimSpace(heading)
@@ -651,3 +1826,205 @@ func TestVersionMatchesChangelog(t *testing.T) {
 		t.Fatalf("CHANGELOG.md's latest entry (%q) does not match VERSION (%q)", latest, version)
 	}
 }
+
+// adminRequest makes an admin API request, authorized by token if it isn't empty.
+func adminRequest(t *testing.T, client *http.Client, method, rawURL, token string, form url.Values) (int, string) {
+	t.Helper()
+
+	req, err := http.NewRequestWithContext(context.Background(), method, rawURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		t.Fatalf("failed to build %s %s request: %v", method, rawURL, err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s failed: %v", method, rawURL, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("reading response body failed: %v", err)
+	}
+	return resp.StatusCode, string(body)
+}
+
+// adminSessions fetches the admin API's view of the server.
+func adminSessions(t *testing.T, srvURL, token string) (view struct {
+	Sessions []struct {
+		Handle string
+		Rows   int
+		Moves  int
+		Won    bool
+	}
+	Count        int
+	MaxSessions  int
+	Waiting      int
+	WaitingConns int
+},
+) {
+	t.Helper()
+
+	status, body := adminRequest(t, newClient(t), http.MethodGet, srvURL+"/admin/sessions", token, nil)
+	if status != http.StatusOK {
+		t.Fatalf("GET /admin/sessions = %d, want 200: %s", status, body)
+	}
+	if err := json.Unmarshal([]byte(body), &view); err != nil {
+		t.Fatalf("GET /admin/sessions returned bad JSON: %v: %s", err, body)
+	}
+	return view
+}
+
+func TestAdminAPI(t *testing.T) {
+	const token = "test-admin-token-0123456789"
+	srv := newTestServer(t, func(c *utils.Config) {
+		c.MaxSessions = 1
+		c.AdminToken = token
+	})
+
+	for _, bad := range []string{"", "wrong-token-wrong-token"} {
+		if status, _ := adminRequest(t, newClient(t), http.MethodGet, srv.URL+"/admin/sessions", bad, nil); status != http.StatusUnauthorized {
+			t.Fatalf("GET /admin/sessions with token %q = %d, want 401", bad, status)
+		}
+	}
+
+	player := newClient(t)
+	mustGet(t, player, srv.URL+"/")
+	mustPostForm(t, player, srv.URL+"/switch?row=1&col=1", nil)
+	waiter := newClient(t)
+	mustGet(t, waiter, srv.URL+"/")
+
+	view := adminSessions(t, srv.URL, token)
+	if view.Count != 1 || view.MaxSessions != 1 || view.Waiting != 1 || len(view.Sessions) != 1 {
+		t.Fatalf("admin view = %+v, want the one session at capacity and one client waiting", view)
+	}
+	if got := view.Sessions[0]; got.Rows != 3 || got.Moves != 1 || got.Handle == "" {
+		t.Fatalf("admin view's session = %+v, want the 3x3 board one move in", got)
+	}
+
+	// The admin API names sessions by handle: a session's ID is its player's credential.
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatalf("failed to parse the server URL: %v", err)
+	}
+	for _, cookie := range player.Jar.Cookies(u) {
+		if _, body := adminRequest(t, newClient(t), http.MethodGet, srv.URL+"/admin/sessions", token, nil); strings.Contains(body, cookie.Value) {
+			t.Fatalf("GET /admin/sessions leaks the player's %s cookie: %s", cookie.Name, body)
+		}
+		if status, _ := adminRequest(t, newClient(t), http.MethodDelete, srv.URL+"/admin/sessions/"+cookie.Value, token, nil); status != http.StatusNotFound {
+			t.Fatalf("DELETE /admin/sessions/ with the session's ID = %d, want 404: only its handle names it", status)
+		}
+	}
+
+	// Raising the capacity lets the waiting client in without a restart.
+	status, body := adminRequest(t, newClient(t), http.MethodPut, srv.URL+"/admin/capacity", token, url.Values{"maxsessions": {"2"}})
+	if status != http.StatusOK || !strings.Contains(body, `"MaxSessions":2`) {
+		t.Fatalf("PUT /admin/capacity = %d %s, want MaxSessions raised to 2", status, body)
+	}
+	if _, body := mustGet(t, waiter, srv.URL+"/"); strings.Contains(body, "All Tables Are Busy") || !strings.Contains(body, "Sessions: 2/2") {
+		t.Fatalf("the waiting client should be let in once capacity was raised, got: %s", body)
+	}
+	if status, _ := adminRequest(t, newClient(t), http.MethodPut, srv.URL+"/admin/capacity", token, url.Values{"maxsessions": {"0"}}); status != http.StatusBadRequest {
+		t.Fatalf("PUT /admin/capacity with 0 = %d, want 400", status)
+	}
+
+	// Evicting the player's session starts them over, told it expired.
+	status, _ = adminRequest(t, newClient(t), http.MethodDelete, srv.URL+"/admin/sessions/"+view.Sessions[0].Handle, token, nil)
+	if status != http.StatusOK {
+		t.Fatalf("DELETE /admin/sessions/:handle = %d, want 200", status)
+	}
+	if _, body := mustGet(t, player, srv.URL+"/"); !strings.Contains(body, "Your previous session expired") {
+		t.Fatalf("an evicted player should be told their session expired, got: %s", body)
+	}
+	if status, _ := adminRequest(t, newClient(t), http.MethodDelete, srv.URL+"/admin/sessions/no-such-session", token, nil); status != http.StatusNotFound {
+		t.Fatalf("DELETE of an unknown session = %d, want 404", status)
+	}
+}
+
+func TestAdminDashboardLogin(t *testing.T) {
+	const token = "test-admin-token-0123456789"
+	srv := newTestServer(t, func(c *utils.Config) { c.AdminToken = token })
+	admin := newClient(t)
+
+	status, body := mustGet(t, admin, srv.URL+"/admin")
+	if status != http.StatusUnauthorized || !strings.Contains(body, `action="/admin/login"`) {
+		t.Fatalf("GET /admin logged out = %d, want 401 with the login form: %s", status, body)
+	}
+	if status, body := mustPostForm(t, admin, srv.URL+"/admin/login", url.Values{"token": {"nope"}}); status != http.StatusUnauthorized || !strings.Contains(body, "Wrong token.") {
+		t.Fatalf("POST /admin/login with a wrong token = %d, want 401 and an error: %s", status, body)
+	}
+
+	// The redirect back to the dashboard is followed with the new cookie.
+	status, body = mustPostForm(t, admin, srv.URL+"/admin/login", url.Values{"token": {token}})
+	if status != http.StatusOK || !strings.Contains(body, "Live Sessions") || !strings.Contains(body, `hx-put="/admin/capacity"`) {
+		t.Fatalf("POST /admin/login with the token = %d, want the dashboard: %s", status, body)
+	}
+	if strings.Contains(body, token) {
+		t.Fatal("the dashboard page leaks the admin token")
+	}
+
+	adminURL, err := url.Parse(srv.URL + "/admin")
+	if err != nil {
+		t.Fatalf("parsing the admin URL failed: %v", err)
+	}
+	var issued string
+	for _, cookie := range admin.Jar.Cookies(adminURL) {
+		if cookie.Name == "goswitch_admin" {
+			issued = cookie.Value
+		}
+	}
+	stamp, _, _ := strings.Cut(issued, ".")
+	if unix, err := strconv.ParseInt(stamp, 10, 64); err != nil || time.Since(time.Unix(unix, 0)) > time.Minute {
+		t.Fatalf("the login cookie %q should start with the time it was issued", issued)
+	}
+
+	sign := func(issuedAt int64) string {
+		stamp := strconv.FormatInt(issuedAt, 10)
+		mac := hmac.New(sha256.New, []byte(token))
+		_, _ = mac.Write([]byte("goswitch-admin:" + stamp))
+		return stamp + "." + hex.EncodeToString(mac.Sum(nil))
+	}
+	digest := sha256.Sum256([]byte("goswitch-admin:" + token))
+	now := time.Now().Unix()
+	for _, tt := range []struct {
+		name, value string
+		want        int
+	}{
+		{"freshly signed", sign(now), http.StatusOK},
+		{"past its max age", sign(now - 13*3600), http.StatusUnauthorized},
+		{"dated in the future", sign(now + 3600), http.StatusUnauthorized},
+		{"with its time moved on", strconv.FormatInt(now+3600, 10) + issued[len(stamp):], http.StatusUnauthorized},
+		{"a bare digest of the token", hex.EncodeToString(digest[:]), http.StatusUnauthorized},
+	} {
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, srv.URL+"/admin/sessions", nil)
+		if err != nil {
+			t.Fatalf("failed to build the request: %v", err)
+		}
+		req.AddCookie(&http.Cookie{Name: "goswitch_admin", Value: tt.value})
+		resp, err := newClient(t).Do(req)
+		if err != nil {
+			t.Fatalf("GET /admin/sessions failed: %v", err)
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode != tt.want {
+			t.Errorf("GET /admin/sessions with a cookie %s = %d, want %d", tt.name, resp.StatusCode, tt.want)
+		}
+	}
+}
+
+func TestAdminIsOffWithoutAToken(t *testing.T) {
+	srv := newTestServer(t, nil)
+
+	for _, path := range []string{"/admin", "/admin/sessions"} {
+		if status, _ := mustGet(t, newClient(t), srv.URL+path); status != http.StatusNotFound {
+			t.Fatalf("GET %s with no AdminToken configured = %d, want 404", path, status)
+		}
+	}
+	if status, _ := mustPostForm(t, newClient(t), srv.URL+"/admin/login", url.Values{"token": {""}}); status != http.StatusNotFound {
+		t.Fatalf("POST /admin/login with no AdminToken configured = %d, want 404", status)
+	}
+}
@@ -0,0 +1,252 @@
+package grid
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sync"
+
+	utils "goSwitch/modules/utils"
+)
+
+// maxCachedConfigs bounds configCache. The set of reachable configurations is small
+// (the HTTP API clamps the board size and only accepts configured patterns), so this is a
+// backstop against unbounded growth rather than a tuned working-set size.
+const maxCachedConfigs = 64
+
+// cachedConfig is everything derived from a Spec alone: the toggle table Switch reads,
+// and the reduced toggle matrix the solver and Analyze read, built on first use. The
+// matrix and analysis are built under their own sync.Once rather than configCacheMu: the
+// elimination is O(n^3/64) on the biggest boards, and holding the cache lock through it
+// would stall every other configuration's lookup -- including Switch's toggle table --
+// behind one new spec.
+type cachedConfig struct {
+	toggles *toggleTable
+
+	systemOnce sync.Once
+	system     toggleSystem
+
+	// analysis is indexed by whether the Spec's Target is a picture (or random)
+	// rather than uniform, the one thing besides the matrix an Analysis depends on.
+	analysisOnce [2]sync.Once
+	analysis     [2]Analysis
+}
+
+var (
+	configCacheMu sync.Mutex
+	configCache   = make(map[string]*cachedConfig)
+)
+
+// cachedConfigLocked returns spec's cache entry, creating it -- toggle table included
+// -- if there isn't one yet. The caller must hold configCacheMu.
+func cachedConfigLocked(spec Spec) *cachedConfig {
+	key := fmt.Sprintf("%dx%d:%t:%t:%d:%v", spec.Rows, spec.Cols, spec.Lattice == LatticeHex, spec.Topology == TopologyTorus, spec.NumStates(), spec.Neighborhood)
+	if spec.Graph != nil {
+		key = fmt.Sprintf("graph:%d:%d:%v", spec.Graph.Nodes, spec.NumStates(), spec.Graph.Edges)
+	}
+
+	if entry, ok := configCache[key]; ok {
+		return entry
+	}
+
+	rows, cols := spec.size()
+	probe := &Grid{
+		Rows:         rows,
+		Cols:         cols,
+		lattice:      spec.Lattice,
+		neighborhood: spec.Neighborhood,
+		topology:     spec.Topology,
+		graph:        spec.Graph,
+		board:        boardState{n: rows * cols},
+	}
+	entry := &cachedConfig{toggles: newToggleTable(rows*cols, spec.NumStates(), probe.affected)}
+
+	if len(configCache) >= maxCachedConfigs {
+		for k := range configCache {
+			delete(configCache, k)
+			break
+		}
+	}
+	configCache[key] = entry
+
+	return entry
+}
+
+// cachedToggles returns the toggle table for spec, shared by every Grid with that
+// configuration.
+func cachedToggles(spec Spec) *toggleTable {
+	return cachedEntry(spec).toggles
+}
+
+// cachedEntry returns spec's cache entry, holding configCacheMu only for the lookup.
+func cachedEntry(spec Spec) *cachedConfig {
+	configCacheMu.Lock()
+	defer configCacheMu.Unlock()
+
+	return cachedConfigLocked(spec)
+}
+
+// cachedSystem returns the reduced toggle matrix for spec, shared by every Grid and
+// Analyze call with that configuration. Built under the entry's sync.Once so two
+// concurrent first uses don't both pay for the elimination.
+func cachedSystem(spec Spec) toggleSystem {
+	return cachedEntry(spec).toggleSystem(spec)
+}
+
+// toggleSystem returns entry's reduced toggle matrix, building it on first use.
+func (entry *cachedConfig) toggleSystem(spec Spec) toggleSystem {
+	entry.systemOnce.Do(func() {
+		n := len(entry.toggles.affected)
+		effects := func(pos int) []int { return entry.toggles.affected[pos] }
+		if states := spec.NumStates(); states == 2 {
+			entry.system = newLinearSystem(n, effects)
+		} else {
+			entry.system = newModSystem(n, states, effects)
+		}
+	})
+	return entry.system
+}
+
+// Analysis describes what a Spec can and can't do, independent of any particular
+// board.
+type Analysis struct {
+	Spec Spec
+	Rank int
+	// QuietPatterns is a basis of the toggle matrix's null space: press lists (flat
+	// positions, ascending, repeated for cells pressed more than once) that leave the
+	// board unchanged. Their count is the null space's dimension; each one multiplies
+	// the number of solutions of every solvable board by k, and divides the share of
+	// boards that are solvable at all by k, for k states per cell.
+	QuietPatterns [][]int
+	// SolvableFraction is the share of all k^cells boards from which some press
+	// list reaches a win: every cell in the same state, or with a Target other than
+	// TargetUniform, that target's one board.
+	SolvableFraction float64
+	// Degenerate is true when every board reachable from a won board is itself won, so
+	// no real puzzle can ever be dealt -- e.g. a 2x2 board with every pattern enabled,
+	// where each press flips all 4 cells, unless it's played to a Target picture,
+	// which that flip moves away from.
+	Degenerate bool
+}
+
+// Nullity returns the dimension of the toggle matrix's null space.
+func (a Analysis) Nullity() int {
+	return len(a.QuietPatterns)
+}
+
+// Analyze reports the toggle matrix's rank, quiet patterns and solvability for boards
+// built from spec. Both sides must be >= 1, as with NewGrid. It's worked out once per
+// configuration and kind of Target -- uniform, or a single board -- like the toggle
+// matrix itself, so QuietPatterns is shared by every caller and mustn't be modified.
+func Analyze(spec Spec) Analysis {
+	entry := cachedEntry(spec)
+	i := 0
+	if spec.Target != TargetUniform {
+		i = 1
+	}
+	entry.analysisOnce[i].Do(func() {
+		entry.analysis[i] = analyze(spec, entry.toggleSystem(spec))
+	})
+	a := entry.analysis[i]
+	a.Spec = spec
+	return a
+}
+
+// analyze is Analyze for spec's toggle matrix ls, uncached.
+func analyze(spec Spec, ls toggleSystem) Analysis {
+	rows, cols := spec.size()
+	n := rows * cols
+	k := float64(ls.modulus())
+
+	// The winnable boards are the column space (reaching all-zero) united with its
+	// translates by each uniform board c*all-ones (reaching all-c). Those k sets
+	// coincide exactly when all-ones is itself in the column space; otherwise, k being
+	// prime, they're pairwise disjoint and multiply it by k. A target board has just
+	// the one translate, the same size as the column space.
+	uniform := spec.Target == TargetUniform
+	fraction := math.Pow(k, float64(ls.matrixRank()-n))
+	if uniform && !ls.reachesAllOn() {
+		fraction *= k
+	}
+
+	return Analysis{
+		Spec:             spec,
+		Rank:             ls.matrixRank(),
+		QuietPatterns:    ls.quietPresses(),
+		SolvableFraction: fraction,
+		Degenerate:       degenerate(ls, uniform),
+	}
+}
+
+// Analysis returns Analyze for g's own configuration.
+func (g *Grid) Analysis() Analysis {
+	return Analyze(g.Spec())
+}
+
+// CheckConfig rejects a config with an unknown default Lattice, Topology, Difficulty or
+// Target, or unsupported default States, or whose default board -- or any of whose graph
+// boards -- is too big for its States, Target picture or LitOnly rule (see
+// Spec.Validate) or structurally degenerate (see Analysis.Degenerate).
+// It's meant to be passed to utils.ParseJSONConfig as an extra check, since utils
+// can't import grid itself without an import cycle.
+func CheckConfig(config *utils.Config) error {
+	lattice, err := ParseLattice(config.Lattice)
+	if err != nil {
+		return fmt.Errorf("'Lattice' must be one of %v, got %q", Lattices, config.Lattice)
+	}
+
+	topology, err := ParseTopology(config.Topology)
+	if err != nil {
+		return fmt.Errorf("'Topology' must be one of %v, got %q", Topologies, config.Topology)
+	}
+
+	if _, err := ParseDifficulty(config.Difficulty); err != nil {
+		return fmt.Errorf("'Difficulty' must be one of %v, got %q", Difficulties[1:], config.Difficulty)
+	}
+
+	target, err := ParseTarget(config.Target)
+	if err != nil {
+		return fmt.Errorf("'Target' must be empty or one of %v, got %q", Targets[1:], config.Target)
+	}
+	if _, picture := target.picture(); picture && config.Graph != "" {
+		return fmt.Errorf("'Target' picture %q can't be drawn on the default 'Graph' board %q", target, config.Graph)
+	}
+
+	if config.States != 0 && !slices.Contains(SupportedStates, config.States) {
+		return fmt.Errorf("'States' must be one of %v, got %d", SupportedStates, config.States)
+	}
+
+	// A graph is a fixed board, unlike a lattice whose size and patterns players pick,
+	// so one that could never be dealt unsolved is a mistake in the graphs file, and
+	// caught here rather than only once someone picks it. Only the default graph is
+	// held to LitOnly: the others are just as playable without it.
+	for i := range config.Graphs {
+		spec := Spec{Graph: &config.Graphs[i], States: config.States, LitOnly: config.LitOnly && config.Graphs[i].Name == config.Graph}
+		if err := spec.Validate(); err != nil {
+			return fmt.Errorf("'Graphs' entry %q: %w", config.Graphs[i].Name, err)
+		}
+		if Analyze(spec).Degenerate {
+			return fmt.Errorf("'Graphs' entry %q with %d 'States' is degenerate: every reachable board is already won",
+				config.Graphs[i].Name, spec.NumStates())
+		}
+	}
+
+	neighborhood := utils.BuildNeighborhoodFromConfig(config)
+	spec := Spec{Rows: config.Rows, Cols: config.Cols, Lattice: lattice, Neighborhood: neighborhood, Topology: topology, States: config.States, Target: target, LitOnly: config.LitOnly}
+	if err := spec.Validate(); err != nil {
+		return fmt.Errorf("'Rows' x 'Cols' %dx%d: %w", config.Rows, config.Cols, err)
+	}
+	if Analyze(spec).Degenerate {
+		return fmt.Errorf("'Rows' x 'Cols' %dx%d %s %s board with %d 'States' and 'ToggleSequence' patterns %v is degenerate: every reachable board is already won",
+			config.Rows, config.Cols, topology, lattice, spec.NumStates(), patternNames(neighborhood))
+	}
+	return nil
+}
+
+func patternNames(patterns []Pattern) []string {
+	names := make([]string, len(patterns))
+	for i, p := range patterns {
+		names[i] = p.Name
+	}
+	return names
+}
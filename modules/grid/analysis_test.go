@@ -0,0 +1,380 @@
+package grid
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"testing"
+
+	utils "goSwitch/modules/utils"
+)
+
+// bruteForceSolvableFraction counts every board from which some press list wins, by
+// collecting the effect of every press-count combination -- only feasible for tiny
+// boards.
+func bruteForceSolvableFraction(spec Spec) float64 {
+	rows, cols := spec.size()
+	n := rows * cols
+	k := spec.NumStates()
+	total := 1
+	for range n {
+		total *= k
+	}
+
+	reachable := make(map[string]bool)
+	for code := 0; code < total; code++ {
+		g := &Grid{Rows: rows, Cols: cols, lattice: spec.Lattice, neighborhood: spec.Neighborhood, topology: spec.Topology, states: spec.States, graph: spec.Graph, board: newBoardState(n, spec.NumStates())}
+		for pos, rest := 0, code; pos < n; pos, rest = pos+1, rest/k {
+			for range rest % k {
+				g.Switch(pos)
+			}
+		}
+		// Pressing the same combination from the board c - effect reaches all-c.
+		for c := range k {
+			board := make([]int, n)
+			for i, e := range g.board.cells() {
+				board[i] = ((c-e)%k + k) % k
+			}
+			reachable[fmt.Sprint(board)] = true
+		}
+	}
+	return float64(len(reachable)) / float64(total)
+}
+
+func TestAnalyzeMatchesBruteForce(t *testing.T) {
+	for _, size := range [][2]int{{1, 1}, {2, 2}, {3, 3}, {2, 3}, {3, 2}, {1, 4}, {2, 5}} {
+		rows, cols := size[0], size[1]
+		for _, neighborhood := range [][]Pattern{classic(0), classic(4), classic(8), classic(0, 4), classic(4, 8), classic(0, 4, 8)} {
+			for _, topology := range Topologies {
+				spec := Spec{Rows: rows, Cols: cols, Neighborhood: neighborhood, Topology: topology}
+				a := Analyze(spec)
+
+				if a.Rank+a.Nullity() != rows*cols {
+					t.Fatalf("%+v: rank %d + nullity %d != %d", spec, a.Rank, a.Nullity(), rows*cols)
+				}
+				if want := bruteForceSolvableFraction(spec); a.SolvableFraction != want {
+					t.Fatalf("%+v: SolvableFraction = %v, brute force = %v", spec, a.SolvableFraction, want)
+				}
+
+				for _, quiet := range a.QuietPatterns {
+					g := NewGrid(spec)
+					before := g.board.cells()
+					for _, pos := range quiet {
+						g.Switch(pos)
+					}
+					assertGrid(t, g, before)
+				}
+			}
+		}
+	}
+}
+
+func TestAnalyzeMultiStateMatchesBruteForce(t *testing.T) {
+	for _, size := range [][2]int{{1, 1}, {2, 2}, {2, 3}, {1, 4}} {
+		for _, states := range []int{3, 5} {
+			for _, neighborhood := range [][]Pattern{classic(0), classic(4), classic(0, 4), classic(0, 4, 8)} {
+				for _, topology := range Topologies {
+					spec := Spec{Rows: size[0], Cols: size[1], Neighborhood: neighborhood, Topology: topology, States: states}
+					a := Analyze(spec)
+
+					if a.Rank+a.Nullity() != size[0]*size[1] {
+						t.Fatalf("%+v: rank %d + nullity %d != %d", spec, a.Rank, a.Nullity(), size[0]*size[1])
+					}
+					if want := bruteForceSolvableFraction(spec); math.Abs(a.SolvableFraction-want) > 1e-12 {
+						t.Fatalf("%+v: SolvableFraction = %v, brute force = %v", spec, a.SolvableFraction, want)
+					}
+
+					for _, quiet := range a.QuietPatterns {
+						g := NewGrid(spec)
+						before := g.board.cells()
+						for _, pos := range quiet {
+							g.Switch(pos)
+						}
+						assertGrid(t, g, before)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestAnalyzeHexMatchesBruteForce(t *testing.T) {
+	for _, size := range [][2]int{{2, 2}, {3, 3}, {2, 4}, {4, 2}} {
+		for _, neighborhood := range [][]Pattern{{hexPattern}, append(classic(0), hexPattern)} {
+			for _, topology := range Topologies {
+				for _, states := range []int{2, 3} {
+					if states > 2 && size[0]*size[1] > 8 || topology == TopologyTorus && size[0]%2 != 0 {
+						continue
+					}
+					spec := Spec{Rows: size[0], Cols: size[1], Lattice: LatticeHex, Neighborhood: neighborhood, Topology: topology, States: states}
+					a := Analyze(spec)
+
+					if want := bruteForceSolvableFraction(spec); math.Abs(a.SolvableFraction-want) > 1e-12 {
+						t.Fatalf("%+v: SolvableFraction = %v, brute force = %v", spec, a.SolvableFraction, want)
+					}
+
+					for _, quiet := range a.QuietPatterns {
+						g := NewGrid(spec)
+						before := g.board.cells()
+						for _, pos := range quiet {
+							g.Switch(pos)
+						}
+						assertGrid(t, g, before)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestAnalyzeGraphMatchesBruteForce(t *testing.T) {
+	for _, graph := range []*Graph{pathGraph(5), cycleGraph(6), cycleGraph(7), petersenGraph()} {
+		for _, states := range []int{2, 3} {
+			if states > 2 && graph.Nodes > 7 {
+				continue
+			}
+			spec := Spec{Graph: graph, States: states}
+			a := Analyze(spec)
+
+			if a.Rank+a.Nullity() != graph.Nodes {
+				t.Fatalf("%s: rank %d + nullity %d != %d", graph.Name, a.Rank, a.Nullity(), graph.Nodes)
+			}
+			if want := bruteForceSolvableFraction(spec); math.Abs(a.SolvableFraction-want) > 1e-12 {
+				t.Fatalf("%s, %d states: SolvableFraction = %v, brute force = %v", graph.Name, states, a.SolvableFraction, want)
+			}
+		}
+	}
+}
+
+// TestAnalyzeClassicLightsOut pins the well-known numbers for the 5x5 plus-shaped
+// game: two independent quiet patterns, so a quarter of all boards are solvable.
+func TestAnalyzeClassicLightsOut(t *testing.T) {
+	a := Analyze(Spec{Rows: 5, Cols: 5, Neighborhood: classic(0, 4)})
+
+	if a.Rank != 23 || a.Nullity() != 2 {
+		t.Fatalf("Analyze(5x5 {0,4}) rank/nullity = %d/%d, want 23/2", a.Rank, a.Nullity())
+	}
+	if a.SolvableFraction != 0.25 {
+		t.Fatalf("Analyze(5x5 {0,4}).SolvableFraction = %v, want 0.25", a.SolvableFraction)
+	}
+	if a.Degenerate {
+		t.Fatal("Analyze(5x5 {0,4}) reported degenerate")
+	}
+}
+
+// TestAnalyzeConcurrentFirstUse checks callers racing to analyze a configuration no one
+// has used yet all get the same answer, built once outside the cache lock.
+func TestAnalyzeConcurrentFirstUse(t *testing.T) {
+	spec := Spec{Rows: 7, Cols: 9, Neighborhood: classic(0, 4), Topology: TopologyTorus}
+
+	var wg sync.WaitGroup
+	results := make([]Analysis, 8)
+	for i := range results {
+		wg.Go(func() { results[i] = Analyze(spec) })
+	}
+	wg.Wait()
+
+	for i, a := range results {
+		if a.Rank != results[0].Rank || a.Nullity() != results[0].Nullity() {
+			t.Fatalf("Analyze #%d rank/nullity = %d/%d, want %d/%d", i, a.Rank, a.Nullity(), results[0].Rank, results[0].Nullity())
+		}
+		if cachedSystem(spec).matrixRank() != a.Rank {
+			t.Fatalf("Analyze #%d rank = %d, but the cached system's is %d", i, a.Rank, cachedSystem(spec).matrixRank())
+		}
+	}
+}
+
+func TestAnalyzeDegenerate(t *testing.T) {
+	tests := []struct {
+		name         string
+		dim          int
+		neighborhood []Pattern
+		topology     Topology
+		want         bool
+	}{
+		{"2x2 every pattern flips every cell", 2, classic(0, 4, 8), TopologyBounded, true},
+		{"no patterns at all", 3, classic(), TopologyBounded, true},
+		{"single cell", 1, classic(0), TopologyBounded, true},
+		{"duplicate patterns cancel out", 3, classic(4, 4), TopologyBounded, true},
+		{"classic 3x3", 3, classic(0, 4), TopologyBounded, false},
+		{"2x2 self only", 2, classic(0), TopologyBounded, false},
+		{"2x2 plus", 2, classic(4), TopologyBounded, false},
+		{"2x2 torus plus wraps each offset onto its opposite", 2, classic(4), TopologyTorus, true},
+		{"3x3 torus classic", 3, classic(0, 4), TopologyTorus, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := Spec{Rows: tt.dim, Cols: tt.dim, Neighborhood: tt.neighborhood, Topology: tt.topology}
+			if got := Analyze(spec).Degenerate; got != tt.want {
+				t.Errorf("Analyze(%+v).Degenerate = %v, want %v", spec, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestAnalyzeDegenerateWithTarget checks a configuration that can only advance the
+// whole board uniformly is degenerate for uniform wins alone: played to a target board,
+// that uniform step moves away from the win, so it's a real (if short) puzzle.
+func TestAnalyzeDegenerateWithTarget(t *testing.T) {
+	spec := Spec{Rows: 2, Cols: 2, Neighborhood: classic(0, 4, 8)}
+	if a := Analyze(spec); !a.Degenerate {
+		t.Fatalf("Analyze(2x2 {0,4,8}) = %+v, want degenerate", a)
+	}
+
+	spec.Target = TargetRandom
+	if a := Analyze(spec); a.Degenerate {
+		t.Fatalf("Analyze(2x2 {0,4,8} random target) = %+v, want playable", a)
+	}
+
+	g := NewGridFromSeed(spec, 3)
+	moves, ok := g.Solve()
+	if g.CheckWin() || !ok || len(moves) == 0 || g.GetPossibleSolution() == nil {
+		t.Fatalf("NewGridFromSeed(2x2 {0,4,8} random target) dealt %v, solution %v, %v; want a real deal", g.board.cells(), moves, ok)
+	}
+
+	spec.Neighborhood = classic()
+	if a := Analyze(spec); !a.Degenerate {
+		t.Fatalf("Analyze(2x2 {} random target) = %+v, want degenerate: nothing moves", a)
+	}
+}
+
+// TestAnalyzeSolvableFractionWithTarget checks a target board counts only the boards
+// that reach it, not those reaching any uniform board: on a 3x3 {4} board, where
+// all-ones isn't in the column space, that's half as many.
+func TestAnalyzeSolvableFractionWithTarget(t *testing.T) {
+	spec := Spec{Rows: 3, Cols: 3, Neighborhood: classic(4)}
+	uniform := Analyze(spec)
+
+	spec.Target = TargetRandom
+	target := Analyze(spec)
+	if want := math.Pow(2, float64(target.Rank-9)); target.SolvableFraction != want || uniform.SolvableFraction != 2*want {
+		t.Fatalf("SolvableFraction = %v uniform, %v to a target; want %v and %v", uniform.SolvableFraction, target.SolvableFraction, 2*want, want)
+	}
+}
+
+func TestDefaultSpec(t *testing.T) {
+	config := &utils.Config{
+		Rows:           4,
+		Cols:           5,
+		Lattice:        "hex",
+		Topology:       "torus",
+		ToggleSequence: []bool{true, false, true},
+		Patterns:       classic(0, 4, 8),
+		Graphs:         []Graph{*cycleGraph(5)},
+	}
+
+	spec := DefaultSpec(config)
+	if spec.Rows != 4 || spec.Cols != 5 || spec.Lattice != LatticeHex || spec.Topology != TopologyTorus || spec.States != 2 || spec.Graph != nil {
+		t.Fatalf("DefaultSpec() = %+v, want a 4x5 two-state hex torus", spec)
+	}
+	if names := patternNames(spec.Neighborhood); fmt.Sprint(names) != "[0 8]" {
+		t.Fatalf("DefaultSpec() patterns = %v, want [0 8]", names)
+	}
+
+	config.Graph, config.Lattice, config.Topology = "cycle5", "", "klein"
+	spec = DefaultSpec(config)
+	if spec.Graph != &config.Graphs[0] || spec.Lattice != LatticeSquare || spec.Topology != TopologyBounded {
+		t.Fatalf("DefaultSpec() = %+v, want the cycle5 graph over a square, bounded default", spec)
+	}
+}
+
+func TestCheckConfig(t *testing.T) {
+	config := &utils.Config{
+		Rows:           2,
+		Cols:           2,
+		ToggleSequence: []bool{true, true, true},
+		Patterns:       classic(0, 4, 8),
+	}
+	if err := CheckConfig(config); err == nil {
+		t.Fatal("CheckConfig() accepted a degenerate 2x2 {0,4,8} default board")
+	}
+
+	config.Target = string(TargetRandom)
+	if err := CheckConfig(config); err != nil {
+		t.Fatalf("CheckConfig() rejected a 2x2 {0,4,8} default board played to a random target: %v", err)
+	}
+	config.Target = ""
+
+	config.ToggleSequence = []bool{true, true, false}
+	if err := CheckConfig(config); err != nil {
+		t.Fatalf("CheckConfig() rejected a playable 2x2 {0,4} default board: %v", err)
+	}
+
+	config.Topology = "klein"
+	if err := CheckConfig(config); err == nil {
+		t.Fatal("CheckConfig() accepted an unknown topology")
+	}
+
+	config.Topology, config.ToggleSequence = "torus", []bool{false, true, false}
+	if err := CheckConfig(config); err == nil {
+		t.Fatal("CheckConfig() accepted a degenerate 2x2 torus {4} default board")
+	}
+
+	config.Rows, config.Cols, config.Lattice, config.ToggleSequence = 3, 4, "hex", []bool{true, true, false}
+	if err := CheckConfig(config); err == nil {
+		t.Fatal("CheckConfig() accepted a 3x4 hex torus default board")
+	}
+	config.Rows, config.Cols = 2, 2
+
+	config.Topology = ""
+	config.Lattice = "triangle"
+	if err := CheckConfig(config); err == nil {
+		t.Fatal("CheckConfig() accepted an unknown lattice")
+	}
+
+	config.Lattice, config.Difficulty = "", "brutal"
+	if err := CheckConfig(config); err == nil {
+		t.Fatal("CheckConfig() accepted an unknown difficulty")
+	}
+
+	config.Difficulty = "hard"
+	config.Target = "spade"
+	if err := CheckConfig(config); err == nil {
+		t.Fatal("CheckConfig() accepted an unknown target")
+	}
+
+	config.Target = "heart"
+	if err := CheckConfig(config); err == nil {
+		t.Fatal("CheckConfig() accepted a picture target too big for the 2x2 default board")
+	}
+
+	config.Target = "random"
+	config.LitOnly, config.States = true, 3
+	if err := CheckConfig(config); err == nil {
+		t.Fatal("CheckConfig() accepted a lit-only default board with 3 states")
+	}
+
+	config.LitOnly, config.States = false, 2
+	config.Lattice = "hex"
+	if err := CheckConfig(config); err != nil {
+		t.Fatalf("CheckConfig() rejected a playable 2x2 hex {0,4} default board: %v", err)
+	}
+
+	config.Lattice = ""
+	config.Graphs = []Graph{*cycleGraph(3)}
+	if err := CheckConfig(config); err == nil {
+		t.Fatal("CheckConfig() accepted a degenerate triangle graph, where every press flips every node")
+	}
+
+	config.Graphs = []Graph{*cycleGraph(5)}
+	if err := CheckConfig(config); err != nil {
+		t.Fatalf("CheckConfig() rejected a playable 5-cycle graph: %v", err)
+	}
+
+	config.Graphs = nil
+	config.States = 4
+	if err := CheckConfig(config); err == nil {
+		t.Fatal("CheckConfig() accepted a non-prime number of states")
+	}
+
+	config.States = 3
+	if err := CheckConfig(config); err != nil {
+		t.Fatalf("CheckConfig() rejected a playable 2x2 {0,4} 3-state default board: %v", err)
+	}
+
+	config.Rows, config.Cols = 20, 20
+	if err := CheckConfig(config); err == nil {
+		t.Fatal("CheckConfig() accepted a 3-state default board over MaxMultiStateCells")
+	}
+}
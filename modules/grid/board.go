@@ -0,0 +1,117 @@
+package grid
+
+import "slices"
+
+// boardState is a board's cells, stored the way its number of states calls for. On
+// the classic two-state board, lit packs cell i into bit i, so a press is an XOR with
+// that press's precomputed mask (see toggleTable) and checking for a win is a
+// popcount; with more states each cell needs a whole value, so values holds one per
+// cell instead. Exactly one of the two is set.
+type boardState struct {
+	n      int
+	lit    bitVec
+	values []int
+}
+
+// newBoardState returns an all-zero board of n cells with k states each.
+func newBoardState(n, k int) boardState {
+	if k == 2 {
+		return boardState{n: n, lit: newBitVec(n)}
+	}
+	return boardState{n: n, values: make([]int, n)}
+}
+
+// boardFrom returns a board holding cells, each already in [0, k).
+func boardFrom(cells []int, k int) boardState {
+	b := newBoardState(len(cells), k)
+	for pos, val := range cells {
+		if b.lit == nil {
+			b.values[pos] = val
+		} else if val == 1 {
+			b.lit.set(pos)
+		}
+	}
+	return b
+}
+
+// clone returns an independent copy of b.
+func (b boardState) clone() boardState {
+	return boardState{n: b.n, lit: b.lit.clone(), values: append([]int(nil), b.values...)}
+}
+
+func (b boardState) get(pos int) int {
+	if b.lit == nil {
+		return b.values[pos]
+	}
+	if b.lit.get(pos) {
+		return 1
+	}
+	return 0
+}
+
+// cells returns a copy of every cell's state, in flat position order.
+func (b boardState) cells() []int {
+	out := make([]int, b.n)
+	for pos := range out {
+		out[pos] = b.get(pos)
+	}
+	return out
+}
+
+// fill sets every cell to v.
+func (b boardState) fill(v int) {
+	if b.lit == nil {
+		for pos := range b.values {
+			b.values[pos] = v
+		}
+		return
+	}
+	if v == 0 {
+		clear(b.lit)
+	} else {
+		copy(b.lit, onesVec(b.n))
+	}
+}
+
+// bump advances the single cell pos by one state, outside of any press.
+func (b boardState) bump(pos, k int) {
+	if b.lit == nil {
+		b.values[pos] = (b.values[pos] + 1) % k
+		return
+	}
+	b.lit.flip(pos)
+}
+
+// press applies pressing pos step times, for step 1 (Switch) or -1 (Unswitch): on two
+// states both are the same single XOR.
+func (b boardState) press(t *toggleTable, pos, step, k int) {
+	if b.lit != nil {
+		b.lit.xor(t.masks[pos])
+		return
+	}
+	for _, p := range t.affected[pos] {
+		b.values[p] = (b.values[p] + step + k) % k
+	}
+}
+
+// equal reports whether b and o hold the same cells.
+func (b boardState) equal(o boardState) bool {
+	if b.lit != nil {
+		return slices.Equal(b.lit, o.lit)
+	}
+	return slices.Equal(b.values, o.values)
+}
+
+// uniform reports whether every cell is in the same state.
+func (b boardState) uniform() bool {
+	if b.lit != nil {
+		count := b.lit.onesCount()
+		return count == 0 || count == b.n
+	}
+	for _, val := range b.values {
+		if val != b.values[0] {
+			return false
+		}
+	}
+	return true
+}
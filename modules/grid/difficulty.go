@@ -0,0 +1,155 @@
+package grid
+
+import (
+	"fmt"
+	"math"
+)
+
+// Difficulty is a band of Rating scores a board can be asked to be dealt in.
+type Difficulty string
+
+const (
+	// DifficultyAny deals whatever initGame's scramble produces, as before difficulty
+	// existed.
+	DifficultyAny Difficulty = ""
+	// DifficultyEasy, DifficultyMedium and DifficultyHard split the score range into
+	// the bands Rating.Difficulty reports (see difficultyBands).
+	DifficultyEasy   Difficulty = "easy"
+	DifficultyMedium Difficulty = "medium"
+	DifficultyHard   Difficulty = "hard"
+)
+
+// Difficulties lists every Difficulty, in the order the configuration panel offers
+// them.
+var Difficulties = []Difficulty{DifficultyAny, DifficultyEasy, DifficultyMedium, DifficultyHard}
+
+// ParseDifficulty returns the Difficulty named s; the empty string is DifficultyAny.
+func ParseDifficulty(s string) (Difficulty, error) {
+	for _, d := range Difficulties {
+		if string(d) == s {
+			return d, nil
+		}
+	}
+	return "", fmt.Errorf("unknown difficulty %q", s)
+}
+
+// String is the Difficulty's name, with DifficultyAny spelled out for display.
+func (d Difficulty) String() string {
+	if d == DifficultyAny {
+		return "any"
+	}
+	return string(d)
+}
+
+// difficultyBands is the score range of each band, [low, high). They're tuned so a
+// freshly scrambled board of any reasonable size lands in each one often enough that
+// NewGrid rarely needs more than a handful of redeals to hit the one asked for.
+var difficultyBands = map[Difficulty][2]float64{
+	DifficultyEasy:   {0, 0.35},
+	DifficultyMedium: {0.35, 0.55},
+	DifficultyHard:   {0.55, math.Inf(1)},
+}
+
+// contains reports whether score is in d's band; DifficultyAny's band is everything.
+func (d Difficulty) contains(score float64) bool {
+	band, ok := difficultyBands[d]
+	return !ok || (band[0] <= score && score < band[1])
+}
+
+// distance returns how far score is from d's band, for picking the closest board when
+// none lands inside it.
+func (d Difficulty) distance(score float64) float64 {
+	if d.contains(score) {
+		return 0
+	}
+	band := difficultyBands[d]
+	return math.Min(math.Abs(score-band[0]), math.Abs(score-band[1]))
+}
+
+// Rating is how hard a board is to solve from scratch, from three signals:
+//
+//   - Par, the fewest presses that win (OptimalSolution's length): more presses is
+//     more to find.
+//   - NonLocality, the share of those presses on cells already showing the state the
+//     board is won in. A press on a cell that's visibly wrong is the obvious move;
+//     one on a cell that looks done is only found by thinking ahead.
+//   - Ambiguity, the number of quiet patterns: a fixed property of the configuration,
+//     where every one doubles (or multiplies by k) the press sets with the same effect,
+//     so reasoning backwards from the board no longer pins down a single answer.
+//
+// Score folds them into [0, 1], weighted towards Par: each is first scaled to [0, 1] --
+// Par against the average press count of a random press set, (k-1)/2 per cell, or the
+// longest a LitOnly deal can be if that's fewer, and Ambiguity as nullity / (nullity +
+// 2) -- then weighted 0.5, 0.3 and 0.2.
+type Rating struct {
+	Par         int
+	NonLocality float64
+	Ambiguity   int
+	Score       float64
+	// Difficulty is the band Score falls in; never DifficultyAny.
+	Difficulty Difficulty
+	// Estimated is set on a board with more than maxRatedCells cells, rated from
+	// Solve's press list rather than OptimalSolution's: its Par is only an upper bound.
+	Estimated bool
+}
+
+// rate works out g's Rating for its board as it stands. A board no press list wins
+// -- only NewGrid's degenerate fallback -- rates as zero.
+func (g *Grid) rate() Rating {
+	estimated := g.board.n > maxRatedCells
+	solve := g.OptimalSolution
+	if estimated {
+		solve = g.Solve
+	}
+	moves, ok := solve()
+	if !ok {
+		return Rating{Difficulty: DifficultyEasy, Estimated: estimated}
+	}
+
+	n, k := g.board.n, g.modulus()
+	before := g.board.cells()
+
+	// The board it's won as is whatever pressing the solution leaves it as: the target
+	// picture, or whichever uniform board was cheapest.
+	after := append([]int(nil), before...)
+	table := g.toggles()
+	for _, pos := range moves {
+		for _, p := range table.affected[pos] {
+			after[p] = (after[p] + 1) % k
+		}
+	}
+
+	r := Rating{Par: len(moves), Ambiguity: n - g.system().matrixRank(), Estimated: estimated}
+	if r.Par > 0 {
+		done := 0
+		for _, pos := range moves {
+			if before[pos] == after[pos] {
+				done++
+			}
+		}
+		r.NonLocality = float64(done) / float64(r.Par)
+	}
+
+	// A lit-only deal is never more than maxLitOnlyScramble presses from a win, so
+	// that's what its par is measured against, if it's the lower of the two.
+	parScale := float64(n) * float64(k-1) / 2
+	if g.litOnly {
+		parScale = math.Min(parScale, maxLitOnlyScramble)
+	}
+	par := math.Min(1, float64(r.Par)/parScale)
+	ambiguity := float64(r.Ambiguity) / float64(r.Ambiguity+2)
+	r.Score = 0.5*par + 0.3*r.NonLocality + 0.2*ambiguity
+
+	for _, d := range Difficulties[1:] {
+		if d.contains(r.Score) {
+			r.Difficulty = d
+		}
+	}
+	return r
+}
+
+// Rating returns how hard g's board was as dealt, before any moves: unlike Solve and
+// its kin, it describes the puzzle, not the current position.
+func (g *Grid) Rating() Rating {
+	return g.rating
+}
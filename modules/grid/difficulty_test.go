@@ -0,0 +1,87 @@
+package grid
+
+import (
+	"testing"
+)
+
+// TestNewGridHitsDifficulty checks a board that can reach every band is dealt in
+// whichever one is asked for, and that its Rating describes the board as dealt.
+func TestNewGridHitsDifficulty(t *testing.T) {
+	for _, d := range Difficulties[1:] {
+		spec := Spec{Rows: 5, Cols: 5, Neighborhood: classic(0, 4), Difficulty: d}
+		for seed := range int64(10) {
+			g := NewGridFromSeed(spec, seed)
+			r := g.Rating()
+			if r.Difficulty != d || !d.contains(r.Score) {
+				t.Fatalf("%s seed %d: dealt a %s board (score %.2f)", d, seed, r.Difficulty, r.Score)
+			}
+			if moves, _ := g.OptimalSolution(); len(moves) != r.Par {
+				t.Fatalf("%s seed %d: Rating().Par = %d, want %d", d, seed, r.Par, len(moves))
+			}
+			if g.Spec().Difficulty != d {
+				t.Fatalf("Spec().Difficulty = %q, want %q", g.Spec().Difficulty, d)
+			}
+		}
+	}
+}
+
+// TestRatingDescribesDeal checks Rating stays the board's as dealt once it's played.
+func TestRatingDescribesDeal(t *testing.T) {
+	g := NewGridFromSeed(Spec{Rows: 4, Cols: 4, Neighborhood: classic(0, 4)}, 3)
+	before := g.Rating()
+	moves, _ := g.OptimalSolution()
+	g.Switch(moves[0])
+	if g.Rating() != before {
+		t.Fatalf("Rating() changed after a move: %+v, was %+v", g.Rating(), before)
+	}
+}
+
+// TestNewGridUnreachableDifficulty checks a band the configuration can't reach still
+// deals a board -- the closest one found -- rather than failing or spinning.
+func TestNewGridUnreachableDifficulty(t *testing.T) {
+	g := NewGridFromSeed(Spec{Rows: 2, Cols: 2, Neighborhood: classic(0, 4), Difficulty: DifficultyHard}, 1)
+	if g.CheckWin() {
+		t.Fatal("NewGridFromSeed() dealt a won board")
+	}
+	if r := g.Rating(); r.Difficulty == "" || r.Score < 0 || r.Score > 1 {
+		t.Fatalf("Rating() = %+v, want a score in [0, 1] and a band", r)
+	}
+}
+
+// TestNewGridSkipsRedealsOnLargeBoards checks a board past maxRatedCells isn't redealt
+// for a difficulty at all -- it deals what DifficultyAny would from the same seed --
+// and is rated from Solve, flagged as an estimate.
+func TestNewGridSkipsRedealsOnLargeBoards(t *testing.T) {
+	spec := Spec{Rows: 21, Cols: 21, Neighborhood: classic(0, 4)}
+	dealt := NewGridFromSeed(spec, 7)
+	if dealt.board.n <= maxRatedCells {
+		t.Fatalf("%d cells, want more than maxRatedCells for the test to mean anything", dealt.board.n)
+	}
+
+	r := dealt.Rating()
+	solution, ok := dealt.Solve()
+	if !ok || !r.Estimated || r.Par != len(solution) {
+		t.Fatalf("Rating() = %+v, want Estimated with Par %d from Solve", r, len(solution))
+	}
+
+	for _, d := range Difficulties[1:] {
+		spec.Difficulty = d
+		if g := NewGridFromSeed(spec, 7); !g.board.equal(dealt.board) {
+			t.Fatalf("%s: NewGridFromSeed() redealt a board too big to rate", d)
+		}
+	}
+}
+
+func TestParseDifficulty(t *testing.T) {
+	for in, want := range map[string]Difficulty{"": DifficultyAny, "easy": DifficultyEasy, "medium": DifficultyMedium, "hard": DifficultyHard} {
+		if got, err := ParseDifficulty(in); err != nil || got != want {
+			t.Errorf("ParseDifficulty(%q) = %q, %v, want %q, nil", in, got, err, want)
+		}
+	}
+	if _, err := ParseDifficulty("brutal"); err == nil {
+		t.Error("ParseDifficulty(\"brutal\") accepted an unknown difficulty")
+	}
+	if DifficultyAny.String() != "any" {
+		t.Errorf("DifficultyAny.String() = %q, want \"any\"", DifficultyAny.String())
+	}
+}
@@ -0,0 +1,520 @@
+package grid
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"slices"
+
+	utils "goSwitch/modules/utils"
+)
+
+// encodingVersion is the version of both of Grid's encodings. Bump it whenever what
+// they carry changes; decoding rejects any other version rather than guess at it.
+const encodingVersion = 1
+
+// binaryMagic opens every MarshalBinary encoding, ahead of its version byte, so
+// decoding something else entirely fails up front.
+var binaryMagic = []byte("gS")
+
+// encodedGrid is everything a Grid is, as both its encodings carry it: its rules in
+// full -- the neighborhood's offsets and the graph's edges, not just their names, so a
+// grid decodes the same whatever config it's decoded under -- and its state: the board,
+// as it was dealt and as it stands, the target, the dealt solution, and the move log
+// with how far into it the player has undone. The net-effect history is rebuilt from
+// the log, and the toggle table and solver from the rules.
+type encodedGrid struct {
+	Version      int
+	Rows         int
+	Cols         int
+	Lattice      Lattice
+	Topology     Topology
+	States       int
+	Neighborhood []Pattern
+	Graph        *Graph `json:",omitempty"`
+	Difficulty   Difficulty
+	Target       Target
+	LitOnly      bool
+	Seed         int64
+	Board        []int
+	Initial      []int
+	TargetBoard  []int `json:",omitempty"`
+	Solution     []int
+	MoveLog      []int
+	LogCursor    int
+	Rating       Rating
+}
+
+func (g *Grid) encode() encodedGrid {
+	e := encodedGrid{
+		Version:      encodingVersion,
+		Rows:         g.Rows,
+		Cols:         g.Cols,
+		Lattice:      g.lattice,
+		Topology:     g.topology,
+		States:       g.states,
+		Neighborhood: g.neighborhood,
+		Graph:        g.graph,
+		Difficulty:   g.difficulty,
+		Target:       g.target,
+		LitOnly:      g.litOnly,
+		Seed:         g.seed,
+		Board:        g.board.cells(),
+		Initial:      g.initial.cells(),
+		Solution:     g.solution,
+		MoveLog:      g.moveLog,
+		LogCursor:    g.logCursor,
+		Rating:       g.rating,
+	}
+	if g.targetBoard != nil {
+		e.TargetBoard = g.targetBoard.cells()
+	}
+	return e
+}
+
+// decode checks e describes a grid that could have been dealt and played -- valid
+// rules, cells in range, and the move log, replayed on the dealt board, landing on the
+// board as it stands -- and builds it.
+func (e encodedGrid) decode() (*Grid, error) {
+	if e.Version != encodingVersion {
+		return nil, fmt.Errorf("encoding version %d isn't supported (want %d)", e.Version, encodingVersion)
+	}
+
+	// The lattice and topology are checked but kept as they came, empty for the
+	// default, so a decoded Grid's Spec is the very one it was dealt from.
+	spec := Spec{Rows: e.Rows, Cols: e.Cols, Lattice: e.Lattice, Topology: e.Topology, Neighborhood: e.Neighborhood, States: e.States, Graph: e.Graph, LitOnly: e.LitOnly}
+	if _, err := ParseLattice(string(e.Lattice)); err != nil {
+		return nil, err
+	}
+	if _, err := ParseTopology(string(e.Topology)); err != nil {
+		return nil, err
+	}
+	var err error
+	if spec.Difficulty, err = ParseDifficulty(string(e.Difficulty)); err != nil {
+		return nil, err
+	}
+	if spec.Target, err = ParseTarget(string(e.Target)); err != nil {
+		return nil, err
+	}
+	if e.States != 0 && !slices.Contains(SupportedStates, e.States) {
+		return nil, fmt.Errorf("%d states isn't supported", e.States)
+	}
+	for _, pattern := range e.Neighborhood {
+		if err := utils.ValidatePattern(pattern); err != nil {
+			return nil, err
+		}
+	}
+	if e.Graph != nil {
+		if err := utils.ValidateGraph(*e.Graph); err != nil {
+			return nil, err
+		}
+		if e.Rows != 1 || e.Cols != e.Graph.Nodes {
+			return nil, fmt.Errorf("a %d-node graph board must be 1x%d, got %dx%d", e.Graph.Nodes, e.Graph.Nodes, e.Rows, e.Cols)
+		}
+	} else if e.Rows < 1 || e.Cols < 1 || e.Rows*e.Cols > utils.MaxBoardCells {
+		return nil, fmt.Errorf("a %dx%d board must have between 1 and %d cells", e.Rows, e.Cols, utils.MaxBoardCells)
+	}
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+	if _, err := ParseDifficulty(string(e.Rating.Difficulty)); err != nil || e.Rating.Par < 0 {
+		return nil, fmt.Errorf("rating %+v is invalid", e.Rating)
+	}
+
+	n, k := e.Rows*e.Cols, spec.NumStates()
+	board, err := decodeCells("board", e.Board, n, k)
+	if err != nil {
+		return nil, err
+	}
+	initial, err := decodeCells("initial board", e.Initial, n, k)
+	if err != nil {
+		return nil, err
+	}
+	var targetBoard *boardState
+	if (e.TargetBoard != nil) != (spec.Target != TargetUniform) {
+		return nil, fmt.Errorf("target %q doesn't match whether a target board is given", spec.Target)
+	}
+	if e.TargetBoard != nil {
+		target, err := decodeCells("target board", e.TargetBoard, n, k)
+		if err != nil {
+			return nil, err
+		}
+		targetBoard = &target
+	}
+	for _, pos := range slices.Concat(e.Solution, e.MoveLog) {
+		if pos < 0 || pos >= n {
+			return nil, fmt.Errorf("press %d is off the board", pos)
+		}
+	}
+	if e.LogCursor < 0 || e.LogCursor > len(e.MoveLog) {
+		return nil, fmt.Errorf("move log cursor %d is outside its %d moves", e.LogCursor, len(e.MoveLog))
+	}
+
+	g := &Grid{
+		Rows:         e.Rows,
+		Cols:         e.Cols,
+		lattice:      spec.Lattice,
+		neighborhood: slices.Clone(spec.Neighborhood),
+		topology:     spec.Topology,
+		states:       spec.States,
+		graph:        spec.Graph,
+		board:        initial.clone(),
+		initial:      initial,
+		solution:     slices.Clone(e.Solution),
+		moveLog:      slices.Clone(e.MoveLog),
+		logCursor:    e.LogCursor,
+		seed:         e.Seed,
+		rand:         rand.New(rand.NewSource(e.Seed)), //nolint:gosec // puzzle shuffling, not security-sensitive
+		difficulty:   spec.Difficulty,
+		rating:       e.Rating,
+		target:       spec.Target,
+		targetBoard:  targetBoard,
+		litOnly:      spec.LitOnly,
+	}
+	for i, pos := range g.moveLog[:g.logCursor] {
+		if !g.Switch(pos) {
+			return nil, fmt.Errorf("move %d presses %d, which can't be pressed then", i, pos)
+		}
+	}
+	if !g.board.equal(board) {
+		return nil, errors.New("the move log doesn't lead from the initial board to the board")
+	}
+	g.moveHistory = netMoves(g.moveLog[:g.logCursor], k)
+
+	return g, nil
+}
+
+// decodeCells checks cells is a board of n cells, each in [0, k), and returns it.
+func decodeCells(name string, cells []int, n, k int) (boardState, error) {
+	if len(cells) != n {
+		return boardState{}, fmt.Errorf("%s has %d cells, want %d", name, len(cells), n)
+	}
+	for pos, val := range cells {
+		if val < 0 || val >= k {
+			return boardState{}, fmt.Errorf("%s cell %d is %d, outside [0, %d)", name, pos, val, k)
+		}
+	}
+	return boardFrom(cells, k), nil
+}
+
+// MarshalJSON encodes g as a JSON object: its rules in full and everything played on
+// it, for UnmarshalJSON to restore exactly.
+func (g *Grid) MarshalJSON() ([]byte, error) {
+	return json.Marshal(g.encode())
+}
+
+// UnmarshalJSON replaces g with the grid MarshalJSON encoded in data, once it checks
+// out as one that could have been played (see encodedGrid.decode).
+func (g *Grid) UnmarshalJSON(data []byte) error {
+	var e encodedGrid
+	if err := json.Unmarshal(data, &e); err != nil {
+		return fmt.Errorf("grid: %w", err)
+	}
+	decoded, err := e.decode()
+	if err != nil {
+		return fmt.Errorf("grid: %w", err)
+	}
+	*g = *decoded
+	return nil
+}
+
+// MarshalBinary encodes g compactly: what MarshalJSON carries, as varints, with a
+// two-state board's cells packed eight to a byte. It opens with binaryMagic and the
+// encoding version.
+func (g *Grid) MarshalBinary() ([]byte, error) {
+	e := g.encode()
+	k := Spec{States: e.States}.NumStates()
+
+	w := binaryWriter{buf: append(slices.Clone(binaryMagic), encodingVersion)}
+	w.uint(e.Rows)
+	w.uint(e.Cols)
+	w.str(string(e.Lattice))
+	w.str(string(e.Topology))
+	w.uint(e.States)
+	w.uint(len(e.Neighborhood))
+	for _, pattern := range e.Neighborhood {
+		w.str(pattern.Name)
+		w.uint(len(pattern.Offsets))
+		for _, off := range pattern.Offsets {
+			w.int(off[0])
+			w.int(off[1])
+		}
+	}
+	w.bool(e.Graph != nil)
+	if e.Graph != nil {
+		w.str(e.Graph.Name)
+		w.uint(e.Graph.Nodes)
+		w.uint(len(e.Graph.Positions))
+		for _, p := range e.Graph.Positions {
+			w.float(p[0])
+			w.float(p[1])
+		}
+		w.uint(len(e.Graph.Edges))
+		for _, edge := range e.Graph.Edges {
+			w.uint(edge[0])
+			w.uint(edge[1])
+		}
+	}
+	w.str(string(e.Difficulty))
+	w.str(string(e.Target))
+	w.bool(e.LitOnly)
+	w.buf = binary.AppendVarint(w.buf, e.Seed)
+	w.cells(e.Board, k)
+	w.cells(e.Initial, k)
+	w.bool(e.TargetBoard != nil)
+	if e.TargetBoard != nil {
+		w.cells(e.TargetBoard, k)
+	}
+	w.ints(e.Solution)
+	w.ints(e.MoveLog)
+	w.uint(e.LogCursor)
+	w.uint(e.Rating.Par)
+	w.float(e.Rating.NonLocality)
+	w.uint(e.Rating.Ambiguity)
+	w.float(e.Rating.Score)
+	w.str(string(e.Rating.Difficulty))
+
+	return w.buf, nil
+}
+
+// UnmarshalBinary replaces g with the grid MarshalBinary encoded in data, with the same
+// checks as UnmarshalJSON.
+func (g *Grid) UnmarshalBinary(data []byte) error {
+	rest, ok := bytes.CutPrefix(data, binaryMagic)
+	if !ok || len(rest) == 0 {
+		return errors.New("grid: not a binary-encoded grid")
+	}
+
+	r := binaryReader{buf: rest[1:]}
+	e := encodedGrid{Version: int(rest[0])}
+	e.Rows = r.uint()
+	e.Cols = r.uint()
+	e.Lattice = Lattice(r.str())
+	e.Topology = Topology(r.str())
+	e.States = r.uint()
+	e.Neighborhood = make([]Pattern, r.count(2))
+	for i := range e.Neighborhood {
+		e.Neighborhood[i].Name = r.str()
+		e.Neighborhood[i].Offsets = make([][2]int, r.count(2))
+		for j := range e.Neighborhood[i].Offsets {
+			e.Neighborhood[i].Offsets[j] = [2]int{r.int(), r.int()}
+		}
+	}
+	if r.bool() {
+		e.Graph = &Graph{Name: r.str(), Nodes: r.uint()}
+		if positions := r.count(16); positions > 0 {
+			e.Graph.Positions = make([][2]float64, positions)
+			for i := range e.Graph.Positions {
+				e.Graph.Positions[i] = [2]float64{r.float(), r.float()}
+			}
+		}
+		e.Graph.Edges = make([][2]int, r.count(2))
+		for i := range e.Graph.Edges {
+			e.Graph.Edges[i] = [2]int{r.uint(), r.uint()}
+		}
+	}
+	e.Difficulty = Difficulty(r.str())
+	e.Target = Target(r.str())
+	e.LitOnly = r.bool()
+	e.Seed = r.varint()
+	n, k := e.Rows*e.Cols, Spec{States: e.States}.NumStates()
+	e.Board = r.cells(n, k)
+	e.Initial = r.cells(n, k)
+	if r.bool() {
+		e.TargetBoard = r.cells(n, k)
+	}
+	e.Solution = r.ints()
+	e.MoveLog = r.ints()
+	e.LogCursor = r.uint()
+	e.Rating.Par = r.uint()
+	e.Rating.NonLocality = r.float()
+	e.Rating.Ambiguity = r.uint()
+	e.Rating.Score = r.float()
+	e.Rating.Difficulty = Difficulty(r.str())
+
+	if r.err == nil && len(r.buf) != 0 {
+		r.err = fmt.Errorf("%d trailing bytes", len(r.buf))
+	}
+	if r.err != nil {
+		return fmt.Errorf("grid: %w", r.err)
+	}
+
+	decoded, err := e.decode()
+	if err != nil {
+		return fmt.Errorf("grid: %w", err)
+	}
+	*g = *decoded
+	return nil
+}
+
+// binaryWriter appends MarshalBinary's fields to buf.
+type binaryWriter struct {
+	buf []byte
+}
+
+func (w *binaryWriter) uint(v int) {
+	w.buf = binary.AppendUvarint(w.buf, uint64(v)) //nolint:gosec // every field written with it is non-negative
+}
+
+func (w *binaryWriter) int(v int) {
+	w.buf = binary.AppendVarint(w.buf, int64(v))
+}
+
+func (w *binaryWriter) bool(v bool) {
+	if v {
+		w.buf = append(w.buf, 1)
+	} else {
+		w.buf = append(w.buf, 0)
+	}
+}
+
+func (w *binaryWriter) float(v float64) {
+	w.buf = binary.LittleEndian.AppendUint64(w.buf, math.Float64bits(v))
+}
+
+func (w *binaryWriter) str(s string) {
+	w.uint(len(s))
+	w.buf = append(w.buf, s...)
+}
+
+func (w *binaryWriter) ints(vs []int) {
+	w.uint(len(vs))
+	for _, v := range vs {
+		w.uint(v)
+	}
+}
+
+// cells writes a board's cells: a bit each on a two-state board, a byte each
+// otherwise. Their count isn't written; the board's size already says it.
+func (w *binaryWriter) cells(cells []int, k int) {
+	if k != 2 {
+		for _, v := range cells {
+			w.buf = append(w.buf, byte(v)) //nolint:gosec // a cell's state, below k <= 7
+		}
+		return
+	}
+	packed := make([]byte, (len(cells)+7)/8)
+	for pos, v := range cells {
+		if v == 1 {
+			packed[pos/8] |= 1 << (pos % 8)
+		}
+	}
+	w.buf = append(w.buf, packed...)
+}
+
+// binaryReader reads back binaryWriter's fields from buf. The first malformed or
+// missing field sets err, after which every read returns a zero value -- so a decoder
+// can read every field unconditionally and check err once at the end. Counts are
+// checked against the bytes left before anything is allocated for them, so a forged
+// count can't make it allocate more than the input could possibly fill.
+type binaryReader struct {
+	buf []byte
+	err error
+}
+
+func (r *binaryReader) fail(what string) {
+	if r.err == nil {
+		r.err = fmt.Errorf("truncated or malformed %s", what)
+	}
+	r.buf = nil
+}
+
+func (r *binaryReader) uint() int {
+	v, size := binary.Uvarint(r.buf)
+	if size <= 0 || v > math.MaxInt32 {
+		r.fail("unsigned integer")
+		return 0
+	}
+	r.buf = r.buf[size:]
+	return int(v)
+}
+
+func (r *binaryReader) int() int {
+	v, size := binary.Varint(r.buf)
+	if size <= 0 || v > math.MaxInt32 || v < math.MinInt32 {
+		r.fail("integer")
+		return 0
+	}
+	r.buf = r.buf[size:]
+	return int(v)
+}
+
+func (r *binaryReader) varint() int64 {
+	v, size := binary.Varint(r.buf)
+	if size <= 0 {
+		r.fail("integer")
+		return 0
+	}
+	r.buf = r.buf[size:]
+	return v
+}
+
+func (r *binaryReader) bool() bool {
+	if len(r.buf) == 0 || r.buf[0] > 1 {
+		r.fail("flag")
+		return false
+	}
+	v := r.buf[0] == 1
+	r.buf = r.buf[1:]
+	return v
+}
+
+func (r *binaryReader) float() float64 {
+	if len(r.buf) < 8 {
+		r.fail("float")
+		return 0
+	}
+	v := math.Float64frombits(binary.LittleEndian.Uint64(r.buf))
+	r.buf = r.buf[8:]
+	return v
+}
+
+// count reads a count of items each taking at least minSize bytes.
+func (r *binaryReader) count(minSize int) int {
+	c := r.uint()
+	if c*minSize > len(r.buf) {
+		r.fail("count")
+		return 0
+	}
+	return c
+}
+
+func (r *binaryReader) str() string {
+	size := r.count(1)
+	s := string(r.buf[:size])
+	r.buf = r.buf[size:]
+	return s
+}
+
+func (r *binaryReader) ints() []int {
+	vs := make([]int, r.count(1))
+	for i := range vs {
+		vs[i] = r.uint()
+	}
+	return vs
+}
+
+func (r *binaryReader) cells(n, k int) []int {
+	size := n
+	if k == 2 {
+		size = (n + 7) / 8
+	}
+	if n < 0 || n > utils.MaxBoardCells || size > len(r.buf) {
+		r.fail("board")
+		return nil
+	}
+	cells := make([]int, n)
+	for pos := range cells {
+		if k == 2 {
+			cells[pos] = int(r.buf[pos/8]>>(pos%8)) & 1
+		} else {
+			cells[pos] = int(r.buf[pos])
+		}
+	}
+	r.buf = r.buf[size:]
+	return cells
+}
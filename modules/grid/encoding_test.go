@@ -0,0 +1,177 @@
+package grid
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// playSome makes a few legal presses on g, recording them, then undoes the last -- so
+// an encoding has a board, a history and a redo to carry.
+func playSome(g *Grid) {
+	played := 0
+	for pos := 0; pos < g.Rows*g.Cols && played < 4; pos += 2 {
+		if g.Switch(pos) {
+			g.RecordMove(pos)
+			played++
+		}
+	}
+	if pos, ok := g.PopLastMove(); ok {
+		g.Unswitch(pos)
+	}
+}
+
+// sameGame fails t unless got is want in every way a game can observe.
+func sameGame(t *testing.T, how string, got, want *Grid) {
+	t.Helper()
+
+	// A decoded graph is an equal copy, not the same pointer.
+	gotSpec, wantSpec := got.Spec(), want.Spec()
+	var gotGraph, wantGraph Graph
+	if gotSpec.Graph != nil && wantSpec.Graph != nil {
+		gotGraph, wantGraph = *gotSpec.Graph, *wantSpec.Graph
+		gotSpec.Graph, wantSpec.Graph = nil, nil
+	}
+
+	for _, check := range []struct {
+		what      string
+		got, want interface{}
+	}{
+		{"board", got.GetGrid(), want.GetGrid()},
+		{"dealt board", got.Restart().GetGrid(), want.Restart().GetGrid()},
+		{"target", got.Target(), want.Target()},
+		{"spec", gotSpec, wantSpec},
+		{"graph", gotGraph, wantGraph},
+		{"seed", got.Seed(), want.Seed()},
+		{"rating", got.Rating(), want.Rating()},
+		{"solution", got.GetPossibleSolution(), want.GetPossibleSolution()},
+		{"history", got.GetPreviousMoves(), want.GetPreviousMoves()},
+		{"move log", got.GetMoveLog(), want.GetMoveLog()},
+		{"redo depth", got.RedoDepth(), want.RedoDepth()},
+		{"win", got.CheckWin(), want.CheckWin()},
+	} {
+		if fmt.Sprint(check.got) != fmt.Sprint(check.want) {
+			t.Fatalf("%s: %s = %v, want %v", how, check.what, check.got, check.want)
+		}
+	}
+}
+
+func encodingSpecs() []Spec {
+	return []Spec{
+		{Rows: 5, Cols: 5, Neighborhood: classic(0, 4)},
+		{Rows: 4, Cols: 6, Lattice: LatticeHex, Neighborhood: []Pattern{hexPattern}, Topology: TopologyTorus},
+		{Rows: 3, Cols: 4, Neighborhood: classic(0, 4), States: 5},
+		{Rows: 6, Cols: 6, Neighborhood: classic(0, 4), Target: "heart", Difficulty: DifficultyHard},
+		{Rows: 4, Cols: 4, Neighborhood: classic(0, 4), Target: TargetRandom, States: 3},
+		{Rows: 4, Cols: 4, Neighborhood: classic(0, 4), LitOnly: true},
+		{Graph: petersenGraph(), States: 3},
+	}
+}
+
+func TestEncodingRoundTrips(t *testing.T) {
+	for _, spec := range encodingSpecs() {
+		g := NewGrid(spec)
+		playSome(g)
+
+		data, err := json.Marshal(g)
+		if err != nil {
+			t.Fatalf("%+v: MarshalJSON failed: %v", spec, err)
+		}
+		fromJSON := &Grid{}
+		if err := json.Unmarshal(data, fromJSON); err != nil {
+			t.Fatalf("%+v: UnmarshalJSON failed: %v", spec, err)
+		}
+		sameGame(t, "JSON", fromJSON, g)
+
+		packed, err := g.MarshalBinary()
+		if err != nil {
+			t.Fatalf("%+v: MarshalBinary failed: %v", spec, err)
+		}
+		fromBinary := &Grid{}
+		if err := fromBinary.UnmarshalBinary(packed); err != nil {
+			t.Fatalf("%+v: UnmarshalBinary failed: %v", spec, err)
+		}
+		sameGame(t, "binary", fromBinary, g)
+		if len(packed) >= len(data) {
+			t.Errorf("%+v: binary encoding is %d bytes, no smaller than JSON's %d", spec, len(packed), len(data))
+		}
+
+		// A decoded game plays on exactly as the original does.
+		for _, decoded := range []*Grid{fromJSON, fromBinary} {
+			pos, _ := decoded.RedoMove()
+			decoded.Switch(pos)
+		}
+		pos, _ := g.RedoMove()
+		g.Switch(pos)
+		sameGame(t, "JSON, played on", fromJSON, g)
+		sameGame(t, "binary, played on", fromBinary, g)
+	}
+}
+
+func TestUnmarshalJSONRejectsImpossibleGames(t *testing.T) {
+	g := NewGrid(Spec{Rows: 3, Cols: 3, Neighborhood: classic(0, 4)})
+	playSome(g)
+
+	tests := []struct {
+		name   string
+		tamper func(e *encodedGrid)
+	}{
+		{"another version", func(e *encodedGrid) { e.Version = encodingVersion + 1 }},
+		{"unknown lattice", func(e *encodedGrid) { e.Lattice = "triangle" }},
+		{"unsupported states", func(e *encodedGrid) { e.States = 4 }},
+		{"bad pattern", func(e *encodedGrid) { e.Neighborhood = []Pattern{{Name: "far", Offsets: [][2]int{{99, 0}}}} }},
+		{"no cells", func(e *encodedGrid) { e.Rows = 0 }},
+		{"wrong cell count", func(e *encodedGrid) { e.Board = e.Board[1:] }},
+		{"cell out of range", func(e *encodedGrid) { e.Initial[0] = 2 }},
+		{"target board without a target", func(e *encodedGrid) { e.TargetBoard = e.Board }},
+		{"press off the board", func(e *encodedGrid) { e.Solution = []int{9} }},
+		{"cursor past the log", func(e *encodedGrid) { e.LogCursor = len(e.MoveLog) + 1 }},
+		{"log not leading to the board", func(e *encodedGrid) { e.LogCursor = 0 }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := g.encode()
+			e.Board, e.Initial = g.board.cells(), g.initial.cells()
+			tt.tamper(&e)
+			data, err := json.Marshal(e)
+			if err != nil {
+				t.Fatalf("marshalling the tampered encoding failed: %v", err)
+			}
+			if err := json.Unmarshal(data, &Grid{}); err == nil {
+				t.Fatalf("UnmarshalJSON accepted %s", tt.name)
+			}
+		})
+	}
+}
+
+func TestUnmarshalBinaryRejectsMalformedData(t *testing.T) {
+	g := NewGrid(Spec{Graph: petersenGraph(), Neighborhood: classic(0)})
+	playSome(g)
+	packed, err := g.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary failed: %v", err)
+	}
+
+	for size := range len(packed) {
+		if err := (&Grid{}).UnmarshalBinary(packed[:size]); err == nil {
+			t.Fatalf("UnmarshalBinary accepted the encoding cut to %d of %d bytes", size, len(packed))
+		}
+	}
+	if err := (&Grid{}).UnmarshalBinary(append(packed, 0)); err == nil || !strings.Contains(err.Error(), "trailing") {
+		t.Fatalf("UnmarshalBinary with a trailing byte = %v, want a trailing bytes error", err)
+	}
+
+	versioned := append([]byte(nil), packed...)
+	versioned[len(binaryMagic)]++
+	if err := (&Grid{}).UnmarshalBinary(versioned); err == nil {
+		t.Fatal("UnmarshalBinary accepted another encoding version")
+	}
+
+	// A huge count must be refused before anything's allocated for it.
+	forged := append(append([]byte(nil), binaryMagic...), encodingVersion, 3, 3, 0xff, 0xff, 0xff, 0xff, 0x07)
+	if err := (&Grid{}).UnmarshalBinary(forged); err == nil {
+		t.Fatal("UnmarshalBinary accepted a forged string length")
+	}
+}
@@ -0,0 +1,78 @@
+package grid
+
+import "math/bits"
+
+// bitVec is a packed vector over GF(2): bit i of the vector is bit (i % 64) of word
+// (i / 64). Addition over GF(2) is XOR, so toggling a set of cells and adding two
+// vectors are the same operation -- which is what makes the board's linear algebra
+// cheap to express with it.
+type bitVec []uint64
+
+func newBitVec(n int) bitVec {
+	return make(bitVec, (n+63)/64)
+}
+
+// onesVec returns a bitVec of length n with every bit set -- and none past n, so its
+// onesCount is exactly n.
+func onesVec(n int) bitVec {
+	v := newBitVec(n)
+	for i := range v {
+		v[i] = ^uint64(0)
+	}
+	if rem := n % 64; rem != 0 {
+		v[len(v)-1] = 1<<uint(rem) - 1
+	}
+	return v
+}
+
+func (v bitVec) get(i int) bool {
+	return v[i/64]&(1<<(uint(i)%64)) != 0
+}
+
+func (v bitVec) set(i int) {
+	v[i/64] |= 1 << (uint(i) % 64)
+}
+
+func (v bitVec) flip(i int) {
+	v[i/64] ^= 1 << (uint(i) % 64)
+}
+
+// xor adds o into v in place. Both must have been built for the same length.
+func (v bitVec) xor(o bitVec) {
+	for i := range v {
+		v[i] ^= o[i]
+	}
+}
+
+// dot returns the GF(2) inner product of v and o: the parity of their common bits.
+func (v bitVec) dot(o bitVec) bool {
+	var acc uint64
+	for i := range v {
+		acc ^= v[i] & o[i]
+	}
+	return bits.OnesCount64(acc)%2 == 1
+}
+
+func (v bitVec) onesCount() int {
+	count := 0
+	for _, w := range v {
+		count += bits.OnesCount64(w)
+	}
+	return count
+}
+
+func (v bitVec) clone() bitVec {
+	return append(bitVec(nil), v...)
+}
+
+// positions returns the indices of v's set bits, ascending.
+func (v bitVec) positions() []int {
+	var out []int
+	for wi, w := range v {
+		for w != 0 {
+			out = append(out, wi*64+bits.TrailingZeros64(w))
+			w &= w - 1
+		}
+	}
+	return out
+}
@@ -1,12 +1,16 @@
-// Package grid implements the switch-toggle puzzle board: a square grid of
-// two-state cells where switching one cell also flips its neighbors according to
-// a configurable pattern (self, orthogonal, and/or diagonal).
+// Package grid implements the switch-toggle puzzle board: a rows x cols grid of
+// k-state square or hexagonal cells where switching one cell also advances its
+// neighbors according to configurable patterns of offsets (self, orthogonal, diagonal,
+// knight moves, the six hex directions, ...), on either a bounded board or a torus
+// whose edges wrap around -- or, instead of a grid, the nodes of an arbitrary graph,
+// where switching a node advances it and its adjacent nodes.
 package grid
 
 import (
 	cryptorand "crypto/rand"
 	"encoding/binary"
 	"fmt"
+	"hash/fnv"
 	"log/slog"
 	"math/rand"
 	"sort"
@@ -16,20 +20,239 @@ import (
 	utils "goSwitch/modules/utils"
 )
 
+// Pattern is a named set of (dx, dy) offsets that switching a cell also flips; see
+// utils.Pattern, which it aliases so config.json can declare patterns directly.
+type Pattern = utils.Pattern
+
+// Graph is a board made of a graph's nodes instead of a lattice's cells; see
+// utils.Graph, which it aliases so config can declare graphs directly.
+type Graph = utils.Graph
+
+// Topology is how a board's edges behave when a pattern's offset points past them.
+type Topology string
+
+const (
+	// TopologyBounded discards offsets that fall off the board: the classic game.
+	TopologyBounded Topology = "bounded"
+	// TopologyTorus wraps them around to the opposite edge, on both axes, so every
+	// cell has the same neighborhood -- and, in general, very different solvability
+	// from the same-sized bounded board.
+	TopologyTorus Topology = "torus"
+)
+
+// Topologies lists every supported Topology, in the order the configuration panel
+// offers them.
+var Topologies = []Topology{TopologyBounded, TopologyTorus}
+
+// ParseTopology returns the Topology named s. The empty string is TopologyBounded, so
+// configs written before topologies existed keep their meaning.
+func ParseTopology(s string) (Topology, error) {
+	if s == "" {
+		return TopologyBounded, nil
+	}
+	for _, t := range Topologies {
+		if string(t) == s {
+			return t, nil
+		}
+	}
+	return "", fmt.Errorf("unknown topology %q", s)
+}
+
+// Lattice is the shape of a board's cells, which decides what a pattern's offsets mean.
+type Lattice string
+
+const (
+	// LatticeSquare is the classic square grid: an offset is (dx, dy), in columns and
+	// rows.
+	LatticeSquare Lattice = "square"
+	// LatticeHex is a grid of hexagons, laid out in rows with every odd row shifted
+	// half a cell right. An offset is (dq, dr) in axial coordinates: dr rows down, and
+	// dq steps along the row axis, so the six neighbors are (1, 0), (1, -1), (0, -1),
+	// (-1, 0), (-1, 1) and (0, 1).
+	LatticeHex Lattice = "hex"
+)
+
+// Lattices lists every supported Lattice, in the order the configuration panel offers
+// them.
+var Lattices = []Lattice{LatticeSquare, LatticeHex}
+
+// ParseLattice returns the Lattice named s. The empty string is LatticeSquare, so
+// configs written before lattices existed keep their meaning.
+func ParseLattice(s string) (Lattice, error) {
+	if s == "" {
+		return LatticeSquare, nil
+	}
+	for _, l := range Lattices {
+		if string(l) == s {
+			return l, nil
+		}
+	}
+	return "", fmt.Errorf("unknown lattice %q", s)
+}
+
+// SupportedStates lists the numbers of states per cell a board can have. Only primes:
+// the solver works over GF(k), which is only a field -- where every nonzero press count
+// can be divided out during elimination -- when k is prime.
+var SupportedStates = []int{2, 3, 5, 7}
+
+// MaxMultiStateCells caps the number of cells on a board with more than two states.
+// Their solver (modSystem) reduces a dense matrix at O(cells^3), which is instant at
+// this size but would take minutes on the largest two-state boards, whose solver
+// packs 64 cells into each word instead.
+const MaxMultiStateCells = 256
+
+// Spec is everything that determines a board's rules, as opposed to its current
+// state: its size and cell shape, the active toggle patterns, how its edges behave,
+// and how many states each cell cycles through.
+type Spec struct {
+	Rows         int
+	Cols         int
+	Lattice      Lattice
+	Neighborhood []Pattern
+	Topology     Topology
+	// States is k: each press advances every affected cell by one, mod k. Zero means
+	// the classic two-state game.
+	States int
+	// Graph, if set, replaces the lattice altogether: the board is its nodes, as a
+	// single row of Graph.Nodes cells, and a press advances the pressed node and its
+	// neighbors in the graph. Rows, Cols, Lattice, Neighborhood and Topology are then
+	// ignored; States still applies.
+	Graph *Graph
+	// Difficulty isn't a rule, and nothing but NewGrid reads it: it has NewGrid keep
+	// redealing until the board's Rating falls in that band.
+	Difficulty Difficulty
+	// Target is what the board has to look like to be won: the classic uniform board,
+	// or a picture (see Target).
+	Target Target
+	// LitOnly only lets lit cells be pressed -- the lit-only sigma game. It needs two
+	// states, and changes which boards can be won, so dealing and solving take their
+	// own path (see scrambleLitOnly and searchLitOnly).
+	LitOnly bool
+}
+
+// size returns the board's actual rows and columns: one row of nodes for a graph
+// board, Rows x Cols otherwise.
+func (s Spec) size() (rows, cols int) {
+	if s.Graph != nil {
+		return 1, s.Graph.Nodes
+	}
+	return s.Rows, s.Cols
+}
+
+// Validate reports whether s is within what the solver can handle (see
+// MaxMultiStateCells and MaxLitOnlyCells), whether its Target picture fits on the
+// board, and whether it's a real hex torus if it's meant to be one.
+func (s Spec) Validate() error {
+	rows, cols := s.size()
+	// Odd rows are shifted half a cell right, so wrapping the bottom row onto the top
+	// only lines the hexagons up if the rows alternate all the way round: with an odd
+	// count, the last and first rows are both even, and a cell's neighbors across the
+	// seam don't count it as theirs.
+	if s.Graph == nil && s.Lattice == LatticeHex && s.Topology == TopologyTorus && rows%2 != 0 {
+		return fmt.Errorf("a hex torus needs an even number of rows, got %d", rows)
+	}
+	if s.NumStates() > 2 && rows*cols > MaxMultiStateCells {
+		return fmt.Errorf("a board with %d states per cell can have at most %d cells, got %d",
+			s.NumStates(), MaxMultiStateCells, rows*cols)
+	}
+	if s.LitOnly {
+		if err := validateLitOnly(rows*cols, s.NumStates()); err != nil {
+			return err
+		}
+	}
+	return s.Target.validate(rows, cols, s.Graph != nil)
+}
+
+// DefaultSpec returns config's default board: the one every new session starts on.
+// config is expected to have passed CheckConfig already; a hand-built one with an
+// unknown lattice, topology, difficulty or target gets the square, bounded, uniform
+// board of any difficulty, and an unknown Graph a lattice board.
+func DefaultSpec(config *utils.Config) Spec {
+	lattice, err := ParseLattice(config.Lattice)
+	if err != nil {
+		lattice = LatticeSquare
+	}
+	topology, err := ParseTopology(config.Topology)
+	if err != nil {
+		topology = TopologyBounded
+	}
+	difficulty, err := ParseDifficulty(config.Difficulty)
+	if err != nil {
+		difficulty = DifficultyAny
+	}
+	target, err := ParseTarget(config.Target)
+	if err != nil {
+		target = TargetUniform
+	}
+
+	var graph *Graph
+	for i := range config.Graphs {
+		if config.Graphs[i].Name == config.Graph {
+			graph = &config.Graphs[i]
+		}
+	}
+
+	return Spec{
+		Rows:         config.Rows,
+		Cols:         config.Cols,
+		Lattice:      lattice,
+		Neighborhood: utils.BuildNeighborhoodFromConfig(config),
+		Topology:     topology,
+		States:       Spec{States: config.States}.NumStates(),
+		Graph:        graph,
+		Difficulty:   difficulty,
+		Target:       target,
+		LitOnly:      config.LitOnly,
+	}
+}
+
+// NumStates returns s.States, with its zero value meaning 2.
+func (s Spec) NumStates() int {
+	if s.States == 0 {
+		return 2
+	}
+	return s.States
+}
+
 type Grid struct {
-	Dim          int
-	neighborhood []int
-	grid         []int
+	Rows         int
+	Cols         int
+	lattice      Lattice
+	neighborhood []Pattern
+	topology     Topology
+	states       int
+	graph        *Graph
+	board        boardState
+	initial      boardState
 	solution     []int
 	moveHistory  []int
+	moveLog      []int
+	logCursor    int
+	seed         int64
 	rand         *rand.Rand
+	difficulty   Difficulty
+	rating       Rating
+	target       Target
+	// targetBoard is the board CheckWin wants, or nil for the classic uniform win. It
+	// never changes once dealt, so copies of g (see Restart) share it.
+	targetBoard *boardState
+	litOnly     bool
+
+	// table is every press's precomputed effect, which Switch applies (see toggles);
+	// linear is the reduced toggle matrix Solve works from (see system). Both are
+	// shared with every other Grid of the same configuration.
+	table  *toggleTable
+	linear toggleSystem
+
+	// optimal is OptimalSolution's last answer, and the board it's for.
+	optimal *solvedBoard
 }
 
 // maxInitAttempts bounds the "regenerate until not already won" retry loop in
-// NewGrid. Some (dim, neighborhood) combinations are structurally degenerate --
-// e.g. a 2x2 grid with every pattern enabled has every switch touch all 4 cells
-// uniformly, so the board can never be anything but solved -- without a cap the
-// loop would spin forever instead of just being unlikely to need many retries.
+// NewGrid. Structurally degenerate configurations, where no scramble could ever end
+// unsolved, are detected analytically up front (see Analysis.Degenerate) and never
+// reach the loop; for everything else a scramble landing back on a win is only bad
+// luck, and the cap just keeps a long streak of it from costing unbounded time.
 const maxInitAttempts = 1000
 
 // randSeed returns a seed sourced from crypto/rand rather than time.Now().UnixNano(),
@@ -46,46 +269,191 @@ func randSeed() int64 {
 	return int64(binary.LittleEndian.Uint64(buf[:])) //nolint:gosec // puzzle shuffling, not security-sensitive
 }
 
-// NewGrid builds a dim x dim board using neighborhood as the active toggle patterns.
-// dim must be >= 1: dim <= 0 panics (a zero-or-negative-size board isn't a meaningful
-// precondition to support), and dim == 1 -- while it won't panic -- produces a trivial
-// single-cell board whose only two possible states are both already "won", so callers
-// wanting an actual puzzle should use dim >= 2.
-func NewGrid(dim int, neighborhood []int) *Grid {
+// DailySeed returns the seed of day's daily puzzle: a hash of its UTC date, so every
+// client, and every server instance, deals the same board all day -- and a different
+// one the next.
+func DailySeed(day time.Time) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(day.UTC().Format(time.DateOnly)))
+	return int64(h.Sum64()) //nolint:gosec // a seed, only ever used bit-for-bit
+}
+
+// NewGrid builds a spec.Rows x spec.Cols board using spec.Neighborhood as the active
+// toggle patterns; a Lattice other than LatticeHex is treated as square, a Topology
+// other than TopologyTorus as bounded, and spec.States must be zero or one of
+// SupportedStates. A spec.Graph board is 1 x Graph.Nodes instead. Both sides must be
+// >= 1: a zero-or-negative side panics (a board with no cells isn't a meaningful
+// precondition to support), and a 1x1 board -- while it won't panic -- is a trivial
+// single cell whose only two possible states are both already "won", so callers
+// wanting an actual puzzle should use at least two cells. The board is scrambled from
+// a fresh random seed; see NewGridFromSeed to deal a particular one.
+func NewGrid(spec Spec) *Grid {
+	return NewGridFromSeed(spec, randSeed())
+}
+
+// NewGridFromSeed is NewGrid with the scramble's seed chosen by the caller: the same
+// spec and seed always deal the same board, which is what lets a board be shared or
+// replayed (see Seed). Everything random about dealing, retries included, is drawn
+// from that seed alone.
+func NewGridFromSeed(spec Spec, seed int64) *Grid {
+	rows, cols := spec.size()
 	g := &Grid{
-		Dim:          dim,
-		neighborhood: neighborhood,
-		grid:         make([]int, dim*dim),
-		rand:         rand.New(rand.NewSource(randSeed())), //nolint:gosec // puzzle shuffling, not security-sensitive; the seed itself comes from crypto/rand
+		Rows:         rows,
+		Cols:         cols,
+		lattice:      spec.Lattice,
+		neighborhood: spec.Neighborhood,
+		topology:     spec.Topology,
+		states:       spec.States,
+		graph:        spec.Graph,
+		board:        newBoardState(rows*cols, spec.NumStates()),
+		seed:         seed,
+		rand:         rand.New(rand.NewSource(seed)), //nolint:gosec // puzzle shuffling, not security-sensitive; NewGrid's seeds come from crypto/rand
+		difficulty:   spec.Difficulty,
+		target:       spec.Target,
+		litOnly:      spec.LitOnly,
 	}
 
+	g.dealTarget()
 	g.initGame()
-	for attempts := 0; g.CheckWin() && attempts < maxInitAttempts; attempts++ {
-		g.initGame()
-	}
 
-	if g.CheckWin() {
-		// Structurally degenerate configuration (see maxInitAttempts doc comment):
-		// every reachable Switch() result is itself a win, so there is no sequence of
+	if degenerate(g.system(), g.targetBoard == nil) {
+		// Every reachable Switch() result is itself a win, so there is no sequence of
 		// real moves that both starts from a won board and ends unsolved. Force a
-		// single raw flip instead -- this deliberately bypasses Switch's neighborhood
+		// single raw bump instead -- this deliberately bypasses Switch's neighborhood
 		// fanout, so g.solution can't describe it as a move sequence; clear it rather
 		// than report a "solution" that doesn't actually solve this board.
-		g.grid[0] = 1 - g.grid[0]
+		g.board.bump(0, g.modulus())
 		g.solution = nil
+		g.rating = g.rate()
+		g.initial = g.board.clone()
+		return g
+	}
+
+	g.redealWhileWon()
+	g.rating = g.rate()
+
+	// Redealt until the board lands in the requested band, keeping the closest one
+	// seen in case none does -- some configurations can't reach every band at all (a
+	// 2x2 board has too few cells to be hard), so Rating, not the request, is what
+	// says how hard the board actually is.
+	best := g.snapshot()
+	work := g.ratingWork()
+	for attempts, spent := 0, work; g.board.n <= maxRatedCells && !g.difficulty.contains(best.rating.Score) && attempts < maxDifficultyAttempts && spent+work <= maxDifficultyWork; attempts, spent = attempts+1, spent+work {
+		g.initGame()
+		g.redealWhileWon()
+		g.rating = g.rate()
+		if g.difficulty.distance(g.rating.Score) < g.difficulty.distance(best.rating.Score) {
+			best = g.snapshot()
+		}
 	}
+	g.restore(best)
+	g.initial = g.board.clone()
 
 	return g
 }
 
+// maxDifficultyAttempts bounds NewGrid's redeals in search of a board in the requested
+// Difficulty band. Each one costs an OptimalSolution, so this is kept well below
+// maxInitAttempts.
+const maxDifficultyAttempts = 100
+
+// maxDifficultyWork bounds those redeals' total cost too, first deal's rating included,
+// as ratingWork estimates it: about a tenth of a second's worth. A small board gets
+// every attempt; one with too many quiet patterns to search cheaply -- each doubles
+// what rating a board costs -- gets few, or none, and keeps the board first dealt. The
+// estimate depends on the Spec alone, so the same seed still deals the same board.
+const maxDifficultyWork = 1 << 24
+
+// maxRatedCells is the biggest board NewGrid redeals for a Difficulty, and rates by
+// its optimal solution. Past it, even a search bounded by maxOptimalWork adds up over a
+// hundred redeals, and the par it finds is more often only an upper bound anyway: a
+// bigger board is dealt once and rated from Solve (see Rating.Estimated).
+const maxRatedCells = 400
+
+// ratingWork estimates what rating one of g's boards costs, in the solver's word-sized
+// steps: an optimal solve per win target, or a lit-only search run to its budget.
+func (g *Grid) ratingWork() int {
+	if g.litOnly {
+		return maxLitOnlyStates * g.board.n
+	}
+	targets := g.modulus()
+	if g.targetBoard != nil {
+		targets = 1
+	}
+	return targets * g.system().optimalWork()
+}
+
+// redealWhileWon redeals until the board isn't already won, and if a long streak of bad
+// luck keeps it won anyway, unsolves it with a single press.
+func (g *Grid) redealWhileWon() {
+	for attempts := 0; g.CheckWin() && attempts < maxInitAttempts; attempts++ {
+		g.initGame()
+	}
+
+	if g.CheckWin() {
+		g.unsolveWithOnePress()
+	}
+}
+
+// dealt is a dealt board, as NewGrid keeps the best candidate while redealing.
+type dealt struct {
+	board    boardState
+	solution []int
+	rating   Rating
+}
+
+func (g *Grid) snapshot() dealt {
+	return dealt{board: g.board.clone(), solution: append([]int(nil), g.solution...), rating: g.rating}
+}
+
+func (g *Grid) restore(d dealt) {
+	g.board, g.solution, g.rating = d.board, d.solution, d.rating
+}
+
+// unsolveWithOnePress takes a won board to an unsolved one with a single real press,
+// recording the k-1 presses that undo it as the solution. One always exists for a
+// nondegenerate configuration: some press's effect must be neither nothing nor a
+// uniform advance of the whole board, or no press list could be either -- and against
+// a target picture, any press with an effect at all will do.
+func (g *Grid) unsolveWithOnePress() {
+	if g.litOnly {
+		g.unsolveLitOnly()
+		return
+	}
+	for pos := range g.board.n {
+		g.Switch(pos)
+		if !g.CheckWin() {
+			g.solution = nil
+			for range g.modulus() - 1 {
+				g.solution = append(g.solution, pos)
+			}
+			return
+		}
+		g.Unswitch(pos)
+	}
+}
+
+// initGame deals a won board -- a random uniform one, or the target picture -- then
+// scrambles it by pressing a random subset of cells, each a random 1..k-1 times. The
+// solution it records is what undoes that: each of those cells pressed the rest of the
+// way round to k. Scrambling by real presses is what makes every target reachable. A
+// LitOnly board is scrambled by scrambleLitOnly instead.
 func (g *Grid) initGame() {
-	gridSize := g.Dim * g.Dim
+	gridSize := g.board.n
+	k := g.modulus()
 	hits := make([]int, gridSize)
 
-	start := g.rand.Intn(2)
+	if g.targetBoard != nil {
+		g.board = g.targetBoard.clone()
+	} else {
+		g.board.fill(g.rand.Intn(k))
+	}
+	if g.litOnly {
+		g.scrambleLitOnly()
+		return
+	}
 
 	for pos := range gridSize {
-		g.grid[pos] = start
 		hits[pos] = pos
 	}
 
@@ -94,39 +462,51 @@ func (g *Grid) initGame() {
 	})
 
 	randIndex := g.rand.Intn(gridSize)
-	g.solution = hits[:randIndex]
+	g.solution = []int{}
+	for _, hit := range hits[:randIndex] {
+		presses := 1 + g.rand.Intn(k-1)
+		for range presses {
+			g.Switch(hit)
+		}
+		for range k - presses {
+			g.solution = append(g.solution, hit)
+		}
+	}
 	sort.Ints(g.solution)
+}
 
-	for _, hit := range g.solution {
-		g.Switch(hit)
+// modulus returns k, the number of states each cell cycles through; a zero states
+// field (e.g. a Grid built as a struct literal) means the classic 2.
+func (g *Grid) modulus() int {
+	if g.states == 0 {
+		return 2
 	}
+	return g.states
 }
 
 // coordFlatToCart converts a flat board position into (x, y) cartesian coordinates.
 func (g *Grid) coordFlatToCart(pos int) (int, int) {
-	if pos >= len(g.grid) {
+	if pos >= g.board.n {
 		return -1, -1
 	}
-	return pos % g.Dim, pos / g.Dim
+	return pos % g.Cols, pos / g.Cols
 }
 
 func (g *Grid) checkOOB(x, y int) bool {
-	return (0 <= x && x < g.Dim) && (0 <= y && y < g.Dim)
+	return (0 <= x && x < g.Cols) && (0 <= y && y < g.Rows)
 }
 
-// orthogonalOffsets and diagonalOffsets are the (dx, dy) pairs for the "4" (plus-shaped)
-// and "8" (diagonal) neighborhood patterns, relative to the switched cell.
-var (
-	orthogonalOffsets = [][2]int{{1, 0}, {0, 1}, {-1, 0}, {0, -1}}
-	diagonalOffsets   = [][2]int{{1, 1}, {-1, -1}, {1, -1}, {-1, 1}}
-)
-
-// neighborsAt returns the in-bounds cells at (x,y)+offset for each offset, discarding
-// any that fall off the board.
+// neighborsAt returns the cells at (x,y)+offset for each offset (see step). On a torus
+// those wrap around modulo the board's size, so none are lost -- though on a small
+// enough board two offsets can wrap onto the same cell; otherwise any that fall off
+// the board are discarded.
 func (g *Grid) neighborsAt(x, y int, offsets [][2]int) [][2]int {
 	coordsToSwitch := [][2]int{}
 	for _, off := range offsets {
-		nx, ny := x+off[0], y+off[1]
+		nx, ny := g.step(x, y, off)
+		if g.topology == TopologyTorus {
+			nx, ny = wrap(nx, g.Cols), wrap(ny, g.Rows)
+		}
 		if g.checkOOB(nx, ny) {
 			coordsToSwitch = append(coordsToSwitch, [2]int{nx, ny})
 		}
@@ -134,84 +514,255 @@ func (g *Grid) neighborsAt(x, y int, offsets [][2]int) [][2]int {
 	return coordsToSwitch
 }
 
-func (g *Grid) Switch(pos int) {
+// step returns the (column, row) reached from (x, y) by off, in g's lattice. On a hex
+// board that means converting the odd-row-shifted layout to axial coordinates, where
+// a hex offset is a plain sum, and back; r - r&1 is r rounded down to even, negative r
+// included, so the halving is exact.
+func (g *Grid) step(x, y int, off [2]int) (int, int) {
+	if g.lattice != LatticeHex {
+		return x + off[0], y + off[1]
+	}
+	q := x - (y-y&1)/2
+	q, r := q+off[0], y+off[1]
+	return q + (r-r&1)/2, r
+}
+
+// wrap reduces v into [0, n), unlike %, which keeps v's sign.
+func wrap(v, n int) int {
+	return ((v % n) + n) % n
+}
+
+// affected returns the flat positions Switch(pos) advances: every in-bounds cell at
+// pos + offset, for every offset of every active pattern. A position can appear more
+// than once (e.g. overlapping patterns), in which case it's advanced that many times.
+// Out-of-bounds positions affect nothing. It's worked out from scratch on each call,
+// so it's only used to build a configuration's toggleTable, which Switch reads
+// instead.
+func (g *Grid) affected(pos int) []int {
+	if g.graph != nil {
+		return g.graphNeighbors(pos)
+	}
+
 	x, y := g.coordFlatToCart(pos)
 
 	if !g.checkOOB(x, y) {
-		return
+		return nil
 	}
 
 	var coordsToSwitch [][2]int
-	for _, val := range g.neighborhood {
-		switch val {
-		case 0:
-			coordsToSwitch = append(coordsToSwitch, [2]int{x, y})
-		case 4:
-			coordsToSwitch = append(coordsToSwitch, g.neighborsAt(x, y, orthogonalOffsets)...)
-		case 8:
-			coordsToSwitch = append(coordsToSwitch, g.neighborsAt(x, y, diagonalOffsets)...)
-		}
+	for _, pattern := range g.neighborhood {
+		coordsToSwitch = append(coordsToSwitch, g.neighborsAt(x, y, pattern.Offsets)...)
 	}
+
+	positions := make([]int, 0, len(coordsToSwitch))
 	for _, coord := range coordsToSwitch {
-		g.grid[coord[0]+g.Dim*coord[1]] = 1 - g.grid[coord[0]+g.Dim*coord[1]]
+		positions = append(positions, coord[0]+g.Cols*coord[1])
+	}
+	return positions
+}
+
+// graphNeighbors is affected for a graph board: node pos itself, then every node it
+// shares an edge with. Scanning the edge list on each press is fine at the sizes
+// utils allows, and keeps a Grid's graph the only thing describing its adjacency.
+func (g *Grid) graphNeighbors(pos int) []int {
+	if pos < 0 || pos >= g.board.n {
+		return nil
+	}
+
+	positions := []int{pos}
+	for _, edge := range g.graph.Edges {
+		switch pos {
+		case edge[0]:
+			positions = append(positions, edge[1])
+		case edge[1]:
+			positions = append(positions, edge[0])
+		}
 	}
+	return positions
+}
+
+// Switch presses pos: every cell it affects advances to its next state, wrapping from
+// k-1 back to 0 -- on the classic two-state board, a plain flip. It refuses, leaving
+// the board as it was and returning false, whatever CanSwitch does: out-of-bounds
+// positions, and unlit cells on a LitOnly board.
+func (g *Grid) Switch(pos int) bool {
+	if !g.CanSwitch(pos) {
+		return false
+	}
+	g.board.press(g.toggles(), pos, 1, g.modulus())
+	return true
+}
+
+// Unswitch is Switch's inverse: every cell pos affects steps back one state. With two
+// states it's the same as Switch -- except on a LitOnly board, where undoing a move
+// never has to be a legal move itself.
+func (g *Grid) Unswitch(pos int) {
+	if pos < 0 || pos >= g.board.n {
+		return
+	}
+	g.board.press(g.toggles(), pos, -1, g.modulus())
+}
+
+// Spec returns the rules g was built with. Its Neighborhood is a copy, safe to keep
+// after the caller releases whatever lock was guarding this Grid; its Graph is shared,
+// but graphs are never modified once loaded.
+func (g *Grid) Spec() Spec {
+	return Spec{
+		Rows:         g.Rows,
+		Cols:         g.Cols,
+		Lattice:      g.lattice,
+		Neighborhood: append([]Pattern(nil), g.neighborhood...),
+		Topology:     g.topology,
+		States:       g.states,
+		Graph:        g.graph,
+		Difficulty:   g.difficulty,
+		Target:       g.target,
+		LitOnly:      g.litOnly,
+	}
+}
+
+// Restart returns a new Grid on g's board as it was dealt, with no moves played -- for
+// replaying a game from the start without touching g. It shares g's configuration,
+// including its precomputed toggles, so it costs only a copy of the board.
+func (g *Grid) Restart() *Grid {
+	r := *g
+	r.board = g.initial.clone()
+	r.moveHistory, r.moveLog, r.logCursor = nil, nil, 0
+	return &r
+}
+
+// Seed returns the seed g's board was dealt from: passing it and g's Spec to
+// NewGridFromSeed deals the same board again, as it was before any moves.
+func (g *Grid) Seed() int64 {
+	return g.seed
 }
 
 // GetGrid returns a defensive copy of the board, safe to read after the caller
 // releases whatever lock was guarding this Grid.
 func (g *Grid) GetGrid() [][]int {
-	customGrid := make([][]int, g.Dim)
-	for idx := 0; idx < g.Dim; idx++ {
-		row := make([]int, g.Dim)
-		copy(row, g.grid[g.Dim*idx:(idx+1)*g.Dim])
-		customGrid[idx] = row
+	cells := g.board.cells()
+	customGrid := make([][]int, g.Rows)
+	for idx := 0; idx < g.Rows; idx++ {
+		customGrid[idx] = cells[g.Cols*idx : (idx+1)*g.Cols : (idx+1)*g.Cols]
 	}
 	return customGrid
 }
 
+// GetPossibleSolution returns the presses that undo initGame's scramble, and so solve
+// the board -- but only from the initial board. Once the player has moved, use Solve
+// instead.
 func (g *Grid) GetPossibleSolution() []int {
 	return append([]int(nil), g.solution...)
 }
 
+// GetPreviousMoves returns the net-effect view of the moves played: the presses that
+// still change the board, with any pos pressed k times since dropped (see RecordMove).
 func (g *Grid) GetPreviousMoves() []int {
 	return append([]int(nil), g.moveHistory...)
 }
 
-// RecordMove toggles pos's membership in the move history rather than always
-// appending it. Switch's own effects are self-inverse and commute (fixed
-// 1-val flips over a fixed neighborhood for this Grid's lifetime), so switching the
-// same pos twice always cancels out on the board regardless of what happened in
-// between -- this keeps the recorded history matching the set of moves that still
-// have a net effect, in the order they last took effect, instead of growing
-// unboundedly every time a player reclicks the same cell.
+// GetMoveLog returns every press played so far, in the order they were made -- clicks
+// that cancel each other out included, undone ones not.
+func (g *Grid) GetMoveLog() []int {
+	return append([]int(nil), g.moveLog[:g.logCursor]...)
+}
+
+// RedoDepth returns how many undone moves RedoMove can still replay.
+func (g *Grid) RedoDepth() int {
+	return len(g.moveLog) - g.logCursor
+}
+
+// RecordMove logs pos as the latest press, discarding any undone moves RedoMove could
+// have replayed -- a new move starts a new branch, as in any editor -- and updates the
+// net-effect view GetPreviousMoves returns.
 func (g *Grid) RecordMove(pos int) {
-	for i, m := range g.moveHistory {
+	g.moveLog = append(g.moveLog[:g.logCursor], pos)
+	g.logCursor++
+	g.recordNet(pos)
+}
+
+// recordNet appends pos to the net-effect history, unless that makes it pos's k-th
+// recorded press, in which case every recorded press of pos is dropped instead.
+// Switch's effects commute (fixed +1 mod k steps over a fixed neighborhood for this
+// Grid's lifetime), so pressing the same pos k times always cancels out on the board
+// regardless of what happened in between -- this keeps the net-effect history matching
+// the presses that still have an effect, instead of growing every time a player cycles
+// the same cell. On two states that's a plain toggle of pos's membership.
+func (g *Grid) recordNet(pos int) {
+	count := 0
+	for _, m := range g.moveHistory {
 		if m == pos {
-			g.moveHistory = append(g.moveHistory[:i], g.moveHistory[i+1:]...)
-			return
+			count++
+		}
+	}
+
+	if count+1 < g.modulus() {
+		g.moveHistory = append(g.moveHistory, pos)
+		return
+	}
+
+	kept := g.moveHistory[:0]
+	for _, m := range g.moveHistory {
+		if m != pos {
+			kept = append(kept, m)
 		}
 	}
-	g.moveHistory = append(g.moveHistory, pos)
+	g.moveHistory = kept
 }
 
-// PopLastMove removes and returns the most recently recorded move (the one a
-// RevertMove should undo next). ok is false if there's nothing to revert.
+// PopLastMove steps the move log back one press and returns it, for RevertMove to undo
+// with Unswitch; the press stays logged for RedoMove until a new move replaces it. ok
+// is false if there's nothing left to revert. Unlike the net-effect view, this walks
+// back through the clicks actually made, so a cell clicked twice is undone twice.
 func (g *Grid) PopLastMove() (pos int, ok bool) {
-	if len(g.moveHistory) == 0 {
+	if g.logCursor == 0 {
 		return 0, false
 	}
-	last := len(g.moveHistory) - 1
-	pos = g.moveHistory[last]
-	g.moveHistory = g.moveHistory[:last]
+	g.logCursor--
+	pos = g.moveLog[g.logCursor]
+	g.moveHistory = netMoves(g.moveLog[:g.logCursor], g.modulus())
 	return pos, true
 }
 
+// RedoMove steps the move log forward over the press PopLastMove last undid and returns
+// it, for Redo to replay with Switch. ok is false if there's nothing undone to redo.
+func (g *Grid) RedoMove() (pos int, ok bool) {
+	if g.logCursor == len(g.moveLog) {
+		return 0, false
+	}
+	pos = g.moveLog[g.logCursor]
+	g.logCursor++
+	g.recordNet(pos)
+	return pos, true
+}
+
+// netMoves returns the net-effect history recordNet builds up from log, in one pass
+// rather than by replaying it: every k presses of a pos cancel out, so of a pos's c
+// presses exactly the last c mod k survive, in log order.
+func netMoves(log []int, k int) []int {
+	total := map[int]int{}
+	for _, pos := range log {
+		total[pos]++
+	}
+
+	var net []int
+	seen := map[int]int{}
+	for _, pos := range log {
+		if seen[pos] >= total[pos]-total[pos]%k {
+			net = append(net, pos)
+		}
+		seen[pos]++
+	}
+	return net
+}
+
+// CheckWin reports whether the board matches its target picture, or without one,
+// whether every cell is in the same state.
 func (g *Grid) CheckWin() bool {
-	sum := 0
-	for _, val := range g.grid {
-		sum += val
+	if g.targetBoard != nil {
+		return g.board.equal(*g.targetBoard)
 	}
-	return sum == 0 || sum == g.Dim*g.Dim
+	return g.board.uniform()
 }
 
 func (g *Grid) PrettyPrintGrid() {
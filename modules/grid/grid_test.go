@@ -1,19 +1,22 @@
 package grid
 
 import (
+	"fmt"
+	"math/rand"
+	"slices"
 	"testing"
 	"time"
 )
 
 func TestNewGridProducesConsistentUnsolvedBoard(t *testing.T) {
 	for _, dim := range []int{2, 3, 4, 5} {
-		for _, neighborhood := range [][]int{{0}, {4}, {8}, {0, 4}, {4, 8}, {0, 4, 8}} {
+		for _, neighborhood := range [][]Pattern{classic(0), classic(4), classic(8), classic(0, 4), classic(4, 8), classic(0, 4, 8)} {
 			// Repeated to exercise the "regenerate until not already won" retry loop.
 			for i := 0; i < 20; i++ {
-				g := NewGrid(dim, neighborhood)
+				g := NewGrid(Spec{Rows: dim, Cols: dim, Neighborhood: neighborhood})
 
-				if g.Dim != dim {
-					t.Fatalf("dim=%d: Dim = %d, want %d", dim, g.Dim, dim)
+				if g.Rows != dim || g.Cols != dim {
+					t.Fatalf("dim=%d: Rows x Cols = %dx%d, want %dx%d", dim, g.Rows, g.Cols, dim, dim)
 				}
 
 				board := g.GetGrid()
@@ -51,14 +54,14 @@ func TestNewGridProducesConsistentUnsolvedBoard(t *testing.T) {
 // whenever GetPossibleSolution() is non-empty, applying every move in it, in order, via
 // Switch must reach CheckWin()==true. This would have caught that bug directly, instead
 // of relying on eyeballing the rendered cheat hint. An empty solution is a separate,
-// valid case (the structurally-degenerate maxInitAttempts fallback deliberately leaves
-// it empty, since no real move sequence solves that board -- see NewGrid) and is skipped
+// valid case (the structurally-degenerate fallback deliberately leaves it empty, since
+// no real move sequence solves that board -- see NewGrid) and is skipped
 // here rather than treated as a failure to reach a win.
 func TestGetPossibleSolutionIsActuallyValid(t *testing.T) {
 	for _, dim := range []int{2, 3, 4, 5} {
-		for _, neighborhood := range [][]int{{0}, {4}, {8}, {0, 4}, {4, 8}, {0, 4, 8}} {
+		for _, neighborhood := range [][]Pattern{classic(0), classic(4), classic(8), classic(0, 4), classic(4, 8), classic(0, 4, 8)} {
 			for i := 0; i < 20; i++ {
-				g := NewGrid(dim, neighborhood)
+				g := NewGrid(Spec{Rows: dim, Cols: dim, Neighborhood: neighborhood})
 
 				solution := g.GetPossibleSolution()
 				if len(solution) == 0 {
@@ -84,17 +87,17 @@ func TestGetPossibleSolutionIsActuallyValid(t *testing.T) {
 func TestSwitchAtCorner(t *testing.T) {
 	tests := []struct {
 		name         string
-		neighborhood []int
+		neighborhood []Pattern
 		want         []int
 	}{
-		{"self", []int{0}, []int{1, 0, 0, 0, 0, 0, 0, 0, 0}},
-		{"orthogonal", []int{4}, []int{0, 1, 0, 1, 0, 0, 0, 0, 0}},
-		{"diagonal", []int{8}, []int{0, 0, 0, 0, 1, 0, 0, 0, 0}},
+		{"self", classic(0), []int{1, 0, 0, 0, 0, 0, 0, 0, 0}},
+		{"orthogonal", classic(4), []int{0, 1, 0, 1, 0, 0, 0, 0, 0}},
+		{"diagonal", classic(8), []int{0, 0, 0, 0, 1, 0, 0, 0, 0}},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			g := &Grid{Dim: 3, neighborhood: tt.neighborhood, grid: make([]int, 9)}
+			g := &Grid{Rows: 3, Cols: 3, neighborhood: tt.neighborhood, board: newBoardState(9, 2)}
 			g.Switch(0) // top-left corner
 			assertGrid(t, g, tt.want)
 		})
@@ -103,13 +106,13 @@ func TestSwitchAtCorner(t *testing.T) {
 
 // TestNewGridNeverHangsOnDegenerateNeighborhood is a regression test: a 2x2 grid
 // with every pattern enabled ({0,4,8}) makes every switch touch all 4 cells
-// uniformly, so the board can never be anything but solved. NewGrid must bail out
-// via maxInitAttempts instead of spinning forever.
+// uniformly, so the board can never be anything but solved. NewGrid must detect that
+// up front (see Analysis.Degenerate) instead of spinning forever.
 func TestNewGridNeverHangsOnDegenerateNeighborhood(t *testing.T) {
 	done := make(chan *Grid, 1)
 
 	go func() {
-		done <- NewGrid(2, []int{0, 4, 8})
+		done <- NewGrid(Spec{Rows: 2, Cols: 2, Neighborhood: classic(0, 4, 8)})
 	}()
 
 	select {
@@ -123,7 +126,7 @@ func TestNewGridNeverHangsOnDegenerateNeighborhood(t *testing.T) {
 }
 
 func TestSwitchSelfNeighborhood(t *testing.T) {
-	g := &Grid{Dim: 3, neighborhood: []int{0}, grid: make([]int, 9)}
+	g := &Grid{Rows: 3, Cols: 3, neighborhood: classic(0), board: newBoardState(9, 2)}
 
 	g.Switch(4) // center of a 3x3 grid
 
@@ -132,7 +135,7 @@ func TestSwitchSelfNeighborhood(t *testing.T) {
 }
 
 func TestSwitchPlusNeighborhood(t *testing.T) {
-	g := &Grid{Dim: 3, neighborhood: []int{4}, grid: make([]int, 9)}
+	g := &Grid{Rows: 3, Cols: 3, neighborhood: classic(4), board: newBoardState(9, 2)}
 
 	g.Switch(4) // center: flips its 4 orthogonal neighbors, not itself
 
@@ -141,7 +144,7 @@ func TestSwitchPlusNeighborhood(t *testing.T) {
 }
 
 func TestSwitchDiagonalNeighborhood(t *testing.T) {
-	g := &Grid{Dim: 3, neighborhood: []int{8}, grid: make([]int, 9)}
+	g := &Grid{Rows: 3, Cols: 3, neighborhood: classic(8), board: newBoardState(9, 2)}
 
 	g.Switch(4) // center: flips its 4 diagonal neighbors, not itself
 
@@ -150,7 +153,7 @@ func TestSwitchDiagonalNeighborhood(t *testing.T) {
 }
 
 func TestSwitchCombinedNeighborhoods(t *testing.T) {
-	g := &Grid{Dim: 3, neighborhood: []int{0, 4, 8}, grid: make([]int, 9)}
+	g := &Grid{Rows: 3, Cols: 3, neighborhood: classic(0, 4, 8), board: newBoardState(9, 2)}
 
 	g.Switch(4) // self + orthogonal + diagonal covers every cell of a 3x3 grid
 
@@ -158,9 +161,311 @@ func TestSwitchCombinedNeighborhoods(t *testing.T) {
 	assertGrid(t, g, want)
 }
 
+// TestRectangularGrid checks that rows and columns aren't mixed up anywhere on a
+// non-square board: position 5 of a 2x4 board is row 1, col 1, whose plus-shaped
+// neighbors wrap neither across rows nor past the bottom edge.
+func TestRectangularGrid(t *testing.T) {
+	g := &Grid{Rows: 2, Cols: 4, neighborhood: classic(0, 4), board: newBoardState(8, 2)}
+
+	g.Switch(5)
+
+	assertGrid(t, g, []int{0, 1, 0, 0, 1, 1, 1, 0})
+
+	board := g.GetGrid()
+	if len(board) != 2 || len(board[0]) != 4 {
+		t.Fatalf("GetGrid() on a 2x4 board = %v, want 2 rows of 4", board)
+	}
+	if board[1][1] != 1 || board[1][3] != 0 {
+		t.Fatalf("GetGrid() rows/cols transposed: %v", board)
+	}
+
+	g.Switch(8) // one past the end: out of bounds, not row 2
+	assertGrid(t, g, []int{0, 1, 0, 0, 1, 1, 1, 0})
+}
+
+func TestNewGridRectangular(t *testing.T) {
+	for _, size := range [][2]int{{5, 6}, {4, 7}, {2, 8}, {8, 3}} {
+		g := NewGrid(Spec{Rows: size[0], Cols: size[1], Neighborhood: classic(0, 4)})
+
+		if g.CheckWin() {
+			t.Fatalf("%dx%d: NewGrid produced an already-won board", size[0], size[1])
+		}
+
+		moves, ok := g.Solve()
+		if !ok {
+			t.Fatalf("%dx%d: Solve() reported a fresh board unsolvable", size[0], size[1])
+		}
+		for _, pos := range moves {
+			g.Switch(pos)
+		}
+		if !g.CheckWin() {
+			t.Fatalf("%dx%d: applying Solve() %v did not reach a win, board: %v", size[0], size[1], moves, g.GetGrid())
+		}
+	}
+}
+
+// TestSwitchOnTorus checks that offsets past an edge wrap around to the opposite one
+// instead of being discarded, on both axes and on a non-square board.
+func TestSwitchOnTorus(t *testing.T) {
+	g := &Grid{Rows: 3, Cols: 4, neighborhood: classic(0, 4), topology: TopologyTorus, board: newBoardState(12, 2)}
+
+	g.Switch(0) // top-left: left wraps to col 3, up wraps to row 2
+
+	assertGrid(t, g, []int{
+		1, 1, 0, 1,
+		1, 0, 0, 0,
+		1, 0, 0, 0,
+	})
+
+	g.Switch(0)
+	g.Switch(11) // bottom-right: right wraps to col 0, down wraps to row 0
+
+	assertGrid(t, g, []int{
+		0, 0, 0, 1,
+		0, 0, 0, 1,
+		1, 0, 1, 1,
+	})
+}
+
+// TestSwitchOnHex checks that offsets are read as axial hex directions, with odd rows
+// shifted right: the same six-direction pattern reaches the two cells above and below
+// to the right of an odd row's cell, but to the left of an even row's.
+func TestSwitchOnHex(t *testing.T) {
+	g := &Grid{Rows: 3, Cols: 3, lattice: LatticeHex, neighborhood: []Pattern{hexPattern}, board: newBoardState(9, 2)}
+
+	g.Switch(4) // center, odd row
+
+	assertGrid(t, g, []int{
+		0, 1, 1,
+		1, 0, 1,
+		0, 1, 1,
+	})
+
+	g.Switch(4)
+	g.Switch(0) // top-left, even row: only right and down-right land on the board
+
+	assertGrid(t, g, []int{
+		0, 1, 0,
+		1, 0, 0,
+		0, 0, 0,
+	})
+}
+
+// TestHexTorusNeedsEvenRows checks a hex torus is only accepted with an even number of
+// rows, and that those it accepts are true tori: every cell is its neighbors' neighbor.
+func TestHexTorusNeedsEvenRows(t *testing.T) {
+	for _, size := range [][2]int{{3, 3}, {3, 4}, {5, 6}, {1, 4}} {
+		spec := Spec{Rows: size[0], Cols: size[1], Lattice: LatticeHex, Neighborhood: []Pattern{hexPattern}, Topology: TopologyTorus}
+		if err := spec.Validate(); err == nil {
+			t.Fatalf("Validate() accepted a %dx%d hex torus", size[0], size[1])
+		}
+		spec.Topology = TopologyBounded
+		if err := spec.Validate(); err != nil {
+			t.Fatalf("Validate() rejected a %dx%d bounded hex board: %v", size[0], size[1], err)
+		}
+	}
+
+	for _, size := range [][2]int{{2, 3}, {4, 4}, {6, 5}} {
+		spec := Spec{Rows: size[0], Cols: size[1], Lattice: LatticeHex, Neighborhood: []Pattern{hexPattern}, Topology: TopologyTorus}
+		if err := spec.Validate(); err != nil {
+			t.Fatalf("Validate() rejected a %dx%d hex torus: %v", size[0], size[1], err)
+		}
+		g := &Grid{Rows: size[0], Cols: size[1], lattice: LatticeHex, topology: TopologyTorus, neighborhood: []Pattern{hexPattern}, board: newBoardState(size[0]*size[1], 2)}
+		for pos := range size[0] * size[1] {
+			for _, n := range g.affected(pos) {
+				if !slices.Contains(g.affected(n), pos) {
+					t.Fatalf("%dx%d hex torus: %d reaches %d, but not the other way round", size[0], size[1], pos, n)
+				}
+			}
+		}
+	}
+}
+
+// TestSwitchOnGraph checks a graph board presses a node and exactly its neighbors,
+// whichever way round each edge is listed.
+func TestSwitchOnGraph(t *testing.T) {
+	g := NewGrid(Spec{Graph: petersenGraph()})
+	if g.Rows != 1 || g.Cols != 10 {
+		t.Fatalf("NewGrid() on the Petersen graph = %dx%d, want 1x10", g.Rows, g.Cols)
+	}
+
+	g.board = newBoardState(10, 2)
+	g.Switch(7) // inner node: spoke to 2, pentagram edges to 5 and 9
+
+	assertGrid(t, g, []int{0, 0, 1, 0, 0, 1, 0, 1, 0, 1})
+
+	g.Switch(10) // past the last node
+	assertGrid(t, g, []int{0, 0, 1, 0, 0, 1, 0, 1, 0, 1})
+}
+
+func TestNewGridGraph(t *testing.T) {
+	for _, graph := range []*Graph{pathGraph(5), cycleGraph(8), petersenGraph()} {
+		for range 10 {
+			g := NewGrid(Spec{Graph: graph})
+			if g.CheckWin() {
+				t.Fatalf("%s: NewGrid() dealt an already-won board", graph.Name)
+			}
+			if !applyAndCheckWin(g, g.GetPossibleSolution()) {
+				t.Fatalf("%s: GetPossibleSolution() did not win the dealt board", graph.Name)
+			}
+		}
+	}
+}
+
+// TestSwitchMultiState checks Switch advances affected cells by one mod k rather than
+// flipping them, that Unswitch undoes it, and that k presses are a no-op.
+func TestSwitchMultiState(t *testing.T) {
+	g := &Grid{Rows: 3, Cols: 3, neighborhood: classic(0, 4), states: 3, board: newBoardState(9, 3)}
+
+	g.Switch(4)
+	g.Switch(4)
+	assertGrid(t, g, []int{0, 2, 0, 2, 2, 2, 0, 2, 0})
+
+	g.Unswitch(4)
+	assertGrid(t, g, []int{0, 1, 0, 1, 1, 1, 0, 1, 0})
+
+	g.Switch(4)
+	g.Switch(4)
+	assertGrid(t, g, make([]int, 9))
+}
+
+func TestCheckWinMultiState(t *testing.T) {
+	g := &Grid{Rows: 2, Cols: 2, states: 5, board: boardFrom([]int{3, 3, 3, 3}, 5)}
+	if !g.CheckWin() {
+		t.Fatal("CheckWin() rejected a uniform board of a non-zero state")
+	}
+
+	g.board.values[2] = 4
+	if g.CheckWin() {
+		t.Fatal("CheckWin() accepted a non-uniform board")
+	}
+}
+
+func TestNewGridMultiState(t *testing.T) {
+	for _, states := range SupportedStates {
+		for i := 0; i < 20; i++ {
+			g := NewGrid(Spec{Rows: 3, Cols: 4, Neighborhood: classic(0, 4), States: states})
+
+			if g.CheckWin() {
+				t.Fatalf("states=%d: NewGrid produced an already-won board", states)
+			}
+			for _, row := range g.GetGrid() {
+				for _, cell := range row {
+					if cell < 0 || cell >= states {
+						t.Fatalf("states=%d: cell state %d out of range, board: %v", states, cell, g.GetGrid())
+					}
+				}
+			}
+
+			if solution := g.GetPossibleSolution(); len(solution) > 0 && !applyAndCheckWin(g, solution) {
+				t.Fatalf("states=%d: applying GetPossibleSolution() %v did not reach a win, board: %v", states, solution, g.GetGrid())
+			}
+		}
+	}
+}
+
+// TestNewGridFromSeedIsDeterministic checks the same spec and seed always deal the same
+// board and solution, on every kind of board, and that Seed reports the seed dealt from.
+func TestNewGridFromSeedIsDeterministic(t *testing.T) {
+	for _, spec := range []Spec{
+		{Rows: 5, Cols: 5, Neighborhood: classic(0, 4)},
+		{Rows: 3, Cols: 4, Neighborhood: classic(0, 8), Topology: TopologyTorus, States: 3},
+		{Rows: 4, Cols: 4, Lattice: LatticeHex, Neighborhood: []Pattern{hexPattern}},
+		{Graph: petersenGraph()},
+		{Rows: 5, Cols: 5, Neighborhood: classic(0, 4), Difficulty: DifficultyHard},
+		{Rows: 4, Cols: 4, Neighborhood: classic(0, 4), Target: TargetRandom},
+	} {
+		for _, seed := range []int64{0, 1, -42, 1 << 62} {
+			a, b := NewGridFromSeed(spec, seed), NewGridFromSeed(spec, seed)
+			if a.Seed() != seed {
+				t.Fatalf("%+v: Seed() = %d, want %d", spec, a.Seed(), seed)
+			}
+			if fmt.Sprint(a.GetGrid()) != fmt.Sprint(b.GetGrid()) || fmt.Sprint(a.GetPossibleSolution()) != fmt.Sprint(b.GetPossibleSolution()) {
+				t.Fatalf("%+v seed %d: dealt %v and %v", spec, seed, a.GetGrid(), b.GetGrid())
+			}
+		}
+	}
+
+	// Not a guarantee for any two seeds, but a 5x5 board has far too many scrambles for
+	// these three to collide by chance.
+	spec := Spec{Rows: 5, Cols: 5, Neighborhood: classic(0, 4)}
+	boards := map[string]bool{}
+	for _, seed := range []int64{1, 2, 3} {
+		boards[fmt.Sprint(NewGridFromSeed(spec, seed).GetGrid())] = true
+	}
+	if len(boards) != 3 {
+		t.Fatal("NewGridFromSeed() dealt the same board from different seeds")
+	}
+}
+
+// TestRestart checks Restart deals g's board as it was before any moves, with no
+// history, and leaves g itself alone.
+func TestRestart(t *testing.T) {
+	for _, spec := range []Spec{
+		{Rows: 4, Cols: 4, Neighborhood: classic(0, 4)},
+		{Rows: 3, Cols: 3, Neighborhood: classic(0, 4), States: 5},
+	} {
+		g := NewGridFromSeed(spec, 9)
+		dealt := fmt.Sprint(g.GetGrid())
+
+		g.Switch(0)
+		g.RecordMove(0)
+		played := fmt.Sprint(g.GetGrid())
+
+		r := g.Restart()
+		if got := fmt.Sprint(r.GetGrid()); got != dealt {
+			t.Fatalf("%+v: Restart() board = %s, want the dealt %s", spec, got, dealt)
+		}
+		if r.GetMoveLog() != nil || r.GetPreviousMoves() != nil {
+			t.Fatalf("%+v: Restart() kept the move history", spec)
+		}
+
+		r.Switch(1)
+		if got := fmt.Sprint(g.GetGrid()); got != played || len(g.GetMoveLog()) != 1 {
+			t.Fatalf("%+v: playing on Restart()'s Grid changed the original", spec)
+		}
+	}
+}
+
+func TestDailySeed(t *testing.T) {
+	morning := time.Date(2026, 3, 14, 0, 0, 1, 0, time.UTC)
+	night := time.Date(2026, 3, 14, 23, 59, 59, 0, time.UTC)
+	// Still the 14th in UTC, though already the 15th locally.
+	tokyo := time.Date(2026, 3, 15, 8, 0, 0, 0, time.FixedZone("JST", 9*60*60))
+
+	if DailySeed(morning) != DailySeed(night) || DailySeed(morning) != DailySeed(tokyo) {
+		t.Fatal("DailySeed() differs within a single UTC day")
+	}
+	if DailySeed(morning) == DailySeed(morning.AddDate(0, 0, 1)) {
+		t.Fatal("DailySeed() is the same on consecutive days")
+	}
+}
+
+func TestParseTopology(t *testing.T) {
+	for in, want := range map[string]Topology{"": TopologyBounded, "bounded": TopologyBounded, "torus": TopologyTorus} {
+		if got, err := ParseTopology(in); err != nil || got != want {
+			t.Errorf("ParseTopology(%q) = %q, %v, want %q, nil", in, got, err, want)
+		}
+	}
+	if _, err := ParseTopology("klein"); err == nil {
+		t.Error("ParseTopology(\"klein\") accepted an unknown topology")
+	}
+}
+
+func TestParseLattice(t *testing.T) {
+	for in, want := range map[string]Lattice{"": LatticeSquare, "square": LatticeSquare, "hex": LatticeHex} {
+		if got, err := ParseLattice(in); err != nil || got != want {
+			t.Errorf("ParseLattice(%q) = %q, %v, want %q, nil", in, got, err, want)
+		}
+	}
+	if _, err := ParseLattice("triangle"); err == nil {
+		t.Error("ParseLattice(\"triangle\") accepted an unknown lattice")
+	}
+}
+
 func TestSwitchOutOfBoundsIsNoOp(t *testing.T) {
 	for _, pos := range []int{-1, -100, 9, 100} {
-		g := &Grid{Dim: 3, neighborhood: []int{0, 4, 8}, grid: make([]int, 9)}
+		g := &Grid{Rows: 3, Cols: 3, neighborhood: classic(0, 4, 8), board: newBoardState(9, 2)}
 
 		g.Switch(pos)
 
@@ -169,14 +474,14 @@ func TestSwitchOutOfBoundsIsNoOp(t *testing.T) {
 }
 
 func TestGetGridReturnsDefensiveCopy(t *testing.T) {
-	g := &Grid{Dim: 2, grid: []int{1, 0, 0, 1}}
+	g := &Grid{Rows: 2, Cols: 2, board: boardFrom([]int{1, 0, 0, 1}, 2)}
 
 	board := g.GetGrid()
 	board[0][0] = 99
 	board[1][1] = 42
 
-	if g.grid[0] != 1 || g.grid[3] != 1 {
-		t.Fatalf("mutating GetGrid()'s result affected the underlying grid: %v", g.grid)
+	if g.board.get(0) != 1 || g.board.get(3) != 1 {
+		t.Fatalf("mutating GetGrid()'s result affected the underlying grid: %v", g.board.cells())
 	}
 }
 
@@ -248,6 +553,25 @@ func TestRecordMoveTogglesMembership(t *testing.T) {
 	}
 }
 
+// TestRecordMoveCancelsAfterKPresses covers RecordMove on a multi-state board: a cell
+// only drops out of the history once it's been pressed k times, which is when its
+// presses cancel out on the board.
+func TestRecordMoveCancelsAfterKPresses(t *testing.T) {
+	g := &Grid{states: 3}
+
+	g.RecordMove(0)
+	g.RecordMove(4)
+	g.RecordMove(0)
+	if moves := g.GetPreviousMoves(); len(moves) != 3 || moves[0] != 0 || moves[1] != 4 || moves[2] != 0 {
+		t.Fatalf("after pressing 0, 4, 0, moves = %v, want [0 4 0]", moves)
+	}
+
+	g.RecordMove(0)
+	if moves := g.GetPreviousMoves(); len(moves) != 1 || moves[0] != 4 {
+		t.Fatalf("after pressing 0 a third time, moves = %v, want [4]", moves)
+	}
+}
+
 // TestPopLastMove is a regression test for RevertMove's undo semantics: it must pop
 // the most recently recorded move and report ok=false once history is empty, rather
 // than the old bare-nil-slice check.
@@ -278,6 +602,92 @@ func TestPopLastMove(t *testing.T) {
 	}
 }
 
+// TestPopLastMoveFollowsClicks checks undo walks back through the presses actually
+// made, not the net-effect view: a cell clicked twice has dropped out of
+// GetPreviousMoves, but is still undone one click at a time.
+func TestPopLastMoveFollowsClicks(t *testing.T) {
+	g := &Grid{}
+
+	g.RecordMove(3)
+	g.RecordMove(0)
+	g.RecordMove(0)
+	if moves := g.GetPreviousMoves(); len(moves) != 1 || moves[0] != 3 {
+		t.Fatalf("after pressing 3, 0, 0, moves = %v, want [3]", moves)
+	}
+	if log := g.GetMoveLog(); fmt.Sprint(log) != "[3 0 0]" {
+		t.Fatalf("GetMoveLog() = %v, want [3 0 0]", log)
+	}
+
+	for _, want := range []string{"[3 0]", "[3]"} {
+		if pos, ok := g.PopLastMove(); !ok || pos != 0 {
+			t.Fatalf("PopLastMove() = (%d, %v), want (0, true)", pos, ok)
+		}
+		if moves := g.GetPreviousMoves(); fmt.Sprint(moves) != want {
+			t.Fatalf("after undoing a press of 0, moves = %v, want %s", moves, want)
+		}
+	}
+}
+
+// TestRedoMove checks redo replays undone presses in order, that the net-effect view
+// follows along, and that a new move discards whatever was left to redo.
+func TestRedoMove(t *testing.T) {
+	g := &Grid{states: 3}
+
+	if _, ok := g.RedoMove(); ok {
+		t.Fatal("RedoMove() on a fresh Grid should report ok=false")
+	}
+
+	for _, pos := range []int{1, 2, 1, 1} {
+		g.RecordMove(pos)
+	}
+	before := fmt.Sprint(g.GetPreviousMoves())
+	for range 3 {
+		g.PopLastMove()
+	}
+	if g.RedoDepth() != 3 {
+		t.Fatalf("RedoDepth() = %d after three undos, want 3", g.RedoDepth())
+	}
+
+	for _, want := range []int{2, 1, 1} {
+		if pos, ok := g.RedoMove(); !ok || pos != want {
+			t.Fatalf("RedoMove() = (%d, %v), want (%d, true)", pos, ok, want)
+		}
+	}
+	if got := fmt.Sprint(g.GetPreviousMoves()); got != before {
+		t.Fatalf("after undoing and redoing, moves = %s, want %s", got, before)
+	}
+	if _, ok := g.RedoMove(); ok {
+		t.Fatal("RedoMove() past the end of the log should report ok=false")
+	}
+
+	g.PopLastMove()
+	g.RecordMove(5)
+	if g.RedoDepth() != 0 {
+		t.Fatalf("RedoDepth() = %d after a new move, want 0", g.RedoDepth())
+	}
+	if log := g.GetMoveLog(); fmt.Sprint(log) != "[1 2 1 5]" {
+		t.Fatalf("GetMoveLog() = %v, want [1 2 1 5]", log)
+	}
+}
+
+// TestNetMovesMatchesRecordNet checks netMoves' one-pass rebuild of the net-effect
+// history agrees with building it press by press.
+func TestNetMovesMatchesRecordNet(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, k := range []int{2, 3, 5} {
+		g := &Grid{states: k}
+		var log []int
+		for range 200 {
+			pos := rng.Intn(4)
+			log = append(log, pos)
+			g.recordNet(pos)
+			if got, want := fmt.Sprint(netMoves(log, k)), fmt.Sprint(g.moveHistory); got != want {
+				t.Fatalf("k=%d, log %v: netMoves() = %s, want %s", k, log, got, want)
+			}
+		}
+	}
+}
+
 func TestCheckWin(t *testing.T) {
 	tests := []struct {
 		name string
@@ -291,7 +701,7 @@ func TestCheckWin(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			g := &Grid{Dim: 2, grid: tt.grid}
+			g := &Grid{Rows: 2, Cols: 2, board: boardFrom(tt.grid, 2)}
 			if got := g.CheckWin(); got != tt.want {
 				t.Errorf("CheckWin() = %v, want %v", got, tt.want)
 			}
@@ -299,55 +709,117 @@ func TestCheckWin(t *testing.T) {
 	}
 }
 
-// TestNewGridDimEdgeCases documents NewGrid's behavior at the edges of its exported
-// contract (dim=0, dim=1) -- none of these are reachable via the HTTP API, since
-// utils.ParseDim clamps to [2,5], but NewGrid itself has no such guard, so this pins
-// down the actual behavior for any other caller.
-func TestNewGridDimEdgeCases(t *testing.T) {
-	t.Run("dim=0 panics", func(t *testing.T) {
+// TestNewGridSizeEdgeCases documents NewGrid's behavior at the edges of its exported
+// contract (a zero side, 1x1) -- none of these are reachable via the HTTP API, since
+// utils.ParseSize clamps each side to [2,8], but NewGrid itself has no such guard, so
+// this pins down the actual behavior for any other caller.
+func TestNewGridSizeEdgeCases(t *testing.T) {
+	t.Run("zero side panics", func(t *testing.T) {
 		defer func() {
 			if recover() == nil {
-				t.Fatal("NewGrid(0, ...) did not panic (rand.Intn(0) is documented to)")
+				t.Fatal("NewGrid with a zero side did not panic (rand.Intn(0) is documented to)")
 			}
 		}()
-		NewGrid(0, []int{0})
+		NewGrid(Spec{Rows: 0, Cols: 3, Neighborhood: classic(0)})
 	})
 
-	t.Run("dim=1 is tautologically always won", func(t *testing.T) {
-		g := NewGrid(1, []int{0})
+	t.Run("1x1 is tautologically always won", func(t *testing.T) {
+		g := NewGrid(Spec{Rows: 1, Cols: 1, Neighborhood: classic(0)})
 		if !g.CheckWin() {
-			t.Fatal("dim=1's single cell should always satisfy CheckWin() (sum is always 0 or Dim*Dim)")
+			t.Fatal("1x1's single cell should always satisfy CheckWin() (sum is always 0 or Rows*Cols)")
 		}
 	})
 }
 
-// TestSwitchWithDuplicateOrUnknownNeighborhood documents Switch's actual behavior for
-// neighborhood values outside {0,4,8} or containing duplicates -- not reachable via the
-// HTTP API today (utils.ParseNeighborhood rejects both), but Switch itself has no
-// validation of its own, so this pins down what a direct caller actually gets.
-func TestSwitchWithDuplicateOrUnknownNeighborhood(t *testing.T) {
+// TestSwitchWithOverlappingNeighborhood documents Switch's actual behavior when the
+// same offset is reached more than once -- a pattern listed twice, or two different
+// patterns sharing an offset. Not reachable for a duplicated pattern via the HTTP API
+// (utils.ParseNeighborhood rejects it), but Switch itself has no validation of its own,
+// so this pins down what a direct caller actually gets.
+func TestSwitchWithOverlappingNeighborhood(t *testing.T) {
 	t.Run("duplicate pattern cancels out to a no-op", func(t *testing.T) {
-		g := &Grid{Dim: 3, neighborhood: []int{4, 4}, grid: make([]int, 9)}
+		g := &Grid{Rows: 3, Cols: 3, neighborhood: classic(4, 4), board: newBoardState(9, 2)}
 		g.Switch(4)
 		assertGrid(t, g, make([]int, 9)) // each orthogonal neighbor toggled twice = unchanged
 	})
 
-	t.Run("unknown pattern value is silently ignored", func(t *testing.T) {
-		g := &Grid{Dim: 3, neighborhood: []int{99}, grid: make([]int, 9)}
+	t.Run("shared offset across patterns cancels out", func(t *testing.T) {
+		plusWithCenter := Pattern{Name: "plus", Offsets: [][2]int{{0, 0}, {1, 0}, {0, 1}, {-1, 0}, {0, -1}}}
+		g := &Grid{Rows: 3, Cols: 3, neighborhood: append(classic(0), plusWithCenter), board: newBoardState(9, 2)}
 		g.Switch(4)
-		assertGrid(t, g, make([]int, 9)) // no case in Switch's switch matches 99
+		assertGrid(t, g, []int{0, 1, 0, 1, 0, 1, 0, 1, 0}) // center flipped by both patterns
 	})
 }
 
+// TestSwitchCustomPattern exercises a config-defined pattern the old hardcoded
+// 0/4/8 switch could never express: knight moves, clipped at a corner.
+func TestSwitchCustomPattern(t *testing.T) {
+	knight := Pattern{Name: "knight", Offsets: [][2]int{
+		{1, 2}, {2, 1}, {-1, 2}, {-2, 1}, {1, -2}, {2, -1}, {-1, -2}, {-2, -1},
+	}}
+	g := &Grid{Rows: 3, Cols: 3, neighborhood: []Pattern{knight}, board: newBoardState(9, 2)}
+
+	g.Switch(0) // top-left: only (1,2) and (2,1) land on the board
+
+	assertGrid(t, g, []int{0, 0, 0, 0, 0, 1, 0, 1, 0})
+}
+
+// The classic patterns config.json ships with, keyed by their config names.
+var classicPatterns = map[int]Pattern{
+	0: {Name: "0", Offsets: [][2]int{{0, 0}}},
+	4: {Name: "4", Offsets: [][2]int{{1, 0}, {0, 1}, {-1, 0}, {0, -1}}},
+	8: {Name: "8", Offsets: [][2]int{{1, 1}, {-1, -1}, {1, -1}, {-1, 1}}},
+}
+
+// hexPattern is the six-direction pattern config.json ships with for hex boards.
+var hexPattern = Pattern{Name: "hex", Offsets: [][2]int{{1, 0}, {1, -1}, {0, -1}, {-1, 0}, {-1, 1}, {0, 1}}}
+
+// pathGraph, cycleGraph and petersenGraph build small graph boards for tests.
+func pathGraph(n int) *Graph {
+	g := &Graph{Name: fmt.Sprintf("path%d", n), Nodes: n}
+	for i := range n - 1 {
+		g.Edges = append(g.Edges, [2]int{i, i + 1})
+	}
+	return g
+}
+
+func cycleGraph(n int) *Graph {
+	g := pathGraph(n)
+	g.Name = fmt.Sprintf("cycle%d", n)
+	g.Edges = append(g.Edges, [2]int{n - 1, 0})
+	return g
+}
+
+// petersenGraph is nodes 0-4 as an outer pentagon, 5-9 as an inner pentagram, and a
+// spoke joining each i to i+5.
+func petersenGraph() *Graph {
+	g := &Graph{Name: "petersen", Nodes: 10}
+	for i := range 5 {
+		g.Edges = append(g.Edges, [2]int{i, (i + 1) % 5}, [2]int{i, i + 5}, [2]int{5 + (i+2)%5, 5 + i})
+	}
+	return g
+}
+
+// classic builds a neighborhood from the classic patterns' names, e.g. classic(0, 4)
+// for self + orthogonal, so tables of neighborhoods stay as compact as the config's.
+func classic(names ...int) []Pattern {
+	patterns := make([]Pattern, len(names))
+	for i, name := range names {
+		patterns[i] = classicPatterns[name]
+	}
+	return patterns
+}
+
 func assertGrid(t *testing.T, g *Grid, want []int) {
 	t.Helper()
 
-	if len(g.grid) != len(want) {
-		t.Fatalf("grid length = %d, want %d", len(g.grid), len(want))
+	got := g.board.cells()
+	if len(got) != len(want) {
+		t.Fatalf("grid length = %d, want %d", len(got), len(want))
 	}
 	for i := range want {
-		if g.grid[i] != want[i] {
-			t.Fatalf("grid = %v, want %v", g.grid, want)
+		if got[i] != want[i] {
+			t.Fatalf("grid = %v, want %v", got, want)
 		}
 	}
 }
@@ -0,0 +1,240 @@
+package grid
+
+import (
+	"fmt"
+	"slices"
+)
+
+// MaxLitOnlyCells caps a LitOnly board's size. Its reachable boards aren't a linear
+// space the way an unrestricted board's are, so its solver searches board states
+// directly, each packed into a single machine word.
+const MaxLitOnlyCells = 64
+
+// maxLitOnlyScramble bounds how many presses initGame walks back from a won LitOnly
+// board, which keeps a deal's solution short enough for searchLitOnly to find within
+// its budget.
+const maxLitOnlyScramble = 10
+
+// maxLitOnlyStates bounds how many board states searchLitOnly visits, both ends
+// together, before giving up.
+const maxLitOnlyStates = 1 << 15
+
+// validateLitOnly reports whether a board of n cells with k states can be played lit
+// cells only: the rule is defined for on/off cells, and the solver needs a word per
+// board (see MaxLitOnlyCells).
+func validateLitOnly(n, k int) error {
+	if k != 2 {
+		return fmt.Errorf("a lit-only board must have 2 states per cell, got %d", k)
+	}
+	if n > MaxLitOnlyCells {
+		return fmt.Errorf("a lit-only board can have at most %d cells, got %d", MaxLitOnlyCells, n)
+	}
+	return nil
+}
+
+// CanSwitch reports whether Switch would press pos: it's on the board and, on a
+// LitOnly board, lit.
+func (g *Grid) CanSwitch(pos int) bool {
+	if pos < 0 || pos >= g.board.n {
+		return false
+	}
+	return !g.litOnly || g.board.get(pos) != 0
+}
+
+// LitOnly reports whether g only lets lit cells be pressed.
+func (g *Grid) LitOnly() bool {
+	return g.litOnly
+}
+
+// litMasks returns each press's effect as a single word, bit i for cell i; only valid
+// on a LitOnly board (see validateLitOnly).
+func (g *Grid) litMasks() []uint64 {
+	masks := make([]uint64, g.board.n)
+	for pos, m := range g.toggles().masks {
+		masks[pos] = m[0]
+	}
+	return masks
+}
+
+// unpress reports whether the board before pressing pos could have been board^m --
+// pos has to have been lit in it -- which is what running a lit-only game backwards
+// from board has to respect.
+func unpress(board, m uint64, pos int) bool {
+	return (board^m)&(1<<uint(pos)) != 0
+}
+
+// scrambleLitOnly is initGame's scramble for a LitOnly board, starting from the won
+// board it has just dealt. Pressing a random set of cells can't work here: most
+// orders of it would press an unlit cell somewhere along the way, and no order might
+// not. It walks backwards instead, undoing one random press at a time that could have
+// been legal, so the presses it undid, replayed forwards, are a legal solution --
+// whose order, unlike anywhere else, matters.
+func (g *Grid) scrambleLitOnly() {
+	masks := g.litMasks()
+	board := g.board.lit[0]
+	steps := 1 + g.rand.Intn(min(g.board.n, maxLitOnlyScramble))
+
+	g.solution = []int{}
+	candidates := make([]int, 0, len(masks))
+	for range steps {
+		candidates = candidates[:0]
+		for pos, m := range masks {
+			if unpress(board, m, pos) {
+				candidates = append(candidates, pos)
+			}
+		}
+		if len(candidates) == 0 {
+			break
+		}
+		pos := candidates[g.rand.Intn(len(candidates))]
+		board ^= masks[pos]
+		g.solution = append(g.solution, pos)
+	}
+	slices.Reverse(g.solution)
+	g.board.lit[0] = board
+}
+
+// unsolveLitOnly is unsolveWithOnePress for a LitOnly board: it undoes a single press
+// that could have been legal and leaves the board unsolved, recording that press as
+// the solution.
+func (g *Grid) unsolveLitOnly() {
+	masks := g.litMasks()
+	for pos, m := range masks {
+		if !unpress(g.board.lit[0], m, pos) {
+			continue
+		}
+		g.board.lit[0] ^= m
+		if !g.CheckWin() {
+			g.solution = []int{pos}
+			return
+		}
+		g.board.lit[0] ^= m
+	}
+}
+
+// solveLitOnly is Solve and OptimalSolution for a LitOnly board: the shortest legal
+// press sequence searchLitOnly finds to any of the win targets. If the search runs
+// out of budget on the board as dealt, or on one the player has reached by following
+// its solution, the rest of the scramble's own solution -- legal, if not necessarily
+// the shortest -- is returned instead; otherwise ok is false, and exhausted says
+// whether that's because the search gave up rather than because no sequence exists.
+func (g *Grid) solveLitOnly() (moves []int, ok, exhausted bool) {
+	targets := g.winTargets()
+	words := make([]uint64, len(targets))
+	for i, t := range targets {
+		words[i] = t.lit[0]
+	}
+
+	moves, ok, exhausted = searchLitOnly(g.litMasks(), g.board.lit[0], words)
+	if ok {
+		return moves, true, false
+	}
+
+	// g.initial is only set once dealing is done; until then, the board is always the
+	// one g.solution was recorded for.
+	if g.solution != nil && g.initial.n == 0 {
+		return append([]int(nil), g.solution...), true, false
+	}
+	masks, board := g.litMasks(), g.initial.lit[0]
+	for i, pos := range g.solution {
+		if board == g.board.lit[0] {
+			return append([]int(nil), g.solution[i:]...), true, false
+		}
+		board ^= masks[pos]
+	}
+	return nil, false, exhausted
+}
+
+// litVisit is how searchLitOnly reached a board: its depth from that end of the
+// search, and the neighbouring board and press towards the other end (from start,
+// the board it was pressed from; from a target, the board pressing pos leads to).
+// pos is -1 at the ends themselves.
+type litVisit struct {
+	depth int
+	via   uint64
+	pos   int
+}
+
+// searchLitOnly returns the shortest sequence of presses, each on a cell lit at the
+// time, taking start to any of targets: a breadth-first search from both ends at
+// once, always growing whichever frontier is smaller by a full level. Every board a
+// level reaches that the other end has seen is a candidate, and the shortest of them
+// is the answer -- one found earlier in the level can be a press longer than one
+// found later. ok is false if none of targets is found: exhausted if the search
+// visited maxLitOnlyStates boards first, not if there was nowhere left to search.
+func searchLitOnly(masks []uint64, start uint64, targets []uint64) (moves []int, ok, exhausted bool) {
+	forward := map[uint64]litVisit{start: {pos: -1}}
+	backward := map[uint64]litVisit{}
+	for _, t := range targets {
+		backward[t] = litVisit{pos: -1}
+	}
+	if _, won := backward[start]; won {
+		return []int{}, true, false
+	}
+
+	fromStart, fromTargets := []uint64{start}, targets
+	for len(fromStart) > 0 && len(fromTargets) > 0 {
+		var next []uint64
+		meet, best := uint64(0), -1
+
+		if len(fromStart) <= len(fromTargets) {
+			for _, board := range fromStart {
+				depth := forward[board].depth + 1
+				for pos, m := range masks {
+					if board&(1<<uint(pos)) == 0 {
+						continue
+					}
+					if _, seen := forward[board^m]; seen {
+						continue
+					}
+					forward[board^m] = litVisit{depth: depth, via: board, pos: pos}
+					next = append(next, board^m)
+					if other, found := backward[board^m]; found && (best < 0 || depth+other.depth < best) {
+						meet, best = board^m, depth+other.depth
+					}
+				}
+			}
+			fromStart = next
+		} else {
+			for _, board := range fromTargets {
+				depth := backward[board].depth + 1
+				for pos, m := range masks {
+					if !unpress(board, m, pos) {
+						continue
+					}
+					if _, seen := backward[board^m]; seen {
+						continue
+					}
+					backward[board^m] = litVisit{depth: depth, via: board, pos: pos}
+					next = append(next, board^m)
+					if other, found := forward[board^m]; found && (best < 0 || depth+other.depth < best) {
+						meet, best = board^m, depth+other.depth
+					}
+				}
+			}
+			fromTargets = next
+		}
+
+		if best >= 0 {
+			return litPath(forward, backward, meet), true, false
+		}
+		if len(forward)+len(backward) > maxLitOnlyStates {
+			return nil, false, true
+		}
+	}
+	return nil, false, false
+}
+
+// litPath joins searchLitOnly's two halves at meet: the presses from start to meet,
+// then from meet on to a target.
+func litPath(forward, backward map[uint64]litVisit, meet uint64) []int {
+	var moves []int
+	for v := forward[meet]; v.pos >= 0; v = forward[v.via] {
+		moves = append(moves, v.pos)
+	}
+	slices.Reverse(moves)
+	for v := backward[meet]; v.pos >= 0; v = backward[v.via] {
+		moves = append(moves, v.pos)
+	}
+	return moves
+}
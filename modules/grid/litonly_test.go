@@ -0,0 +1,164 @@
+package grid
+
+import (
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+func TestLitOnlySwitch(t *testing.T) {
+	g := NewGridFromSeed(Spec{Rows: 3, Cols: 3, Neighborhood: classic(0, 4), LitOnly: true}, 1)
+	g.board = boardFrom([]int{1, 0, 0, 0, 0, 0, 0, 0, 0}, 2)
+
+	if g.CanSwitch(4) || g.Switch(4) {
+		t.Fatal("Switch() pressed an unlit cell on a lit-only board")
+	}
+	if got := g.board.cells(); !slices.Equal(got, []int{1, 0, 0, 0, 0, 0, 0, 0, 0}) {
+		t.Fatalf("a refused Switch() changed the board to %v", got)
+	}
+
+	if !g.Switch(0) {
+		t.Fatal("Switch() refused a lit cell")
+	}
+	if got := g.board.cells(); !slices.Equal(got, []int{0, 1, 0, 1, 0, 0, 0, 0, 0}) {
+		t.Fatalf("Switch(0) left %v", got)
+	}
+
+	// Undo isn't a move: it has to work whatever the cell shows now.
+	g.Unswitch(0)
+	if got := g.board.cells(); !slices.Equal(got, []int{1, 0, 0, 0, 0, 0, 0, 0, 0}) {
+		t.Fatalf("Unswitch(0) left %v", got)
+	}
+}
+
+// TestNewGridLitOnly checks every lit-only deal is winnable by legal moves alone: both
+// the scramble's own solution and the solver's, played in order, press only lit cells
+// and end won.
+func TestNewGridLitOnly(t *testing.T) {
+	for _, spec := range []Spec{
+		{Rows: 3, Cols: 3, Neighborhood: classic(0, 4), LitOnly: true},
+		{Rows: 4, Cols: 5, Neighborhood: classic(0, 4), Topology: TopologyTorus, LitOnly: true},
+		{Rows: 4, Cols: 4, Neighborhood: classic(4), LitOnly: true},
+		{Rows: 5, Cols: 5, Neighborhood: classic(0, 4), Target: "heart", LitOnly: true},
+		{Rows: 8, Cols: 8, Neighborhood: classic(0, 4), LitOnly: true},
+		{Graph: petersenGraph(), LitOnly: true},
+	} {
+		for seed := range int64(5) {
+			g := NewGridFromSeed(spec, seed)
+			if g.CheckWin() {
+				t.Fatalf("%dx%d seed %d: dealt an already-won board", g.Rows, g.Cols, seed)
+			}
+
+			dealt := g.GetPossibleSolution()
+			solved, ok := g.OptimalSolution()
+			if !ok {
+				t.Fatalf("%dx%d seed %d: no solution to a dealt lit-only board", g.Rows, g.Cols, seed)
+			}
+			if len(solved) > len(dealt) {
+				t.Fatalf("%dx%d seed %d: solver found %v, longer than the scramble's %v", g.Rows, g.Cols, seed, solved, dealt)
+			}
+
+			for _, moves := range [][]int{dealt, solved} {
+				play := g.Restart()
+				for _, pos := range moves {
+					if !play.Switch(pos) {
+						t.Fatalf("%dx%d seed %d: solution %v presses unlit cell %d", g.Rows, g.Cols, seed, moves, pos)
+					}
+				}
+				if !play.CheckWin() {
+					t.Fatalf("%dx%d seed %d: solution %v didn't win", g.Rows, g.Cols, seed, moves)
+				}
+			}
+		}
+	}
+}
+
+// TestSearchLitOnlyIsShortest checks the two-ended search against a plain breadth-first
+// search from the start alone, on random 3x3 boards.
+func TestSearchLitOnlyIsShortest(t *testing.T) {
+	g := NewGridFromSeed(Spec{Rows: 3, Cols: 3, Neighborhood: classic(0, 4), LitOnly: true}, 1)
+	masks := g.litMasks()
+	targets := []uint64{0, 1<<9 - 1}
+
+	// depths returns every board's distance from start under lit-only presses.
+	depths := func(start uint64) map[uint64]int {
+		seen := map[uint64]int{start: 0}
+		for queue := []uint64{start}; len(queue) > 0; queue = queue[1:] {
+			board := queue[0]
+			for pos, m := range masks {
+				if _, ok := seen[board^m]; board&(1<<uint(pos)) != 0 && !ok {
+					seen[board^m] = seen[board] + 1
+					queue = append(queue, board^m)
+				}
+			}
+		}
+		return seen
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	for range 50 {
+		start := uint64(rng.Intn(1 << 9))
+		want, reachable := -1, depths(start)
+		for _, target := range targets {
+			if d, ok := reachable[target]; ok && (want < 0 || d < want) {
+				want = d
+			}
+		}
+
+		moves, ok, exhausted := searchLitOnly(masks, start, targets)
+		if ok != (want >= 0) || (ok && len(moves) != want) || exhausted {
+			t.Fatalf("searchLitOnly(%09b) = %v, %v, want %d presses", start, moves, ok, want)
+		}
+	}
+}
+
+// TestLitOnlyBeyondSearchBudget checks a board too far from a win to search isn't
+// reported unwinnable, and that following Hint from there -- undoing back towards the
+// dealt board, then along its solution -- still wins.
+func TestLitOnlyBeyondSearchBudget(t *testing.T) {
+	g := NewGridFromSeed(Spec{Rows: 8, Cols: 8, Neighborhood: classic(0, 4), LitOnly: true}, 0)
+	rng := rand.New(rand.NewSource(0))
+	for known := true; known; {
+		if pos := rng.Intn(g.board.n); g.Switch(pos) {
+			g.RecordMove(pos)
+		}
+		_, known = g.Solvable()
+	}
+	if solvable, _ := g.Solvable(); solvable {
+		t.Fatal("Solvable() = true, but OptimalSolution found no solution")
+	}
+
+	for steps := 0; !g.CheckWin(); steps++ {
+		if steps > 100 {
+			t.Fatal("following Hint() didn't win within 100 steps")
+		}
+		pos, undo, ok := g.Hint()
+		switch {
+		case !ok:
+			t.Fatalf("Hint() gave up on a winnable board after %d steps", steps)
+		case undo:
+			pos, _ = g.PopLastMove()
+			g.Unswitch(pos)
+		case !g.Switch(pos):
+			t.Fatalf("Hint() = %d, an unlit cell", pos)
+		default:
+			g.RecordMove(pos)
+		}
+	}
+}
+
+func TestValidateLitOnly(t *testing.T) {
+	for _, tt := range []struct {
+		spec    Spec
+		wantErr bool
+	}{
+		{Spec{Rows: 8, Cols: 8, LitOnly: true}, false},
+		{Spec{Rows: 8, Cols: 9, LitOnly: true}, true},
+		{Spec{Rows: 3, Cols: 3, States: 3, LitOnly: true}, true},
+		{Spec{Graph: petersenGraph(), LitOnly: true}, false},
+	} {
+		if err := tt.spec.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("Validate(%dx%d, %d states, lit-only) = %v, want error %v", tt.spec.Rows, tt.spec.Cols, tt.spec.NumStates(), err, tt.wantErr)
+		}
+	}
+}
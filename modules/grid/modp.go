@@ -0,0 +1,312 @@
+package grid
+
+// modSystem is linearSystem's counterpart for boards with more than two states per
+// cell: the same reduced toggle matrix, but over GF(p) rather than GF(2), where A[i][j]
+// is how many times Switch(j) advances cell i (mod p) and a solution is a press count
+// in [0, p) per cell. p must be prime -- otherwise GF(p) isn't a field, and elimination
+// can hit a pivot with no inverse -- which is why SupportedStates only lists primes.
+//
+// Rows are plain []int rather than packed words: each entry needs log2(p) bits anyway,
+// and boards with k > 2 are capped at MaxMultiStateCells, far below the sizes where
+// packing would pay off.
+type modSystem struct {
+	p    int
+	n    int
+	rank int
+
+	// pivots, reduced and transform play the same roles as in linearSystem, with
+	// every pivot scaled to 1.
+	pivots    []int
+	reduced   [][]int
+	transform [][]int
+
+	// quiet is kernel(), computed once up front.
+	quiet [][]int
+}
+
+// newModSystem builds and reduces the n x n toggle matrix over GF(p) whose column j
+// counts, per cell, how many times effects(j) lists it.
+func newModSystem(n, p int, effects func(pos int) []int) *modSystem {
+	rows := make([][]int, n)
+	transform := make([][]int, n)
+	for i := range n {
+		rows[i] = make([]int, n)
+		transform[i] = make([]int, n)
+		transform[i][i] = 1
+	}
+	for j := range n {
+		for _, i := range effects(j) {
+			rows[i][j] = (rows[i][j] + 1) % p
+		}
+	}
+
+	ms := &modSystem{p: p, n: n, reduced: rows, transform: transform}
+
+	r := 0
+	for col := 0; col < n && r < n; col++ {
+		pivot := -1
+		for i := r; i < n; i++ {
+			if rows[i][col] != 0 {
+				pivot = i
+				break
+			}
+		}
+		if pivot < 0 {
+			continue
+		}
+
+		rows[r], rows[pivot] = rows[pivot], rows[r]
+		transform[r], transform[pivot] = transform[pivot], transform[r]
+
+		inv := modInverse(rows[r][col], p)
+		scaleRow(rows[r], inv, p)
+		scaleRow(transform[r], inv, p)
+
+		for i := range n {
+			if f := rows[i][col]; i != r && f != 0 {
+				subtractRow(rows[i], rows[r], f, p)
+				subtractRow(transform[i], transform[r], f, p)
+			}
+		}
+
+		ms.pivots = append(ms.pivots, col)
+		r++
+	}
+	ms.rank = r
+	ms.quiet = ms.kernel()
+
+	return ms
+}
+
+// modInverse returns a's multiplicative inverse mod the prime p, as a^(p-2) (Fermat).
+func modInverse(a, p int) int {
+	result := 1
+	for e := p - 2; e > 0; e-- {
+		result = result * a % p
+	}
+	return result
+}
+
+func scaleRow(row []int, c, p int) {
+	for i := range row {
+		row[i] = row[i] * c % p
+	}
+}
+
+// subtractRow sets row to row - c*other, mod p.
+func subtractRow(row, other []int, c, p int) {
+	for i := range row {
+		row[i] = ((row[i]-c*other[i])%p + p) % p
+	}
+}
+
+// addRow sets row to row + other, mod p.
+func addRow(row, other []int, p int) {
+	for i := range row {
+		row[i] = (row[i] + other[i]) % p
+	}
+}
+
+func (ms *modSystem) modulus() int {
+	return ms.p
+}
+
+func (ms *modSystem) matrixRank() int {
+	return ms.rank
+}
+
+// solve returns one x with A*x == b (mod p), or ok=false if b isn't in A's column
+// space. As in linearSystem.solve, every free variable is left at 0.
+func (ms *modSystem) solve(b []int) (x []int, ok bool) {
+	x = make([]int, ms.n)
+	for r := range ms.n {
+		y := 0
+		for i, c := range ms.transform[r] {
+			y = (y + c*b[i]) % ms.p
+		}
+		if y == 0 {
+			continue
+		}
+		if r >= ms.rank {
+			return nil, false
+		}
+		x[ms.pivots[r]] = y
+	}
+	return x, true
+}
+
+func (ms *modSystem) reachesAllOn() bool {
+	allOn := make([]int, ms.n)
+	for i := range allOn {
+		allOn[i] = 1
+	}
+	_, ok := ms.solve(allOn)
+	return ok
+}
+
+// kernel returns a basis of A's null space, one vector per free column f: press f
+// once, and each pivot column enough times to cancel f's effect on its row.
+func (ms *modSystem) kernel() [][]int {
+	isPivot := make([]bool, ms.n)
+	for _, p := range ms.pivots {
+		isPivot[p] = true
+	}
+
+	var basis [][]int
+	for f := range ms.n {
+		if isPivot[f] {
+			continue
+		}
+		v := make([]int, ms.n)
+		v[f] = 1
+		for r := range ms.rank {
+			v[ms.pivots[r]] = (ms.p - ms.reduced[r][f]) % ms.p
+		}
+		basis = append(basis, v)
+	}
+	return basis
+}
+
+func (ms *modSystem) quietPresses() [][]int {
+	quiet := make([][]int, len(ms.quiet))
+	for i, k := range ms.quiet {
+		quiet[i] = expandPresses(k)
+	}
+	return quiet
+}
+
+// pressesToWin solves for each target's difference from board -- how far each cell
+// has to advance -- and returns the presses reaching the cheapest reachable one.
+func (ms *modSystem) pressesToWin(board boardState, targets []boardState, optimal bool) ([]int, bool) {
+	var best []int
+	for _, target := range targets {
+		b := make([]int, ms.n)
+		for i, val := range board.values {
+			b[i] = ((target.values[i]-val)%ms.p + ms.p) % ms.p
+		}
+		x, solvable := ms.solve(b)
+		if !solvable {
+			continue
+		}
+		if optimal {
+			switch mode, _ := ms.search(); mode {
+			case searchExhaustive:
+				x = minimizeMod(x, ms.quiet, ms.p)
+			case searchGreedy:
+				x = greedyMinimizeMod(x, ms.quiet, ms.p)
+			}
+		}
+		if best == nil || pressWeight(x) < pressWeight(best) {
+			best = x
+		}
+	}
+	if best == nil {
+		return nil, false
+	}
+	return expandPresses(best), true
+}
+
+func (ms *modSystem) optimalWork() int {
+	_, work := ms.search()
+	return work
+}
+
+// search is linearSystem.search over GF(p), where a solve alone costs n^2.
+func (ms *modSystem) search() (mode searchMode, work int) {
+	tries := 1
+	for range ms.quiet {
+		tries *= ms.p
+		if tries > 1<<maxKernelEnumerationDim {
+			break
+		}
+	}
+	if tries <= 1<<maxKernelEnumerationDim {
+		if work := (ms.n + tries) * ms.n; work <= maxOptimalWork {
+			return searchExhaustive, work
+		}
+	}
+	if work := (ms.n + len(ms.quiet)*ms.p*ms.n) * ms.n; work <= maxOptimalWork {
+		return searchGreedy, work
+	}
+	return searchNone, ms.n * ms.n
+}
+
+// pressWeight is the total number of presses x stands for.
+func pressWeight(x []int) int {
+	sum := 0
+	for _, c := range x {
+		sum += c
+	}
+	return sum
+}
+
+// expandPresses turns a press count per cell into a flat, ascending press list, with
+// each cell repeated as many times as it's pressed.
+func expandPresses(x []int) []int {
+	presses := []int{}
+	for pos, c := range x {
+		for range c {
+			presses = append(presses, pos)
+		}
+	}
+	return presses
+}
+
+// minimizeMod is minimize over GF(p): the lowest-weight vector in x + span(kernel),
+// searched exhaustively while there are at most 2^maxKernelEnumerationDim
+// combinations, greedily otherwise.
+func minimizeMod(x []int, kernel [][]int, p int) []int {
+	best := append([]int(nil), x...)
+
+	combinations := 1
+	for range kernel {
+		combinations *= p
+		if combinations > 1<<maxKernelEnumerationDim {
+			break
+		}
+	}
+
+	if combinations > 1<<maxKernelEnumerationDim {
+		return greedyMinimizeMod(x, kernel, p)
+	}
+
+	// Walks every combination as an odometer over the kernel coefficients: bumping
+	// digit i is a single addition of kernel[i], and so is wrapping it from p-1 back
+	// to 0, since p copies of any vector sum to zero.
+	current := append([]int(nil), x...)
+	bestWeight := pressWeight(best)
+	digits := make([]int, len(kernel))
+	for step := 1; step < combinations; step++ {
+		for i := range digits {
+			addRow(current, kernel[i], p)
+			digits[i]++
+			if digits[i] < p {
+				break
+			}
+			digits[i] = 0
+		}
+		if w := pressWeight(current); w < bestWeight {
+			best, bestWeight = append([]int(nil), current...), w
+		}
+	}
+	return best
+}
+
+// greedyMinimizeMod is greedyMinimize over GF(p): it adds whichever multiple of a
+// quiet pattern lowers x's weight until none does.
+func greedyMinimizeMod(x []int, kernel [][]int, p int) []int {
+	best := append([]int(nil), x...)
+	for improved := true; improved; {
+		improved = false
+		for _, k := range kernel {
+			candidate := append([]int(nil), best...)
+			for range p - 1 {
+				addRow(candidate, k, p)
+				if pressWeight(candidate) < pressWeight(best) {
+					best, improved = append([]int(nil), candidate...), true
+				}
+			}
+		}
+	}
+	return best
+}
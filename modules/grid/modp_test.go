@@ -0,0 +1,118 @@
+package grid
+
+import "testing"
+
+// bruteForceParMod returns the fewest presses that win g's current multi-state board,
+// by trying every press-count combination -- only feasible for tiny boards.
+func bruteForceParMod(g *Grid) (par int, ok bool) {
+	n := g.board.n
+	k := g.modulus()
+	total := 1
+	for range n {
+		total *= k
+	}
+
+	par = -1
+	for code := 0; code < total; code++ {
+		trial := &Grid{Rows: g.Rows, Cols: g.Cols, neighborhood: g.neighborhood, topology: g.topology, states: g.states, board: boardFrom(g.board.cells(), g.modulus())}
+		presses := 0
+		for pos, rest := 0, code; pos < n; pos, rest = pos+1, rest/k {
+			for range rest % k {
+				trial.Switch(pos)
+				presses++
+			}
+		}
+		if trial.CheckWin() && (par < 0 || presses < par) {
+			par = presses
+		}
+	}
+	return par, par >= 0
+}
+
+func TestModInverse(t *testing.T) {
+	for _, p := range SupportedStates {
+		for a := 1; a < p; a++ {
+			if got := a * modInverse(a, p) % p; got != 1 {
+				t.Fatalf("%d * modInverse(%d, %d) = %d mod %d, want 1", a, a, p, got, p)
+			}
+		}
+	}
+}
+
+func TestOptimalSolutionMultiStateMatchesBruteForce(t *testing.T) {
+	for _, size := range [][2]int{{2, 2}, {2, 3}, {3, 3}} {
+		for _, states := range []int{3, 5} {
+			if size == [2]int{3, 3} && states == 5 {
+				continue // 5^9 combinations is too slow for a unit test
+			}
+			for _, neighborhood := range [][]Pattern{classic(4), classic(0, 4), classic(0, 8)} {
+				spec := Spec{Rows: size[0], Cols: size[1], Neighborhood: neighborhood, States: states}
+				for i := 0; i < 3; i++ {
+					g := NewGrid(spec)
+
+					moves, ok := g.OptimalSolution()
+					want, wantOK := bruteForceParMod(g)
+					if ok != wantOK {
+						t.Fatalf("%+v: OptimalSolution() ok=%v, brute force ok=%v", spec, ok, wantOK)
+					}
+					if !ok {
+						continue
+					}
+					if len(moves) != want {
+						t.Fatalf("%+v: OptimalSolution() = %v (%d presses), brute force par = %d", spec, moves, len(moves), want)
+					}
+
+					solved, _ := g.Solve()
+					trial := &Grid{Rows: g.Rows, Cols: g.Cols, neighborhood: g.neighborhood, states: g.states, board: boardFrom(g.board.cells(), g.modulus())}
+					if !applyAndCheckWin(trial, solved) {
+						t.Fatalf("%+v: applying Solve() %v did not reach a win", spec, solved)
+					}
+					if !applyAndCheckWin(g, moves) {
+						t.Fatalf("%+v: applying OptimalSolution() %v did not reach a win", spec, moves)
+					}
+				}
+			}
+		}
+	}
+}
+
+// TestOptimalSolutionBeyondSearchBudget checks a configuration whose quiet patterns
+// are too many to search within maxOptimalWork -- 3^10 combinations on 100 cells --
+// falls back to a greedy descent that still wins, and is no longer than Solve's.
+func TestOptimalSolutionBeyondSearchBudget(t *testing.T) {
+	spec := Spec{Rows: 10, Cols: 10, Neighborhood: classic(4), States: 3}
+	ms := cachedSystem(spec).(*modSystem)
+	if mode, work := ms.search(); mode != searchGreedy || work > maxOptimalWork {
+		t.Fatalf("search() = %v, %d, want a greedy search within maxOptimalWork", mode, work)
+	}
+
+	g := NewGridFromSeed(spec, 7)
+	solved, _ := g.Solve()
+	moves, ok := g.OptimalSolution()
+	if !ok || len(moves) > len(solved) {
+		t.Fatalf("OptimalSolution() = %d presses, %v, want no more than Solve's %d", len(moves), ok, len(solved))
+	}
+	if !applyAndCheckWin(g, moves) {
+		t.Fatalf("applying OptimalSolution() %v did not reach a win", moves)
+	}
+}
+
+// TestMinimizeModGreedyFallback is TestMinimizeGreedyFallback over GF(3): with
+// unit-vector quiet patterns every press count is independently removable.
+func TestMinimizeModGreedyFallback(t *testing.T) {
+	n := maxKernelEnumerationDim + 5
+	kernel := make([][]int, n)
+	for i := range kernel {
+		kernel[i] = make([]int, n)
+		kernel[i][i] = 1
+	}
+
+	x := make([]int, n)
+	for i := range x {
+		x[i] = i % 3
+	}
+
+	if got := minimizeMod(x, kernel, 3); pressWeight(got) != 0 {
+		t.Fatalf("minimizeMod() with a unit-vector kernel = %v, want the zero vector", got)
+	}
+}
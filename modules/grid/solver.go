@@ -0,0 +1,396 @@
+package grid
+
+import (
+	"math/bits"
+	"slices"
+)
+
+// toggleSystem is a board configuration's reduced toggle matrix, over GF(k) for k
+// states per cell: linearSystem for the classic two-state game, modSystem above that.
+// Everything the solver and Analyze need from one goes through here, so neither has to
+// care which it has.
+type toggleSystem interface {
+	// modulus is k.
+	modulus() int
+	matrixRank() int
+	// reachesAllOn reports whether some press set advances every cell by exactly one
+	// state, i.e. whether all-ones is in the column space.
+	reachesAllOn() bool
+	// quietPresses is a basis of the null space, each as a press list (see
+	// pressesToWin).
+	quietPresses() [][]int
+	// pressesToWin returns presses taking board to the cheapest reachable of targets,
+	// as a flat, ascending list of positions where a cell pressed m times appears m
+	// times; with optimal, the fewest presses of any such list, as far as
+	// maxOptimalWork lets it look (see searchMode). ok is false if none of targets is
+	// reachable at all.
+	pressesToWin(board boardState, targets []boardState, optimal bool) (presses []int, ok bool)
+	// optimalWork estimates what pressesToWin costs per target with optimal set, in
+	// word-sized steps: solving for the board, then whatever search of the quiet
+	// patterns fits maxOptimalWork. It never exceeds maxOptimalWork by more than the
+	// solve itself.
+	optimalWork() int
+}
+
+// degenerate reports whether every board reachable from a won board is itself won.
+// With uniform wins, that's when the column space lies inside the multiples of
+// all-ones, so either nothing moves at all or the only thing any press set can do is
+// advance the whole board uniformly. A target picture is won by that one board alone,
+// so advancing it uniformly unsolves it like any other move, and only a matrix that
+// moves nothing is degenerate.
+func degenerate(sys toggleSystem, uniform bool) bool {
+	rank := sys.matrixRank()
+	return rank == 0 || (uniform && rank == 1 && sys.reachesAllOn())
+}
+
+// linearSystem is the two-state board's toggle matrix A over GF(2), already reduced:
+// A[i][j] is 1 iff Switch(j) flips cell i, so pressing the set of cells x changes the
+// board by A*x. Which cells get pressed matters, but neither the order nor anything
+// beyond each cell's press parity does, since presses commute and are self-inverse.
+//
+// Reduction happens once per Spec -- the matrix depends on nothing else -- recording
+// the row operations in transform so that solving for any later board is a
+// matrix-vector product instead of a fresh elimination per request. A linearSystem is
+// never mutated once built, so every Grid sharing a configuration can share one (see
+// cachedSystem).
+type linearSystem struct {
+	n    int
+	rank int
+
+	// pivots[r] is the pivot column of reduced row r, for r < rank.
+	pivots []int
+	// reduced is A in reduced row echelon form; transform is the matrix E of row
+	// operations that produced it (E*A == reduced).
+	reduced   []bitVec
+	transform []bitVec
+
+	// quiet is kernel(), computed once up front.
+	quiet []bitVec
+}
+
+// newLinearSystem builds and reduces the n x n toggle matrix whose column j is the
+// set of cells flipped by pressing j, as reported by effects(j). A cell listed twice
+// in effects(j) is flipped twice, i.e. not at all -- matching Switch.
+func newLinearSystem(n int, effects func(pos int) []int) *linearSystem {
+	rows := make([]bitVec, n)
+	transform := make([]bitVec, n)
+	for i := range n {
+		rows[i] = newBitVec(n)
+		transform[i] = newBitVec(n)
+		transform[i].set(i)
+	}
+	for j := range n {
+		for _, i := range effects(j) {
+			rows[i].flip(j)
+		}
+	}
+
+	ls := &linearSystem{n: n, reduced: rows, transform: transform}
+
+	r := 0
+	for col := 0; col < n && r < n; col++ {
+		pivot := -1
+		for i := r; i < n; i++ {
+			if rows[i].get(col) {
+				pivot = i
+				break
+			}
+		}
+		if pivot < 0 {
+			continue
+		}
+
+		rows[r], rows[pivot] = rows[pivot], rows[r]
+		transform[r], transform[pivot] = transform[pivot], transform[r]
+
+		for i := range n {
+			if i != r && rows[i].get(col) {
+				rows[i].xor(rows[r])
+				transform[i].xor(transform[r])
+			}
+		}
+
+		ls.pivots = append(ls.pivots, col)
+		r++
+	}
+	ls.rank = r
+	ls.quiet = ls.kernel()
+
+	return ls
+}
+
+// solve returns one x with A*x == b, or ok=false if b isn't in A's column space.
+// Every free variable is left at 0, so x is just the pivot columns E*b selects.
+func (ls *linearSystem) solve(b bitVec) (x bitVec, ok bool) {
+	x = newBitVec(ls.n)
+	for r := range ls.n {
+		if !ls.transform[r].dot(b) {
+			continue
+		}
+		if r >= ls.rank {
+			return nil, false
+		}
+		x.set(ls.pivots[r])
+	}
+	return x, true
+}
+
+func (ls *linearSystem) modulus() int {
+	return 2
+}
+
+func (ls *linearSystem) matrixRank() int {
+	return ls.rank
+}
+
+func (ls *linearSystem) reachesAllOn() bool {
+	_, ok := ls.solve(onesVec(ls.n))
+	return ok
+}
+
+// kernel returns a basis of A's null space: the "quiet patterns", press sets that
+// leave every cell exactly as it was. There's one basis vector per free (non-pivot)
+// column f, built by pressing f and then every pivot column whose reduced row has a
+// 1 in column f, which cancels f's effect out exactly.
+func (ls *linearSystem) kernel() []bitVec {
+	isPivot := make([]bool, ls.n)
+	for _, p := range ls.pivots {
+		isPivot[p] = true
+	}
+
+	var basis []bitVec
+	for f := range ls.n {
+		if isPivot[f] {
+			continue
+		}
+		v := newBitVec(ls.n)
+		v.set(f)
+		for r := range ls.rank {
+			if ls.reduced[r].get(f) {
+				v.set(ls.pivots[r])
+			}
+		}
+		basis = append(basis, v)
+	}
+	return basis
+}
+
+func (ls *linearSystem) quietPresses() [][]int {
+	quiet := make([][]int, len(ls.quiet))
+	for i, k := range ls.quiet {
+		quiet[i] = k.positions()
+	}
+	return quiet
+}
+
+// pressesToWin solves for each target's difference from board -- the cells a press
+// set has to flip -- keeping the smallest; on two states a press list never needs a
+// cell more than once.
+func (ls *linearSystem) pressesToWin(board boardState, targets []boardState, optimal bool) ([]int, bool) {
+	var best bitVec
+	for _, target := range targets {
+		diff := board.lit.clone()
+		diff.xor(target.lit)
+		x, solvable := ls.solve(diff)
+		if !solvable {
+			continue
+		}
+		if optimal {
+			switch mode, _ := ls.search(); mode {
+			case searchExhaustive:
+				x = minimize(x, ls.quiet)
+			case searchGreedy:
+				x = greedyMinimize(x, ls.quiet)
+			}
+		}
+		if best == nil || x.onesCount() < best.onesCount() {
+			best = x
+		}
+	}
+	if best == nil {
+		return nil, false
+	}
+	return best.positions(), true
+}
+
+func (ls *linearSystem) optimalWork() int {
+	_, work := ls.search()
+	return work
+}
+
+// search picks how pressesToWin minimizes a solution, and what that costs: every
+// combination of quiet patterns if there are few enough to fit maxOptimalWork, a
+// greedy descent if that fits instead, and otherwise none at all.
+func (ls *linearSystem) search() (mode searchMode, work int) {
+	words := (ls.n + 63) / 64
+	dim := len(ls.quiet)
+	if dim <= maxKernelEnumerationDim {
+		if work := (ls.n + 1<<dim) * words; work <= maxOptimalWork {
+			return searchExhaustive, work
+		}
+	}
+	// Greedy: a pass over the kernel per press it saves, at most one per cell.
+	if work := (ls.n + dim*ls.n) * words; work <= maxOptimalWork {
+		return searchGreedy, work
+	}
+	return searchNone, ls.n * words
+}
+
+// searchMode is how hard pressesToWin looks for a shorter solution than the one
+// elimination gives it.
+type searchMode int
+
+const (
+	// searchExhaustive tries every combination of quiet patterns: provably optimal.
+	searchExhaustive searchMode = iota
+	// searchGreedy applies quiet patterns while any one of them shortens the
+	// solution: usually, but not provably, optimal.
+	searchGreedy
+	// searchNone keeps elimination's solution as it is.
+	searchNone
+)
+
+// maxOptimalWork bounds what pressesToWin spends minimizing one target's solution, in
+// the solver's word-sized steps (see optimalWork): a few milliseconds' worth. Every
+// render works out par, so on the biggest boards, where even a greedy descent over the
+// kernel would cost more, par has to make do with a solution that isn't minimized --
+// an upper bound rather than the fewest presses.
+const maxOptimalWork = 1 << 22
+
+// maxKernelEnumerationDim bounds how many quiet patterns minimize will search through
+// exhaustively (2^dim combinations). Every board up to 8x8 has a kernel far below
+// this; anything larger -- only possible on the biggest boards -- falls back to a
+// greedy descent that's usually, but not provably, optimal.
+const maxKernelEnumerationDim = 20
+
+// minimize returns the lowest-weight vector in the coset x + span(kernel): every
+// solution to A*y == A*x is exactly x plus some combination of quiet patterns, so this
+// is the shortest press set with the same effect as x.
+func minimize(x bitVec, kernel []bitVec) bitVec {
+	if len(kernel) > maxKernelEnumerationDim {
+		return greedyMinimize(x, kernel)
+	}
+	best := x.clone()
+
+	// Walks every combination in Gray-code order, so each step is a single XOR
+	// instead of rebuilding the combination from scratch.
+	current := x.clone()
+	bestCount := best.onesCount()
+	for i := uint64(1); i < 1<<len(kernel); i++ {
+		current.xor(kernel[bits.TrailingZeros64(i)])
+		if count := current.onesCount(); count < bestCount {
+			best, bestCount = current.clone(), count
+		}
+	}
+	return best
+}
+
+// greedyMinimize is minimize's fallback for a kernel too big to search: it applies
+// whichever quiet pattern lowers x's weight until none does.
+func greedyMinimize(x bitVec, kernel []bitVec) bitVec {
+	best := x.clone()
+	for improved := true; improved; {
+		improved = false
+		for _, k := range kernel {
+			candidate := best.clone()
+			candidate.xor(k)
+			if candidate.onesCount() < best.onesCount() {
+				best, improved = candidate, true
+			}
+		}
+	}
+	return best
+}
+
+// system returns g's reduced toggle matrix, fetching it from cachedSystem on first
+// use.
+func (g *Grid) system() toggleSystem {
+	if g.linear == nil {
+		g.linear = cachedSystem(g.Spec())
+	}
+	return g.linear
+}
+
+// Solve returns presses which, applied in any order, take the board as it stands
+// right now to a win -- the target picture, or every cell in the same state, whichever
+// CheckWin would accept; when several are reachable the cheapest is returned. A cell listed m times is
+// pressed m times (only possible with more than two states). Unlike
+// GetPossibleSolution, which only describes how initGame scrambled the board, this
+// stays correct after any number of moves. ok is false if no press list wins from
+// here, which only happens on NewGrid's structurally degenerate fallback board. A
+// LitOnly board is solved by search instead (see solveLitOnly), and its presses have
+// to be made in the order given; ok is also false if that search gives up (see
+// Solvable).
+func (g *Grid) Solve() (moves []int, ok bool) {
+	if g.litOnly {
+		moves, ok, _ = g.solveLitOnly()
+		return moves, ok
+	}
+	return g.system().pressesToWin(g.board, g.winTargets(), false)
+}
+
+// OptimalSolution is Solve's minimum-length counterpart: across every win target, it
+// returns a press list with the fewest presses of any that wins from the current
+// board, by searching every solution (one particular solution plus each combination of
+// quiet patterns, see minimize). Its length is the board's par. ok is false exactly
+// when Solve's is; on a LitOnly board, the two are the same search. A configuration
+// with too many quiet patterns to search within maxOptimalWork gets the best a cheaper
+// search finds instead (see searchMode), so its par is an upper bound.
+//
+// The answer is kept for the board it was worked out on, so asking again before the
+// next move -- every render does, for par and the cheat panel -- costs a comparison,
+// not another search.
+func (g *Grid) OptimalSolution() (moves []int, ok bool) {
+	if g.optimal == nil || !g.optimal.board.equal(g.board) {
+		solved := &solvedBoard{board: g.board.clone()}
+		if g.litOnly {
+			solved.moves, solved.ok, solved.unknown = g.solveLitOnly()
+		} else {
+			solved.moves, solved.ok = g.system().pressesToWin(g.board, g.winTargets(), true)
+		}
+		g.optimal = solved
+	}
+	return slices.Clone(g.optimal.moves), g.optimal.ok
+}
+
+// solvedBoard is OptimalSolution's answer for board, and whether a failure to find one
+// is only for want of search budget. It's never modified once made, so copies of a
+// Grid (see Restart) can share it.
+type solvedBoard struct {
+	board   boardState
+	moves   []int
+	ok      bool
+	unknown bool
+}
+
+// Solvable reports whether some press list wins from the board as it stands, as
+// OptimalSolution's ok does -- and, when it doesn't, whether that's known: a LitOnly
+// board's search can run out of budget on a board far from any win, without having
+// shown there's no way there. Any other board's answer is always known.
+func (g *Grid) Solvable() (solvable, known bool) {
+	_, solvable = g.OptimalSolution()
+	return solvable, !g.optimal.unknown
+}
+
+// Hint returns a single cell worth pressing next: one from OptimalSolution, so pressing
+// it always brings the board exactly one move closer to a win (the rest of that
+// solution is still a solution, and nothing shorter can exist or the original wasn't
+// optimal). ok is false if there's nothing left to press -- the board is already won
+// -- or no press list wins from here.
+//
+// Where that isn't known (see Solvable), one line is still known to win: back along
+// the moves made to the board as dealt, then the solution it was dealt with. So the
+// hint is to undo the last move instead, and undo is true.
+func (g *Grid) Hint() (pos int, undo, ok bool) {
+	moves, solvable := g.OptimalSolution()
+	if !solvable {
+		if g.optimal.unknown && g.logCursor > 0 {
+			return 0, true, true
+		}
+		return 0, false, false
+	}
+	if len(moves) == 0 {
+		return 0, false, false
+	}
+	return moves[0], false, true
+}
@@ -0,0 +1,204 @@
+package grid
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// applyAndCheckWin presses every move in moves on g and reports whether that won.
+func applyAndCheckWin(g *Grid, moves []int) bool {
+	for _, pos := range moves {
+		g.Switch(pos)
+	}
+	return g.CheckWin()
+}
+
+// TestSolveWinsFromCurrentBoard is the regression test for the cheat panel going stale
+// after the first click: Solve must win from whatever the board looks like now, not
+// just from the board initGame dealt.
+func TestSolveWinsFromCurrentBoard(t *testing.T) {
+	r := rand.New(rand.NewSource(1)) //nolint:gosec // deterministic test input
+
+	for _, dim := range []int{2, 3, 4, 5} {
+		for _, neighborhood := range [][]Pattern{classic(0), classic(4), classic(8), classic(0, 4), classic(4, 8), classic(0, 4, 8)} {
+			for i := 0; i < 20; i++ {
+				g := NewGrid(Spec{Rows: dim, Cols: dim, Neighborhood: neighborhood})
+
+				if _, ok := g.Solve(); !ok {
+					if len(g.GetPossibleSolution()) != 0 {
+						t.Fatalf("dim=%d neighborhood=%v: Solve() reported unsolvable for a board initGame scrambled from a win", dim, neighborhood)
+					}
+					continue
+				}
+
+				for range r.Intn(6) {
+					g.Switch(r.Intn(dim * dim))
+				}
+
+				moves, ok := g.Solve()
+				if !ok {
+					t.Fatalf("dim=%d neighborhood=%v: Solve() reported unsolvable after real moves, board: %v", dim, neighborhood, g.GetGrid())
+				}
+				if !applyAndCheckWin(g, moves) {
+					t.Fatalf("dim=%d neighborhood=%v: applying Solve() %v did not reach a win, board: %v", dim, neighborhood, moves, g.GetGrid())
+				}
+			}
+		}
+	}
+}
+
+func TestSolveOnWonBoardIsEmpty(t *testing.T) {
+	g := &Grid{Rows: 3, Cols: 3, neighborhood: classic(0, 4), board: newBoardState(9, 2)}
+
+	moves, ok := g.Solve()
+	if !ok || len(moves) != 0 {
+		t.Fatalf("Solve() on an already-won board = (%v, %v), want ([], true)", moves, ok)
+	}
+}
+
+// TestSolveReachesAllOnTarget covers a board that's one press away from all-on but
+// not from all-off, so only the complemented target can be the one Solve picks.
+func TestSolveReachesAllOnTarget(t *testing.T) {
+	g := &Grid{Rows: 3, Cols: 3, neighborhood: classic(0), board: boardFrom([]int{1, 1, 1, 1, 0, 1, 1, 1, 1}, 2)}
+
+	moves, ok := g.Solve()
+	if !ok || len(moves) != 1 || moves[0] != 4 {
+		t.Fatalf("Solve() = (%v, %v), want ([4], true)", moves, ok)
+	}
+}
+
+// TestSolveReportsDegenerateBoardUnsolvable pins down the one unsolvable case NewGrid
+// can produce: a 2x2 board with every pattern, where every press flips every cell, so
+// the raw single-cell flip of NewGrid's degenerate fallback can never be undone.
+func TestSolveReportsDegenerateBoardUnsolvable(t *testing.T) {
+	g := &Grid{Rows: 2, Cols: 2, neighborhood: classic(0, 4, 8), board: boardFrom([]int{1, 0, 0, 0}, 2)}
+
+	if moves, ok := g.Solve(); ok {
+		t.Fatalf("Solve() on a degenerate board = (%v, true), want ok=false", moves)
+	}
+}
+
+// bruteForcePar returns the fewest presses that win g's current board, by trying
+// every press set -- only feasible for the small boards these tests use.
+func bruteForcePar(g *Grid) (par int, ok bool) {
+	n := g.board.n
+	par = n + 1
+	for mask := 0; mask < 1<<n; mask++ {
+		trial := &Grid{Rows: g.Rows, Cols: g.Cols, neighborhood: g.neighborhood, board: boardFrom(g.board.cells(), 2)}
+		var moves []int
+		for pos := range n {
+			if mask&(1<<pos) != 0 {
+				moves = append(moves, pos)
+			}
+		}
+		if len(moves) < par && applyAndCheckWin(trial, moves) {
+			par = len(moves)
+		}
+	}
+	return par, par <= n
+}
+
+// TestOptimalSolutionMatchesBruteForce checks OptimalSolution against exhaustive
+// search on boards with non-trivial quiet patterns (e.g. 4x4 {0,4} has a 4-dimensional
+// kernel, so a particular solution is often far from the shortest).
+func TestOptimalSolutionMatchesBruteForce(t *testing.T) {
+	for _, dim := range []int{2, 3, 4} {
+		for _, neighborhood := range [][]Pattern{classic(4), classic(8), classic(0, 4), classic(0, 8), classic(0, 4, 8)} {
+			for i := 0; i < 3; i++ {
+				g := NewGrid(Spec{Rows: dim, Cols: dim, Neighborhood: neighborhood})
+
+				moves, ok := g.OptimalSolution()
+				want, wantOK := bruteForcePar(g)
+				if ok != wantOK {
+					t.Fatalf("dim=%d neighborhood=%v: OptimalSolution() ok=%v, brute force ok=%v", dim, neighborhood, ok, wantOK)
+				}
+				if !ok {
+					continue
+				}
+				if len(moves) != want {
+					t.Fatalf("dim=%d neighborhood=%v: OptimalSolution() = %v (%d presses), brute force par = %d",
+						dim, neighborhood, moves, len(moves), want)
+				}
+				if !applyAndCheckWin(g, moves) {
+					t.Fatalf("dim=%d neighborhood=%v: applying OptimalSolution() %v did not reach a win", dim, neighborhood, moves)
+				}
+			}
+		}
+	}
+}
+
+// TestOptimalSolutionIsKeptPerBoard checks OptimalSolution's answer is reused only for
+// the board it was worked out on, and that a caller can't spoil it for the next.
+func TestOptimalSolutionIsKeptPerBoard(t *testing.T) {
+	g := NewGridFromSeed(Spec{Rows: 4, Cols: 4, Neighborhood: classic(0, 4)}, 3)
+
+	moves, ok := g.OptimalSolution()
+	if !ok || len(moves) == 0 {
+		t.Fatalf("OptimalSolution() = %v, %v, want presses to make", moves, ok)
+	}
+	want, last := fmt.Sprint(moves), moves[len(moves)-1]
+	moves[0] = -1
+	if again, _ := g.OptimalSolution(); fmt.Sprint(again) != want {
+		t.Fatalf("OptimalSolution() after its last answer was modified = %v, want %s", again, want)
+	}
+
+	g.Switch(last)
+	if after, _ := g.OptimalSolution(); fmt.Sprint(after) == want || !applyAndCheckWin(g, after) {
+		t.Fatalf("OptimalSolution() after a move = %v, want a new solution for the new board", after)
+	}
+}
+
+// TestMinimizeGreedyFallback covers the path taken when the kernel is too large to
+// enumerate: with unit-vector quiet patterns every set bit is independently removable,
+// so the greedy descent must reach the zero vector.
+func TestMinimizeGreedyFallback(t *testing.T) {
+	n := maxKernelEnumerationDim + 5
+	kernel := make([]bitVec, n)
+	for i := range kernel {
+		kernel[i] = newBitVec(n)
+		kernel[i].set(i)
+	}
+
+	x := newBitVec(n)
+	for i := 0; i < n; i += 2 {
+		x.set(i)
+	}
+
+	if got := minimize(x, kernel); got.onesCount() != 0 {
+		t.Fatalf("minimize() with a unit-vector kernel = %v, want the zero vector", got.positions())
+	}
+}
+
+// TestHintIsAlwaysProductive follows Hint all the way to a win: each hinted press must
+// lower par by exactly one, so a game played only from hints takes exactly par moves.
+func TestHintIsAlwaysProductive(t *testing.T) {
+	for _, neighborhood := range [][]Pattern{classic(0, 4), classic(4), classic(0, 8)} {
+		g := NewGrid(Spec{Rows: 4, Cols: 5, Neighborhood: neighborhood})
+
+		moves, ok := g.OptimalSolution()
+		if !ok {
+			continue
+		}
+
+		for par := len(moves); par > 0; par-- {
+			pos, undo, ok := g.Hint()
+			if !ok || undo {
+				t.Fatalf("neighborhood=%v: Hint() = %d, undo %v, ok %v with par %d left", neighborhood, pos, undo, ok, par)
+			}
+			g.Switch(pos)
+
+			if remaining, _ := g.OptimalSolution(); len(remaining) != par-1 {
+				t.Fatalf("neighborhood=%v: pressing hint %d took par from %d to %d, want %d",
+					neighborhood, pos, par, len(remaining), par-1)
+			}
+		}
+
+		if !g.CheckWin() {
+			t.Fatalf("neighborhood=%v: following every hint did not win, board: %v", neighborhood, g.GetGrid())
+		}
+		if _, _, ok := g.Hint(); ok {
+			t.Fatalf("neighborhood=%v: Hint() on a won board reported ok=true", neighborhood)
+		}
+	}
+}
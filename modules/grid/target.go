@@ -0,0 +1,182 @@
+package grid
+
+import "fmt"
+
+// Target is what a board has to look like to be won.
+type Target string
+
+const (
+	// TargetUniform is the classic win: every cell in the same state, whichever it is.
+	TargetUniform Target = ""
+	// TargetRandom is a random picture: every cell has its own state to reach, drawn
+	// from the board's seed.
+	TargetRandom Target = "random"
+)
+
+// Picture is a small drawing a board can be asked to match, centred on it. Each of
+// Rows is one row of cells, '#' for state 1 and '.' for state 0; every other cell of
+// the board has to end at 0.
+type Picture struct {
+	Name string
+	Rows []string
+}
+
+// Pictures is the library of pictures a Target can name. They're all 5x5, so any
+// board from 5x5 up can draw every one of them.
+var Pictures = []Picture{
+	{Name: "heart", Rows: []string{
+		".#.#.",
+		"#####",
+		"#####",
+		".###.",
+		"..#..",
+	}},
+	{Name: "smile", Rows: []string{
+		".#.#.",
+		".#.#.",
+		".....",
+		"#...#",
+		".###.",
+	}},
+	{Name: "diamond", Rows: []string{
+		"..#..",
+		".#.#.",
+		"#...#",
+		".#.#.",
+		"..#..",
+	}},
+	{Name: "arrow", Rows: []string{
+		"..#..",
+		".###.",
+		"#.#.#",
+		"..#..",
+		"..#..",
+	}},
+	{Name: "A", Rows: []string{
+		".###.",
+		"#...#",
+		"#####",
+		"#...#",
+		"#...#",
+	}},
+	{Name: "H", Rows: []string{
+		"#...#",
+		"#...#",
+		"#####",
+		"#...#",
+		"#...#",
+	}},
+}
+
+// Targets lists every Target, in the order the configuration panel offers them:
+// uniform, random, then each of Pictures.
+var Targets = func() []Target {
+	targets := []Target{TargetUniform, TargetRandom}
+	for _, p := range Pictures {
+		targets = append(targets, Target(p.Name))
+	}
+	return targets
+}()
+
+// ParseTarget returns the Target named s; the empty string is TargetUniform.
+func ParseTarget(s string) (Target, error) {
+	for _, t := range Targets {
+		if string(t) == s {
+			return t, nil
+		}
+	}
+	return "", fmt.Errorf("unknown target %q", s)
+}
+
+// String is the Target's name, with TargetUniform spelled out for display.
+func (t Target) String() string {
+	if t == TargetUniform {
+		return "uniform"
+	}
+	return string(t)
+}
+
+// picture returns the Picture t names; ok is false for TargetUniform and TargetRandom.
+func (t Target) picture() (pic Picture, ok bool) {
+	for _, p := range Pictures {
+		if Target(p.Name) == t {
+			return p, true
+		}
+	}
+	return Picture{}, false
+}
+
+// validate reports whether t can be drawn on a rows x cols board, graph or not.
+func (t Target) validate(rows, cols int, graph bool) error {
+	pic, ok := t.picture()
+	if !ok {
+		return nil
+	}
+	if graph {
+		return fmt.Errorf("the %q picture can't be drawn on a graph board", pic.Name)
+	}
+	if rows < len(pic.Rows) || cols < len(pic.Rows[0]) {
+		return fmt.Errorf("the %q picture needs a board of at least %dx%d, got %dx%d",
+			pic.Name, len(pic.Rows), len(pic.Rows[0]), rows, cols)
+	}
+	return nil
+}
+
+// dealTarget sets g's target board for its Target, or leaves it nil for the classic
+// uniform win. A random one is drawn from g.rand, before anything else is, so the
+// seed alone still decides the whole puzzle.
+func (g *Grid) dealTarget() {
+	k := g.modulus()
+	if pic, ok := g.target.picture(); ok {
+		cells := make([]int, g.board.n)
+		top, left := (g.Rows-len(pic.Rows))/2, (g.Cols-len(pic.Rows[0]))/2
+		for r, row := range pic.Rows {
+			for c := range row {
+				if row[c] == '#' {
+					cells[(top+r)*g.Cols+left+c] = 1
+				}
+			}
+		}
+		b := boardFrom(cells, k)
+		g.targetBoard = &b
+		return
+	}
+
+	if g.target == TargetRandom {
+		cells := make([]int, g.board.n)
+		for pos := range cells {
+			cells[pos] = g.rand.Intn(k)
+		}
+		b := boardFrom(cells, k)
+		g.targetBoard = &b
+	}
+}
+
+// winTargets returns every board CheckWin accepts: the target board, or in the
+// classic game, each of the k uniform ones.
+func (g *Grid) winTargets() []boardState {
+	if g.targetBoard != nil {
+		return []boardState{*g.targetBoard}
+	}
+	k := g.modulus()
+	targets := make([]boardState, k)
+	for c := range k {
+		targets[c] = newBoardState(g.board.n, k)
+		targets[c].fill(c)
+	}
+	return targets
+}
+
+// Target returns the board g has to match to be won, in GetGrid's layout, or nil in
+// the classic game, where any uniform board wins.
+func (g *Grid) Target() [][]int {
+	if g.targetBoard == nil {
+		return nil
+	}
+	cells := g.targetBoard.cells()
+	rows := make([][]int, g.Rows)
+	for r := range rows {
+		rows[r] = cells[r*g.Cols : (r+1)*g.Cols : (r+1)*g.Cols]
+	}
+	return rows
+}
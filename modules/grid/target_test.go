@@ -0,0 +1,105 @@
+package grid
+
+import (
+	"fmt"
+	"testing"
+)
+
+// TestNewGridWithTarget checks a target board is dealt unsolved, that playing its
+// solution reaches exactly the target -- not just any uniform board -- and that
+// CheckWin only accepts that.
+func TestNewGridWithTarget(t *testing.T) {
+	for _, spec := range []Spec{
+		{Rows: 5, Cols: 5, Neighborhood: classic(0, 4), Target: "heart"},
+		{Rows: 7, Cols: 6, Neighborhood: classic(0, 4), Target: "A", Topology: TopologyTorus},
+		{Rows: 5, Cols: 5, Neighborhood: classic(0, 4), Target: "smile", States: 3},
+		{Rows: 4, Cols: 4, Neighborhood: classic(0, 4), Target: TargetRandom, States: 5},
+		{Graph: petersenGraph(), Target: TargetRandom},
+	} {
+		for seed := range int64(5) {
+			g := NewGridFromSeed(spec, seed)
+			if g.CheckWin() {
+				t.Fatalf("%+v seed %d: dealt an already-won board", spec.Target, seed)
+			}
+
+			moves, ok := g.OptimalSolution()
+			if !ok {
+				t.Fatalf("%+v seed %d: no solution to a dealt target board", spec.Target, seed)
+			}
+			for _, pos := range moves {
+				g.Switch(pos)
+			}
+			if !g.CheckWin() || fmt.Sprint(g.GetGrid()) != fmt.Sprint(g.Target()) {
+				t.Fatalf("%+v seed %d: solution left %v, want the target %v", spec.Target, seed, g.GetGrid(), g.Target())
+			}
+		}
+	}
+}
+
+// TestTargetPictureIsCentred checks a picture is drawn in the middle of the board,
+// with everything around it at 0.
+func TestTargetPictureIsCentred(t *testing.T) {
+	g := NewGridFromSeed(Spec{Rows: 7, Cols: 9, Neighborhood: classic(0, 4), Target: "H"}, 1)
+	target := g.Target()
+
+	want := [][]int{
+		{0, 0, 0, 0, 0, 0, 0, 0, 0},
+		{0, 0, 1, 0, 0, 0, 1, 0, 0},
+		{0, 0, 1, 0, 0, 0, 1, 0, 0},
+		{0, 0, 1, 1, 1, 1, 1, 0, 0},
+		{0, 0, 1, 0, 0, 0, 1, 0, 0},
+		{0, 0, 1, 0, 0, 0, 1, 0, 0},
+		{0, 0, 0, 0, 0, 0, 0, 0, 0},
+	}
+	if fmt.Sprint(target) != fmt.Sprint(want) {
+		t.Fatalf("Target() = %v, want %v", target, want)
+	}
+}
+
+// TestUniformWinIgnoresTarget checks the classic game has no target, and still wins on
+// any uniform board.
+func TestUniformWinIgnoresTarget(t *testing.T) {
+	g := NewGridFromSeed(Spec{Rows: 3, Cols: 3, Neighborhood: classic(0, 4)}, 1)
+	if g.Target() != nil {
+		t.Fatalf("Target() = %v on a uniform board, want nil", g.Target())
+	}
+	g.board.fill(1)
+	if !g.CheckWin() {
+		t.Fatal("CheckWin() rejected an all-on board without a target")
+	}
+}
+
+func TestTargetValidate(t *testing.T) {
+	for _, tt := range []struct {
+		spec    Spec
+		wantErr bool
+	}{
+		{Spec{Rows: 5, Cols: 5, Target: "heart"}, false},
+		{Spec{Rows: 4, Cols: 9, Target: "heart"}, true},
+		{Spec{Rows: 2, Cols: 2, Target: TargetRandom}, false},
+		{Spec{Graph: petersenGraph(), Target: TargetRandom}, false},
+		{Spec{Graph: petersenGraph(), Target: "heart"}, true},
+	} {
+		if err := tt.spec.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("Validate(%dx%d, %q) = %v, want error %v", tt.spec.Rows, tt.spec.Cols, tt.spec.Target, err, tt.wantErr)
+		}
+	}
+}
+
+func TestParseTarget(t *testing.T) {
+	for in, want := range map[string]Target{"": TargetUniform, "random": TargetRandom, "heart": "heart"} {
+		if got, err := ParseTarget(in); err != nil || got != want {
+			t.Errorf("ParseTarget(%q) = %q, %v, want %q, nil", in, got, err, want)
+		}
+	}
+	if _, err := ParseTarget("spade"); err == nil {
+		t.Error("ParseTarget(\"spade\") accepted an unknown target")
+	}
+	for _, p := range Pictures {
+		for _, row := range p.Rows {
+			if len(row) != len(p.Rows[0]) {
+				t.Errorf("picture %q isn't rectangular", p.Name)
+			}
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package grid
+
+// toggleTable is every press's effect on one board configuration, worked out once so
+// Switch is a lookup instead of a fresh walk over patterns, offsets and bounds on every
+// press -- and, on the two-state board, a handful of word XORs. Like toggleSystem, it
+// depends on nothing but the Spec and is never mutated once built, so every Grid of a
+// configuration shares one (see cachedToggles).
+type toggleTable struct {
+	// affected[pos] is Grid.affected(pos): the cells pressing pos advances, each as
+	// many times as it's listed.
+	affected [][]int
+	// masks[pos] is affected[pos] as a bitVec of the cells it flips, for two-state
+	// boards only: a cell listed an even number of times flips back, so it's left out.
+	masks []bitVec
+}
+
+// newToggleTable records effects(pos) for each of n cells, plus their masks if k is 2.
+func newToggleTable(n, k int, effects func(pos int) []int) *toggleTable {
+	t := &toggleTable{affected: make([][]int, n)}
+	for pos := range n {
+		t.affected[pos] = effects(pos)
+	}
+
+	if k == 2 {
+		t.masks = make([]bitVec, n)
+		for pos, cells := range t.affected {
+			t.masks[pos] = newBitVec(n)
+			for _, p := range cells {
+				t.masks[pos].flip(p)
+			}
+		}
+	}
+
+	return t
+}
+
+// toggles returns g's toggle table, fetching it from cachedToggles on first use.
+func (g *Grid) toggles() *toggleTable {
+	if g.table == nil {
+		g.table = cachedToggles(g.Spec())
+	}
+	return g.table
+}
@@ -0,0 +1,127 @@
+package grid
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestToggleTableMatchesAffected(t *testing.T) {
+	for _, spec := range []Spec{
+		{Rows: 3, Cols: 4, Neighborhood: classic(0, 4)},
+		{Rows: 3, Cols: 3, Neighborhood: classic(4, 4)},
+		{Rows: 4, Cols: 4, Neighborhood: classic(0, 8), Topology: TopologyTorus},
+		{Rows: 3, Cols: 3, Lattice: LatticeHex, Neighborhood: []Pattern{hexPattern}, States: 3},
+		{Graph: petersenGraph()},
+	} {
+		g := NewGrid(spec)
+		table := g.toggles()
+
+		for pos := range g.board.n {
+			if !slices.Equal(table.affected[pos], g.affected(pos)) {
+				t.Fatalf("%+v: table.affected[%d] = %v, want %v", spec, pos, table.affected[pos], g.affected(pos))
+			}
+			if spec.NumStates() != 2 {
+				continue
+			}
+
+			// The mask is the cells affected an odd number of times.
+			want := newBitVec(g.board.n)
+			for _, p := range g.affected(pos) {
+				want.flip(p)
+			}
+			if !slices.Equal(table.masks[pos], want) {
+				t.Fatalf("%+v: table.masks[%d] = %v, want %v", spec, pos, table.masks[pos].positions(), want.positions())
+			}
+		}
+	}
+}
+
+func TestOnesVec(t *testing.T) {
+	for _, n := range []int{1, 9, 63, 64, 65, 128, 2500} {
+		v := onesVec(n)
+		if len(v) != len(newBitVec(n)) || v.onesCount() != n || !v.get(n-1) {
+			t.Fatalf("onesVec(%d) = %d words with %d bits set, want %d bits in %d words", n, len(v), v.onesCount(), n, len(newBitVec(n)))
+		}
+	}
+}
+
+// TestLargeBoardSolves plays the largest board the size fields allow all the way
+// through: dealt, solved, and won by applying the solution.
+func TestLargeBoardSolves(t *testing.T) {
+	g := NewGrid(Spec{Rows: 50, Cols: 50, Neighborhood: classic(0, 4)})
+	if g.CheckWin() {
+		t.Fatal("NewGrid() dealt an already-won 50x50 board")
+	}
+
+	moves, ok := g.Solve()
+	if !ok {
+		t.Fatal("Solve() reported a dealt 50x50 board unsolvable")
+	}
+	if !applyAndCheckWin(g, moves) {
+		t.Fatalf("applying Solve()'s %d presses did not win the 50x50 board", len(moves))
+	}
+}
+
+func TestSpecValidate(t *testing.T) {
+	if err := (Spec{Rows: 50, Cols: 50, Neighborhood: classic(0, 4)}).Validate(); err != nil {
+		t.Fatalf("Validate() rejected a 50x50 two-state board: %v", err)
+	}
+	if err := (Spec{Rows: 16, Cols: 16, Neighborhood: classic(0, 4), States: 3}).Validate(); err != nil {
+		t.Fatalf("Validate() rejected a 16x16 three-state board: %v", err)
+	}
+	if err := (Spec{Rows: 16, Cols: 17, Neighborhood: classic(0, 4), States: 3}).Validate(); err == nil {
+		t.Fatal("Validate() accepted a three-state board over MaxMultiStateCells")
+	}
+}
+
+// The benchmarks below compare Switch and CheckWin against the way they used to work:
+// walking every pattern's offsets, with bounds checks, into a fresh list of cells on
+// each press, and scanning a state per cell for a win.
+
+func BenchmarkSwitch(b *testing.B) {
+	for _, side := range []int{8, 20, 50} {
+		g := NewGrid(Spec{Rows: side, Cols: side, Neighborhood: classic(0, 4, 8)})
+		n := g.board.n
+
+		b.Run(fmt.Sprintf("%dx%d/table", side, side), func(b *testing.B) {
+			for i := range b.N {
+				g.Switch(i % n)
+			}
+		})
+
+		b.Run(fmt.Sprintf("%dx%d/offsets", side, side), func(b *testing.B) {
+			cells := make([]int, n)
+			for i := range b.N {
+				for _, p := range g.affected(i % n) {
+					cells[p] = (cells[p] + 1) % 2
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkCheckWin(b *testing.B) {
+	for _, side := range []int{8, 20, 50} {
+		g := NewGrid(Spec{Rows: side, Cols: side, Neighborhood: classic(0, 4)})
+		// A won board, so neither version can stop early.
+		g.board.fill(1)
+
+		b.Run(fmt.Sprintf("%dx%d/popcount", side, side), func(b *testing.B) {
+			for range b.N {
+				g.CheckWin()
+			}
+		})
+
+		b.Run(fmt.Sprintf("%dx%d/scan", side, side), func(b *testing.B) {
+			cells := g.board.cells()
+			for range b.N {
+				for _, val := range cells {
+					if val != cells[0] {
+						break
+					}
+				}
+			}
+		})
+	}
+}
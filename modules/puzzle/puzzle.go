@@ -0,0 +1,225 @@
+// Package puzzle implements puzzle codes: a short, copy-pasteable string naming one
+// exact board -- its configuration plus the seed it was dealt from -- so a player can
+// share a board, or come back to it, by code or by a /play/<code> link.
+package puzzle
+
+import (
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	grid "goSwitch/modules/grid"
+	utils "goSwitch/modules/utils"
+)
+
+// Puzzle is everything a code records: the configuration panel's fields, as /reset
+// receives them, and the seed grid.NewGridFromSeed deals the board from.
+type Puzzle struct {
+	Seed     int64
+	Rows     int
+	Cols     int
+	Lattice  grid.Lattice
+	Topology grid.Topology
+	States   int
+	// Difficulty is the band the board was dealt in; it changes which board a seed
+	// deals, so it's as much a part of the code as the seed.
+	Difficulty grid.Difficulty
+	// Target is what the board has to look like to be won.
+	Target grid.Target
+	// LitOnly only lets lit cells be pressed, which also changes how the board is dealt.
+	LitOnly bool
+	// Neighborhood is the active patterns' names.
+	Neighborhood []string
+	// Graph is the graph board's name, or empty for a lattice board.
+	Graph string
+}
+
+// codeVersion is a code's first byte, so the layout below can change later without
+// old codes silently decoding into some other board.
+//
+// Version 2 added the difficulty field, version 3 the target, and version 4 the
+// lit-only flag. Older codes are still decoded, as DifficultyAny, TargetUniform and
+// not lit-only -- the only boards they could have named.
+const codeVersion = 4
+
+// headerLen is the version byte plus the 8-byte seed.
+const headerLen = 9
+
+// encoding is base32 without padding: case-insensitive (Decode upper-cases its input),
+// free of characters that need escaping in a URL, and about as short as that allows.
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// ErrInvalidCode is returned by Decode for anything that isn't a well-formed code for
+// this server's configuration.
+var ErrInvalidCode = errors.New("invalid puzzle code")
+
+// Encode returns p's code. Patterns, the lattice, topology, difficulty, target and
+// graph are stored as their index in patterns, grid.Lattices, grid.Topologies,
+// grid.Difficulties, grid.Targets and graphs rather than by name, to keep codes short
+// -- so a code is only meaningful to a server whose config lists the same patterns and
+// graphs in the same order. It fails only if p names a pattern, graph, lattice,
+// topology, difficulty or target that isn't in those lists.
+//
+// The layout is: the version byte, the seed as 8 big-endian bytes, then rows, cols,
+// lattice, topology, states, difficulty, target, lit-only (1 or 0), graph (index + 1,
+// 0 for none), the number of patterns and each pattern's index, all as uvarints, and
+// finally a checksum byte.
+func Encode(p Puzzle, patterns []utils.Pattern, graphs []utils.Graph) (string, error) {
+	lattice := slices.Index(grid.Lattices, p.Lattice)
+	if lattice < 0 {
+		return "", fmt.Errorf("puzzle: unknown lattice %q", p.Lattice)
+	}
+	topology := slices.Index(grid.Topologies, p.Topology)
+	if topology < 0 {
+		return "", fmt.Errorf("puzzle: unknown topology %q", p.Topology)
+	}
+
+	difficulty := slices.Index(grid.Difficulties, p.Difficulty)
+	if difficulty < 0 {
+		return "", fmt.Errorf("puzzle: unknown difficulty %q", p.Difficulty)
+	}
+	target := slices.Index(grid.Targets, p.Target)
+	if target < 0 {
+		return "", fmt.Errorf("puzzle: unknown target %q", p.Target)
+	}
+
+	graph := 0
+	if p.Graph != "" {
+		graph = slices.IndexFunc(graphs, func(g utils.Graph) bool { return g.Name == p.Graph }) + 1
+		if graph == 0 {
+			return "", fmt.Errorf("puzzle: unknown graph %q", p.Graph)
+		}
+	}
+
+	buf := []byte{codeVersion}
+	buf = binary.BigEndian.AppendUint64(buf, uint64(p.Seed)) //nolint:gosec // a bit-for-bit round trip, undone in Decode
+	litOnly := 0
+	if p.LitOnly {
+		litOnly = 1
+	}
+	for _, v := range []int{p.Rows, p.Cols, lattice, topology, p.States, difficulty, target, litOnly, graph, len(p.Neighborhood)} {
+		buf = binary.AppendUvarint(buf, uint64(v)) //nolint:gosec // every field is a small non-negative count or index
+	}
+	for _, name := range p.Neighborhood {
+		idx := slices.IndexFunc(patterns, func(pt utils.Pattern) bool { return pt.Name == name })
+		if idx < 0 {
+			return "", fmt.Errorf("puzzle: unknown pattern %q", name)
+		}
+		buf = binary.AppendUvarint(buf, uint64(idx)) //nolint:gosec // an index, never negative
+	}
+	buf = append(buf, checksum(buf))
+
+	return encoding.EncodeToString(buf), nil
+}
+
+// Decode parses a code made by Encode, against the same patterns and graphs. It only
+// checks the code is well-formed and its indices resolve; whether the board it
+// describes is one this server will deal (size bounds, supported states, and so on)
+// is left to the same validation /reset applies, via Form.
+func Decode(code string, patterns []utils.Pattern, graphs []utils.Graph) (Puzzle, error) {
+	buf, err := encoding.DecodeString(strings.ToUpper(strings.TrimSpace(code)))
+	if err != nil || len(buf) <= headerLen || buf[0] == 0 || buf[0] > codeVersion || checksum(buf[:len(buf)-1]) != buf[len(buf)-1] {
+		return Puzzle{}, ErrInvalidCode
+	}
+	version := buf[0]
+
+	p := Puzzle{Seed: int64(binary.BigEndian.Uint64(buf[1:headerLen]))} //nolint:gosec // a bit-for-bit round trip of Encode's conversion
+	rest := buf[headerLen : len(buf)-1]
+
+	// next reads one uvarint off rest, which must be below limit; ok is false if
+	// there's none left or it's out of range.
+	next := func(limit int) (int, bool) {
+		v, n := binary.Uvarint(rest)
+		if n <= 0 || v >= uint64(limit) { //nolint:gosec // every limit is a positive length or bound
+			return 0, false
+		}
+		rest = rest[n:]
+		return int(v), true
+	}
+
+	// Sizes and states are only bounded loosely here, to keep them well inside an int;
+	// the real bounds are /reset's.
+	const maxField = 1 << 16
+	var lattice, topology, difficulty, target, litOnly, graph, count int
+	type field struct {
+		dst   *int
+		limit int
+	}
+	fields := []field{
+		{&p.Rows, maxField},
+		{&p.Cols, maxField},
+		{&lattice, len(grid.Lattices)},
+		{&topology, len(grid.Topologies)},
+		{&p.States, maxField},
+	}
+	if version >= 2 {
+		fields = append(fields, field{&difficulty, len(grid.Difficulties)})
+	}
+	if version >= 3 {
+		fields = append(fields, field{&target, len(grid.Targets)})
+	}
+	if version >= 4 {
+		fields = append(fields, field{&litOnly, 2})
+	}
+	fields = append(fields, field{&graph, len(graphs) + 1}, field{&count, len(patterns) + 1})
+	for _, f := range fields {
+		v, ok := next(f.limit)
+		if !ok {
+			return Puzzle{}, ErrInvalidCode
+		}
+		*f.dst = v
+	}
+
+	p.Lattice, p.Topology = grid.Lattices[lattice], grid.Topologies[topology]
+	p.Difficulty, p.Target, p.LitOnly = grid.Difficulties[difficulty], grid.Targets[target], litOnly == 1
+	if graph > 0 {
+		p.Graph = graphs[graph-1].Name
+	}
+	for range count {
+		idx, ok := next(len(patterns))
+		if !ok {
+			return Puzzle{}, ErrInvalidCode
+		}
+		p.Neighborhood = append(p.Neighborhood, patterns[idx].Name)
+	}
+
+	if len(rest) != 0 {
+		return Puzzle{}, ErrInvalidCode
+	}
+	return p, nil
+}
+
+// checksum is a single byte of buf's CRC-32: enough to reject all but about 1 in 256
+// mistyped or truncated codes, instead of quietly dealing some other board.
+func checksum(buf []byte) byte {
+	return byte(crc32.ChecksumIEEE(buf))
+}
+
+// Form returns p's configuration as the form fields /reset takes (rows, cols, lattice,
+// topology, states, difficulty, target, litonly if set, graph, and one neighborhood
+// value per pattern), so a decoded code goes through exactly the same parsing and
+// validation as a submitted form.
+func (p Puzzle) Form() url.Values {
+	form := url.Values{}
+	form.Set("rows", strconv.Itoa(p.Rows))
+	form.Set("cols", strconv.Itoa(p.Cols))
+	form.Set("lattice", string(p.Lattice))
+	form.Set("topology", string(p.Topology))
+	form.Set("states", strconv.Itoa(p.States))
+	form.Set("difficulty", string(p.Difficulty))
+	form.Set("target", string(p.Target))
+	if p.LitOnly {
+		form.Set("litonly", "1")
+	}
+	form.Set("graph", p.Graph)
+	for _, name := range p.Neighborhood {
+		form.Add("neighborhood", name)
+	}
+	return form
+}
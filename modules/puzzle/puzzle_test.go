@@ -0,0 +1,171 @@
+package puzzle
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	grid "goSwitch/modules/grid"
+	utils "goSwitch/modules/utils"
+)
+
+var (
+	testPatterns = []utils.Pattern{
+		{Name: "0", Offsets: [][2]int{{0, 0}}},
+		{Name: "4", Offsets: [][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}}},
+		{Name: "knight", Offsets: [][2]int{{1, 2}, {2, 1}}},
+	}
+	testGraphs = []utils.Graph{
+		{Name: "ring", Nodes: 3, Edges: [][2]int{{0, 1}, {1, 2}, {2, 0}}},
+		{Name: "path", Nodes: 3, Edges: [][2]int{{0, 1}, {1, 2}}},
+	}
+)
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	for _, p := range []Puzzle{
+		{Seed: 0, Rows: 5, Cols: 5, Lattice: grid.LatticeSquare, Topology: grid.TopologyBounded, States: 2, Neighborhood: []string{"0", "4"}},
+		{Seed: -1, Rows: 50, Cols: 2, Lattice: grid.LatticeHex, Topology: grid.TopologyTorus, States: 7, Neighborhood: []string{"knight"}},
+		{Seed: 1 << 62, Rows: 3, Cols: 4, Lattice: grid.LatticeSquare, Topology: grid.TopologyBounded, States: 3, Neighborhood: []string{"4", "0"}, Graph: "path"},
+		{Seed: 42, Rows: 7, Cols: 7, Lattice: grid.LatticeSquare, Topology: grid.TopologyBounded, States: 2, Difficulty: grid.DifficultyHard, Neighborhood: []string{"4"}},
+		{Seed: 5, Rows: 6, Cols: 6, Lattice: grid.LatticeSquare, Topology: grid.TopologyBounded, States: 3, Target: "heart", Neighborhood: []string{"0", "4"}},
+		{Seed: 6, Rows: 3, Cols: 3, Lattice: grid.LatticeSquare, Topology: grid.TopologyBounded, States: 2, Target: grid.TargetRandom, Neighborhood: []string{"4"}, Graph: "ring"},
+		{Seed: 8, Rows: 4, Cols: 4, Lattice: grid.LatticeSquare, Topology: grid.TopologyTorus, States: 2, LitOnly: true, Neighborhood: []string{"0", "4"}},
+	} {
+		code, err := Encode(p, testPatterns, testGraphs)
+		if err != nil {
+			t.Fatalf("Encode(%+v) failed: %v", p, err)
+		}
+
+		for _, variant := range []string{code, strings.ToLower(code), " " + code + "\n"} {
+			got, err := Decode(variant, testPatterns, testGraphs)
+			if err != nil {
+				t.Fatalf("Decode(%q) failed: %v", variant, err)
+			}
+			if !reflect.DeepEqual(got, p) {
+				t.Fatalf("Decode(Encode(%+v)) = %+v", p, got)
+			}
+		}
+	}
+}
+
+func TestEncodeRejectsUnknownNames(t *testing.T) {
+	base := Puzzle{Rows: 3, Cols: 3, Lattice: grid.LatticeSquare, Topology: grid.TopologyBounded, States: 2, Neighborhood: []string{"0"}}
+
+	for name, mutate := range map[string]func(*Puzzle){
+		"pattern":    func(p *Puzzle) { p.Neighborhood = []string{"8"} },
+		"graph":      func(p *Puzzle) { p.Graph = "petersen" },
+		"lattice":    func(p *Puzzle) { p.Lattice = "triangle" },
+		"topology":   func(p *Puzzle) { p.Topology = "klein" },
+		"difficulty": func(p *Puzzle) { p.Difficulty = "brutal" },
+		"target":     func(p *Puzzle) { p.Target = "spade" },
+	} {
+		p := base
+		mutate(&p)
+		if _, err := Encode(p, testPatterns, testGraphs); err == nil {
+			t.Fatalf("Encode() accepted an unknown %s", name)
+		}
+	}
+}
+
+func TestDecodeRejectsMalformedCodes(t *testing.T) {
+	valid, err := Encode(Puzzle{Seed: 7, Rows: 3, Cols: 3, Lattice: grid.LatticeSquare, Topology: grid.TopologyBounded, States: 2, Neighborhood: []string{"0", "knight"}, Graph: "ring"}, testPatterns, testGraphs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A code whose checksum is right but whose contents aren't, built the same way
+	// Encode builds one.
+	forge := func(buf []byte) string {
+		return encoding.EncodeToString(append(buf, checksum(buf)))
+	}
+	header := []byte{codeVersion, 0, 0, 0, 0, 0, 0, 0, 0}
+
+	cases := map[string]string{
+		"empty":            "",
+		"not base32":       "not-a-code!",
+		"truncated":        valid[:len(valid)-2],
+		"mistyped":         flipChar(valid, len(valid)/2),
+		"wrong version":    forge(append([]byte{codeVersion + 1}, make([]byte, 15)...)),
+		"header only":      forge(header),
+		"lattice index":    forge(append(header, 3, 3, 9, 0, 2, 0, 0, 0, 0, 1, 0)),
+		"difficulty index": forge(append(header, 3, 3, 0, 0, 2, 4, 0, 0, 0, 1, 0)),
+		"target index":     forge(append(header, 3, 3, 0, 0, 2, 0, 99, 0, 0, 1, 0)),
+		"lit-only flag":    forge(append(header, 3, 3, 0, 0, 2, 0, 0, 2, 0, 1, 0)),
+		"graph index":      forge(append(header, 3, 3, 0, 0, 2, 0, 0, 0, 3, 1, 0)),
+		"pattern index":    forge(append(header, 3, 3, 0, 0, 2, 0, 0, 0, 0, 1, 3)),
+		"missing patterns": forge(append(header, 3, 3, 0, 0, 2, 0, 0, 0, 0, 2, 0)),
+		"trailing bytes":   forge(append(header, 3, 3, 0, 0, 2, 0, 0, 0, 0, 1, 0, 0)),
+		"version zero":     forge(append([]byte{0}, append(header[1:], 3, 3, 0, 0, 2, 0, 0, 0, 0, 1, 0)...)),
+	}
+	for name, code := range cases {
+		if _, err := Decode(code, testPatterns, testGraphs); err == nil {
+			t.Fatalf("Decode() accepted a %s code %q", name, code)
+		}
+	}
+
+	if _, err := Decode(valid, testPatterns[:1], testGraphs); err == nil {
+		t.Fatal("Decode() accepted a code naming a pattern past the configured list")
+	}
+}
+
+func TestDecodeOldVersions(t *testing.T) {
+	// Codes laid out as they were before the difficulty (version 1), target (version
+	// 2) and lit-only (version 3) fields existed.
+	for _, tt := range []struct {
+		buf  []byte
+		want Puzzle
+	}{
+		{
+			[]byte{1, 0, 0, 0, 0, 0, 0, 0, 7, 3, 4, 0, 0, 2, 2, 1, 1},
+			Puzzle{Seed: 7, Rows: 3, Cols: 4, Lattice: grid.LatticeSquare, Topology: grid.TopologyBounded, States: 2, Graph: "path", Neighborhood: []string{"4"}},
+		},
+		{
+			[]byte{2, 0, 0, 0, 0, 0, 0, 0, 7, 3, 4, 0, 0, 2, 3, 2, 1, 1},
+			Puzzle{Seed: 7, Rows: 3, Cols: 4, Lattice: grid.LatticeSquare, Topology: grid.TopologyBounded, States: 2, Difficulty: grid.DifficultyHard, Graph: "path", Neighborhood: []string{"4"}},
+		},
+		{
+			[]byte{3, 0, 0, 0, 0, 0, 0, 0, 7, 3, 4, 0, 0, 2, 3, 1, 2, 1, 1},
+			Puzzle{Seed: 7, Rows: 3, Cols: 4, Lattice: grid.LatticeSquare, Topology: grid.TopologyBounded, States: 2, Difficulty: grid.DifficultyHard, Target: grid.TargetRandom, Graph: "path", Neighborhood: []string{"4"}},
+		},
+	} {
+		code := encoding.EncodeToString(append(tt.buf, checksum(tt.buf)))
+		got, err := Decode(code, testPatterns, testGraphs)
+		if err != nil {
+			t.Fatalf("Decode() rejected a version %d code: %v", tt.buf[0], err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Fatalf("Decode(version %d) = %+v, want %+v", tt.buf[0], got, tt.want)
+		}
+	}
+}
+
+// flipChar replaces code's i-th character with a different valid base32 one.
+func flipChar(code string, i int) string {
+	c := byte('A')
+	if code[i] == 'A' {
+		c = 'B'
+	}
+	return code[:i] + string(c) + code[i+1:]
+}
+
+func TestForm(t *testing.T) {
+	p := Puzzle{Rows: 4, Cols: 6, Lattice: grid.LatticeHex, Topology: grid.TopologyTorus, States: 5, Difficulty: grid.DifficultyMedium, Target: "heart", LitOnly: true, Neighborhood: []string{"0", "knight"}}
+	form := p.Form()
+
+	for key, want := range map[string][]string{
+		"rows":         {"4"},
+		"cols":         {"6"},
+		"lattice":      {"hex"},
+		"topology":     {"torus"},
+		"states":       {"5"},
+		"difficulty":   {"medium"},
+		"target":       {"heart"},
+		"litonly":      {"1"},
+		"graph":        {""},
+		"neighborhood": {"0", "knight"},
+	} {
+		if !reflect.DeepEqual(form[key], want) {
+			t.Fatalf("Form()[%q] = %v, want %v", key, form[key], want)
+		}
+	}
+}
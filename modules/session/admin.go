@@ -0,0 +1,138 @@
+package session
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+)
+
+// Summary is a live session as an operator sees it (see Manager.Summaries): its
+// Handle, when it was created and last active, its board's size -- Graph names a graph
+// board's graph, whose Cols are its nodes -- and how its current game is going.
+type Summary struct {
+	Handle        string
+	CreatedAt     time.Time
+	LastUpdatedAt time.Time
+	Rows          int
+	Cols          int
+	Graph         string
+	Moves         int
+	Won           bool
+}
+
+// Summaries describes every live session, oldest first. Each is read under its own
+// lock, taken after m.mu is let go (sessions are locked before m.mu, never after), so
+// one evicted meanwhile may still be listed -- as it was just before.
+func (m *Manager) Summaries() []Summary {
+	m.mu.Lock()
+	sessions := make([]*Session, 0, len(m.sessions))
+	summaries := make([]Summary, 0, len(m.sessions))
+	for _, s := range m.sessions {
+		sessions = append(sessions, s)
+		summaries = append(summaries, Summary{Handle: Handle(s.ID), CreatedAt: s.CreatedAt, LastUpdatedAt: s.LastUpdatedAt})
+	}
+	m.mu.Unlock()
+
+	for i, s := range sessions {
+		s.Lock()
+		summaries[i].Rows, summaries[i].Cols = s.Rows, s.Cols
+		if s.Graph != nil {
+			summaries[i].Graph = s.Graph.Name
+		}
+		summaries[i].Moves = s.Presses
+		summaries[i].Won = s.Game.CheckWin()
+		s.Unlock()
+	}
+
+	slices.SortFunc(summaries, func(a, b Summary) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	return summaries
+}
+
+// Handle names id's session to an operator without giving its ID away: the ID is all a
+// client needs to take over a session, so it's kept out of everything an operator is
+// shown. A handle is a digest of the ID, so it stays the same for as long as the
+// session does, but the ID can't be worked back out from it.
+func Handle(id string) string {
+	sum := sha256.Sum256([]byte("goswitch-session:" + id))
+	return hex.EncodeToString(sum[:8])
+}
+
+// EvictHandle is Evict for the session Handle names.
+func (m *Manager) EvictHandle(handle string) bool {
+	m.mu.Lock()
+	id, found := "", false
+	for sid := range m.sessions {
+		if Handle(sid) == handle {
+			id, found = sid, true
+			break
+		}
+	}
+	m.mu.Unlock()
+
+	return found && m.Evict(id)
+}
+
+// Evict removes id's session at once, whatever its age, reporting whether there was
+// one. Unlike eviction for a slot, it doesn't skip a session in use: it waits for the
+// request holding it to finish. The client is told its session expired, as for any
+// other eviction, and the slot goes to whoever's waiting.
+func (m *Manager) Evict(id string) bool {
+	m.mu.Lock()
+	s, ok := m.sessions[id]
+	m.mu.Unlock()
+	if !ok {
+		return false
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Gone, or even replaced, while waiting for its lock.
+	if m.sessions[id] != s {
+		return false
+	}
+	delete(m.sessions, id)
+	m.expiredIDs[id] = time.Now()
+	m.deleteStoredLocked(id, s.generation)
+	m.notifyLocked()
+	return true
+}
+
+// MaxSessions returns how many sessions m allows at once.
+func (m *Manager) MaxSessions() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.maxSessions
+}
+
+// SetMaxSessions changes how many sessions m allows at once, from the config's
+// MaxSessions, until the server restarts; n must be at least 1, and fit an int32 like
+// the connection counts it caps. Raising it lets waiting clients straight in. Lowering
+// it below the live count evicts no one: new clients just wait until enough sessions
+// have gone.
+func (m *Manager) SetMaxSessions(n int) error {
+	if n < 1 || n > math.MaxInt32 {
+		return fmt.Errorf("MaxSessions must be in [1, %d], got %d", math.MaxInt32, n)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.maxSessions = n
+	m.notifyLocked()
+	return nil
+}
+
+// Waiting returns how many clients are in line for a slot.
+func (m *Manager) Waiting() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return len(m.queue)
+}
@@ -0,0 +1,117 @@
+package session
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSummariesDescribeSessionsOldestFirst(t *testing.T) {
+	m := NewManager(testConfig(10))
+
+	older, _, _ := m.Claim("older")
+	older.CreatedAt = time.Now().Add(-time.Minute)
+	newer, _, _ := m.Claim("newer")
+	newer.Lock()
+	newer.Game.Switch(0)
+	newer.Presses = 1
+	won := newer.Game.CheckWin() // that one press can happen to win
+	newer.Unlock()
+
+	summaries := m.Summaries()
+	if len(summaries) != 2 || summaries[0].Handle != Handle("older") || summaries[1].Handle != Handle("newer") {
+		t.Fatalf("Summaries() = %+v, want older then newer", summaries)
+	}
+	if got := summaries[1]; got.Rows != 3 || got.Cols != 4 || got.Moves != 1 || got.Won != won {
+		t.Fatalf("Summaries()[1] = %+v, want a 3x4 board one move in, won %v", got, won)
+	}
+}
+
+func TestEvictWaitsForASessionInUse(t *testing.T) {
+	m := NewManager(testConfig(1))
+
+	sess, _, _ := m.Claim("a")
+	m.Claim("b")
+	changed := m.Changed()
+
+	sess.Lock() // an in-flight request on a
+	evicted := make(chan bool)
+	go func() { evicted <- m.Evict("a") }()
+	select {
+	case <-evicted:
+		t.Fatal("Evict() didn't wait for the request holding the session")
+	case <-time.After(50 * time.Millisecond):
+	}
+	sess.Unlock()
+
+	if !<-evicted {
+		t.Fatal("Evict(a) = false, want true")
+	}
+	select {
+	case <-changed:
+	default:
+		t.Fatal("Evict() didn't signal the waiting client")
+	}
+	if _, ok, _ := m.Claim("b"); !ok {
+		t.Fatal("Claim(b) should have got the evicted session's slot")
+	}
+	m.mu.Lock()
+	_, remembered := m.expiredIDs["a"]
+	m.mu.Unlock()
+	if !remembered {
+		t.Fatal("a client whose session was evicted should be told it expired when it gets back in")
+	}
+	if m.Evict("never-there") {
+		t.Fatal("Evict() of an unknown id = true, want false")
+	}
+}
+
+func TestEvictHandle(t *testing.T) {
+	m := NewManager(testConfig(10))
+	m.Claim("a")
+	m.Claim("b")
+
+	if h := Handle("a"); h == Handle("b") {
+		t.Fatalf("Handle() = %q for both sessions, want one apiece", h)
+	}
+	if m.EvictHandle("a") {
+		t.Fatal("EvictHandle() accepted a session ID in place of its handle")
+	}
+	if !m.EvictHandle(Handle("a")) {
+		t.Fatal("EvictHandle(Handle(a)) = false, want true")
+	}
+	if m.Count() != 1 || m.EvictHandle(Handle("a")) {
+		t.Fatalf("after evicting a by handle, Count() = %d, want just b left", m.Count())
+	}
+}
+
+func TestSetMaxSessions(t *testing.T) {
+	m := NewManager(testConfig(1))
+
+	m.Claim("a")
+	if _, ok, _ := m.Claim("b"); ok {
+		t.Fatal("Claim(b) should have had to wait")
+	}
+	if err := m.SetMaxSessions(0); err == nil {
+		t.Fatal("SetMaxSessions(0) succeeded")
+	}
+	if err := m.SetMaxSessions(2); err != nil {
+		t.Fatalf("SetMaxSessions(2) failed: %v", err)
+	}
+	if got := m.MaxSessions(); got != 2 {
+		t.Fatalf("MaxSessions() = %d, want 2", got)
+	}
+	if _, ok, _ := m.Claim("b"); !ok {
+		t.Fatal("Claim(b) should have got in once capacity was raised")
+	}
+
+	// Lowering it leaves both sessions be, but lets no one else in.
+	if err := m.SetMaxSessions(1); err != nil {
+		t.Fatalf("SetMaxSessions(1) failed: %v", err)
+	}
+	if m.Count() != 2 {
+		t.Fatalf("Count() = %d after lowering MaxSessions, want both sessions kept", m.Count())
+	}
+	if _, ok, _ := m.Claim("c"); ok || m.Waiting() != 1 {
+		t.Fatalf("Claim(c) over the lowered capacity = ok %v with %d waiting, want c waiting", ok, m.Waiting())
+	}
+}
@@ -0,0 +1,262 @@
+package session
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// compactSlack is how many superseded records FileStore's journal can pile up beyond
+// one per live session before it's rewritten: every move saves a whole snapshot, so
+// without compaction the journal would grow with every click for as long as the
+// server runs.
+const compactSlack = 1000
+
+// tombstoneTTL is how long FileStore remembers a deleted session's generation, to
+// ignore a save of it still on its way (see Store). Such a save was snapshotted before
+// the delete, so it's never more than a write behind; tombstones older than this are
+// dropped at the next compaction.
+const tombstoneTTL = time.Minute
+
+// FileStore is a Store kept in a single append-only journal file, one JSON record per
+// line: a session's latest snapshot, or its deletion. The live snapshots are also kept
+// in memory, so the journal is only ever read on open, and rewritten down to them --
+// compacted -- then and whenever superseded records pile up past compactSlack.
+//
+// Records are written straight through to the file but not fsynced one by one, so a
+// server crash loses nothing, and an OS crash at most the last few moves; a record
+// torn by one at the journal's end is skipped on the next open.
+type FileStore struct {
+	mu      sync.Mutex
+	path    string
+	file    *os.File
+	live    map[string][]byte
+	records int
+	// deleted is kept in memory only: a save that outran its deletion can't survive
+	// the process it was made in.
+	deleted map[string]tombstone
+}
+
+// tombstone is what FileStore remembers of a Delete: the generation it covers, and
+// when, so it can be forgotten again.
+type tombstone struct {
+	generation uint64
+	at         time.Time
+}
+
+// journalRecord is one line of a FileStore's journal: Save set for a snapshot, or
+// Delete for the id of a session that's gone.
+type journalRecord struct {
+	Save   *Snapshot `json:",omitempty"`
+	Delete string    `json:",omitempty"`
+}
+
+// OpenFileStore opens the journal at path, creating it and any missing directories if
+// there isn't one yet, and compacts it.
+func OpenFileStore(path string) (*FileStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return nil, fmt.Errorf("session store: %w", err)
+	}
+
+	fs := &FileStore{path: path, live: make(map[string][]byte), deleted: make(map[string]tombstone)}
+	if err := fs.read(); err != nil {
+		return nil, fmt.Errorf("session store %s: %w", path, err)
+	}
+	if err := fs.compactLocked(); err != nil {
+		return nil, fmt.Errorf("session store %s: %w", path, err)
+	}
+	return fs, nil
+}
+
+// read replays the journal into fs.live. Only its last line may fail to parse -- a
+// write torn by a crash; anywhere else that's corruption, and an error.
+func (fs *FileStore) read() error {
+	file, err := os.Open(fs.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+
+	reader := bufio.NewReader(file)
+	for lineNo := 1; ; lineNo++ {
+		line, readErr := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			var record journalRecord
+			if err := json.Unmarshal(line, &record); err != nil {
+				if readErr == io.EOF {
+					return nil
+				}
+				return fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			switch {
+			case record.Save != nil:
+				fs.live[record.Save.ID] = bytes.TrimSpace(line)
+			case record.Delete != "":
+				delete(fs.live, record.Delete)
+			}
+		}
+		if readErr == io.EOF {
+			return nil
+		}
+		if readErr != nil {
+			return readErr
+		}
+	}
+}
+
+// Load returns every session's latest snapshot, in no particular order.
+func (fs *FileStore) Load() ([]Snapshot, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	snaps := make([]Snapshot, 0, len(fs.live))
+	for _, line := range fs.live {
+		var record journalRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			return nil, fmt.Errorf("session store %s: %w", fs.path, err)
+		}
+		snaps = append(snaps, *record.Save)
+	}
+	return snaps, nil
+}
+
+// Save appends snap to the journal, superseding any earlier snapshot of its session --
+// unless that session has been deleted since (see Store).
+func (fs *FileStore) Save(snap Snapshot) error {
+	line, err := json.Marshal(journalRecord{Save: &snap})
+	if err != nil {
+		return fmt.Errorf("session store: %w", err)
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if dead, ok := fs.deleted[snap.ID]; ok {
+		if snap.Generation <= dead.generation {
+			return nil
+		}
+		delete(fs.deleted, snap.ID)
+	}
+
+	if err := fs.appendLocked(line); err != nil {
+		return err
+	}
+	fs.live[snap.ID] = line
+	return fs.maybeCompactLocked()
+}
+
+// Delete appends id's deletion to the journal, if it has a snapshot there, and holds
+// off saves of its generation or older for tombstoneTTL.
+func (fs *FileStore) Delete(id string, generation uint64) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if dead, ok := fs.deleted[id]; !ok || dead.generation < generation {
+		fs.deleted[id] = tombstone{generation: generation, at: time.Now()}
+	}
+
+	if _, ok := fs.live[id]; !ok {
+		return nil
+	}
+
+	line, err := json.Marshal(journalRecord{Delete: id})
+	if err != nil {
+		return fmt.Errorf("session store: %w", err)
+	}
+	if err := fs.appendLocked(line); err != nil {
+		return err
+	}
+	delete(fs.live, id)
+	return fs.maybeCompactLocked()
+}
+
+// Close flushes the journal to disk and closes it. The store can't be used after.
+func (fs *FileStore) Close() error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if fs.file == nil {
+		return nil
+	}
+	syncErr := fs.file.Sync()
+	closeErr := fs.file.Close()
+	fs.file = nil
+	return errors.Join(syncErr, closeErr)
+}
+
+func (fs *FileStore) appendLocked(line []byte) error {
+	if fs.file == nil {
+		return fmt.Errorf("session store %s: closed", fs.path)
+	}
+	if _, err := fs.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("session store %s: %w", fs.path, err)
+	}
+	fs.records++
+	return nil
+}
+
+func (fs *FileStore) maybeCompactLocked() error {
+	if fs.records <= len(fs.live)+compactSlack {
+		return nil
+	}
+	return fs.compactLocked()
+}
+
+// compactLocked rewrites the journal down to one record per live session. The new
+// journal is written and synced beside the old one, then renamed over it, so a crash
+// partway through leaves one or the other intact, never a mix.
+func (fs *FileStore) compactLocked() error {
+	tmpPath := fs.path + ".tmp"
+	tmp, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600) //nolint:gosec // path is a trusted, operator-supplied config value, not user input
+	if err != nil {
+		return err
+	}
+
+	writer := bufio.NewWriter(tmp)
+	for _, line := range fs.live {
+		if _, err := writer.Write(append(line, '\n')); err != nil {
+			_ = tmp.Close()
+			return err
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, fs.path); err != nil {
+		return err
+	}
+
+	if fs.file != nil {
+		_ = fs.file.Close()
+	}
+	fs.file, err = os.OpenFile(fs.path, os.O_APPEND|os.O_WRONLY, 0o600) //nolint:gosec // as above
+	if err != nil {
+		return err
+	}
+	fs.records = len(fs.live)
+
+	for id, dead := range fs.deleted {
+		if time.Since(dead.at) >= tombstoneTTL {
+			delete(fs.deleted, id)
+		}
+	}
+	return nil
+}
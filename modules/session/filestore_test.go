@@ -0,0 +1,156 @@
+package session
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func openTestFileStore(t *testing.T, path string) *FileStore {
+	t.Helper()
+
+	fs, err := OpenFileStore(path)
+	if err != nil {
+		t.Fatalf("OpenFileStore(%s) failed: %v", path, err)
+	}
+	t.Cleanup(func() { _ = fs.Close() })
+	return fs
+}
+
+func loadIDs(t *testing.T, fs *FileStore) map[string]int {
+	t.Helper()
+
+	snaps, err := fs.Load()
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	ids := make(map[string]int, len(snaps))
+	for _, snap := range snaps {
+		ids[snap.ID] = snap.Presses
+	}
+	return ids
+}
+
+func TestFileStoreKeepsLatestSnapshots(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "journal")
+	fs := openTestFileStore(t, path)
+
+	for _, snap := range []Snapshot{{ID: "a", Presses: 1}, {ID: "b", Presses: 1}, {ID: "a", Presses: 2}} {
+		if err := fs.Save(snap); err != nil {
+			t.Fatalf("Save(%+v) failed: %v", snap, err)
+		}
+	}
+	if err := fs.Delete("b", 0); err != nil {
+		t.Fatalf("Delete(b) failed: %v", err)
+	}
+	if err := fs.Delete("never-saved", 0); err != nil {
+		t.Fatalf("Delete() of an id with nothing stored failed: %v", err)
+	}
+	if err := fs.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+	if err := fs.Save(Snapshot{ID: "c"}); err == nil {
+		t.Fatal("Save() after Close() succeeded")
+	}
+
+	reopened := openTestFileStore(t, path)
+	if ids := loadIDs(t, reopened); len(ids) != 1 || ids["a"] != 2 {
+		t.Fatalf("reopened store holds %v, want only a's latest snapshot (2 presses)", ids)
+	}
+
+	// Reopening compacted the journal down to that one snapshot.
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading the journal failed: %v", err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines != 1 {
+		t.Fatalf("compacted journal has %d lines, want 1", lines)
+	}
+}
+
+// TestFileStoreIgnoresSavesOfDeletedSessions checks a save that lands after its
+// session's deletion is dropped, even when there was nothing stored to delete yet, while
+// a newer session with the same id is saved as usual.
+func TestFileStoreIgnoresSavesOfDeletedSessions(t *testing.T) {
+	fs := openTestFileStore(t, filepath.Join(t.TempDir(), "journal"))
+
+	if err := fs.Save(Snapshot{ID: "a", Generation: 1, Presses: 1}); err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+	for _, id := range []string{"a", "b"} {
+		if err := fs.Delete(id, 1); err != nil {
+			t.Fatalf("Delete(%s) failed: %v", id, err)
+		}
+		if err := fs.Save(Snapshot{ID: id, Generation: 1, Presses: 2}); err != nil {
+			t.Fatalf("Save() of deleted %s failed: %v", id, err)
+		}
+	}
+	if ids := loadIDs(t, fs); len(ids) != 0 {
+		t.Fatalf("store holds %v after saves of deleted sessions, want nothing", ids)
+	}
+
+	if err := fs.Save(Snapshot{ID: "a", Generation: 2, Presses: 3}); err != nil {
+		t.Fatalf("Save() of a newer session failed: %v", err)
+	}
+	if ids := loadIDs(t, fs); len(ids) != 1 || ids["a"] != 3 {
+		t.Fatalf("store holds %v, want the newer session's snapshot (3 presses)", ids)
+	}
+}
+
+func TestFileStoreSkipsTornLastRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal")
+	fs := openTestFileStore(t, path)
+	if err := fs.Save(Snapshot{ID: "a", Presses: 3}); err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+	if err := fs.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		t.Fatalf("opening the journal failed: %v", err)
+	}
+	if _, err := file.WriteString(`{"Save":{"ID":"b","Pre`); err != nil {
+		t.Fatalf("tearing the journal failed: %v", err)
+	}
+	_ = file.Close()
+
+	if ids := loadIDs(t, openTestFileStore(t, path)); len(ids) != 1 || ids["a"] != 3 {
+		t.Fatalf("store with a torn last record holds %v, want just a", ids)
+	}
+}
+
+func TestFileStoreRejectsCorruptJournal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal")
+	if err := os.WriteFile(path, []byte("not json\n{\"Delete\":\"a\"}\n"), 0o600); err != nil {
+		t.Fatalf("writing the journal failed: %v", err)
+	}
+
+	if _, err := OpenFileStore(path); err == nil {
+		t.Fatal("OpenFileStore() accepted a journal corrupt before its last record")
+	}
+}
+
+func TestFileStoreCompactsAsItGoes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal")
+	fs := openTestFileStore(t, path)
+
+	for i := range compactSlack + 10 {
+		if err := fs.Save(Snapshot{ID: "a", Presses: i}); err != nil {
+			t.Fatalf("Save() failed: %v", err)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading the journal failed: %v", err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines > compactSlack {
+		t.Fatalf("journal grew to %d lines for one session, want it compacted", lines)
+	}
+	if ids := loadIDs(t, fs); ids["a"] != compactSlack+9 {
+		t.Fatalf("store holds %v after compacting, want a's latest snapshot", ids)
+	}
+}
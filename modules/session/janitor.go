@@ -0,0 +1,72 @@
+package session
+
+import (
+	"context"
+	"time"
+)
+
+// janitorRetry is how soon the Janitor sweeps again when a session it should have
+// evicted was in use (see evictLocked), rather than spinning until it's let go.
+const janitorRetry = time.Second
+
+// Janitor evicts sessions as they fall due, until ctx is done: every session once it's
+// past SessionTTLSeconds, and -- only while clients are waiting for more slots than
+// are free -- every idle one past SessionIdleTimeoutSeconds, just as Claim would for
+// them; it also drops abandoned places in line. Rather than sweeping on a fixed tick,
+// it sleeps until the next of those falls due or the manager changes (see Changed), so
+// a waiting client is woken the moment there's a slot for it instead of whenever it
+// next happens to check. It's meant to run in its own goroutine, one per Manager.
+func (m *Manager) Janitor(ctx context.Context) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		changed := m.Changed()
+		timer.Reset(m.sweep(time.Now()))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-changed:
+		case <-timer.C:
+		}
+	}
+}
+
+// sweep evicts whatever is due as of now (see Janitor) and returns how long until
+// something next could be.
+func (m *Manager) sweep(now time.Time) time.Duration {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.pruneExpiredIDsLocked(now)
+	m.pruneQueueLocked(now)
+	m.evictExpiredLocked(now)
+	pressed := len(m.queue) > 0 && len(m.sessions)+len(m.queue) > m.maxSessions
+	if pressed {
+		m.evictIdleLocked(now)
+	}
+
+	// A session created from here on can't fall due for a whole TTL, nor can a
+	// waiter's idle-timeout pressure arise without the queue changing; either way
+	// the wait ends early.
+	next := m.ttl
+	due := func(at time.Time) {
+		next = min(next, at.Sub(now))
+	}
+	for _, s := range m.sessions {
+		due(s.CreatedAt.Add(m.ttl))
+		if pressed {
+			due(s.LastUpdatedAt.Add(m.idleTimeout))
+		}
+	}
+	for _, w := range m.queue {
+		if m.watching[w.id] == 0 {
+			due(w.seen.Add(queueGrace))
+		}
+	}
+	if next <= 0 {
+		return janitorRetry
+	}
+	return next
+}
@@ -0,0 +1,86 @@
+package session
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSweepEvictsPastTTLWithoutAClaim(t *testing.T) {
+	m := NewManager(testConfig(10))
+
+	old, _, _ := m.Claim("old")
+	old.CreatedAt = time.Now().Add(-testTTLSeconds * time.Second)
+	m.Claim("fresh")
+	changed := m.Changed()
+
+	next := m.sweep(time.Now())
+	if got := m.Count(); got != 1 {
+		t.Fatalf("Count() after sweeping = %d, want just the fresh session left", got)
+	}
+	if _, ok := m.Lookup("old"); ok {
+		t.Fatal("the session past its TTL survived the sweep")
+	}
+	select {
+	case <-changed:
+	default:
+		t.Fatal("evicting a session didn't signal Changed")
+	}
+	if next <= 0 || next > testTTLSeconds*time.Second {
+		t.Fatalf("sweep() = %v until the next eviction, want at most the fresh session's TTL", next)
+	}
+}
+
+func TestSweepEvictsIdleSessionsOnlyForWaiters(t *testing.T) {
+	m := NewManager(testConfig(1))
+
+	idle, _, _ := m.Claim("idle")
+	idle.LastUpdatedAt = time.Now().Add(-testIdleSeconds * time.Second)
+
+	m.sweep(time.Now())
+	if _, ok := m.Lookup("idle"); !ok {
+		t.Fatal("sweep evicted an idle session with nobody waiting for its slot")
+	}
+
+	// Touched again first, so it's the sweep that evicts it rather than the Claim.
+	m.Claim("idle")
+	if _, ok, _ := m.Claim("waiter"); ok {
+		t.Fatal("Claim(waiter) should have had to wait")
+	}
+	idle.LastUpdatedAt = time.Now().Add(-testIdleSeconds * time.Second)
+	m.sweep(time.Now())
+	if _, ok := m.Lookup("idle"); ok {
+		t.Fatal("sweep kept an idle session a client is waiting for the slot of")
+	}
+}
+
+func TestJanitorWakesWaitersTheMomentASlotFrees(t *testing.T) {
+	m := NewManager(testConfig(1))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go m.Janitor(ctx)
+
+	sess, _, _ := m.Claim("a")
+	m.mu.Lock()
+	sess.LastUpdatedAt = time.Now().Add(-testIdleSeconds*time.Second + 200*time.Millisecond) // idles out 200ms from now
+	m.mu.Unlock()
+
+	changed := m.Changed()
+	if _, ok, _ := m.Claim("b"); ok {
+		t.Fatal("Claim(b) should have had to wait: a isn't idle yet")
+	}
+
+	deadline := time.After(5 * time.Second)
+	for {
+		select {
+		case <-changed:
+		case <-deadline:
+			t.Fatal("the janitor never freed a's slot once it idled out")
+		}
+		changed = m.Changed()
+		if _, ok, _ := m.Claim("b"); ok {
+			return
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package session
+
+import (
+	"slices"
+	"time"
+)
+
+// queueGrace is how long a place in line is kept for a client nothing is watching it
+// for (see Manager.Watch): long enough to cover the gap between the waiting page
+// loading and its /wait stream connecting, or that stream reconnecting after a
+// dropped connection, but no longer -- a client that closed the tab would otherwise
+// hold up everyone behind it for good.
+const queueGrace = 30 * time.Second
+
+// waiter is one client's place in a Manager's waiting queue: its session id, and when
+// it was last heard from.
+type waiter struct {
+	id   string
+	seen time.Time
+}
+
+// Changed returns a channel that's closed the next time a slot may have freed up or the
+// waiting queue moves: a session evicted, or a client joining or leaving the line. It's
+// for a waiter to block on between checks -- taking the channel before checking, so a
+// change that lands in between still wakes it -- rather than polling on a timer.
+func (m *Manager) Changed() <-chan struct{} {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.changed
+}
+
+// notifyLocked wakes everything blocked on Changed.
+func (m *Manager) notifyLocked() {
+	close(m.changed)
+	m.changed = make(chan struct{})
+}
+
+// QueuePosition returns where id stands in the line for a session slot: its 1-based
+// position, and how many are waiting in all. pos is 0 if id isn't queued -- it has a
+// session, or the line was full when it asked. Asking counts as being heard from, so
+// it keeps id's place.
+func (m *Manager) QueuePosition(id string) (pos, total int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.pruneQueueLocked(time.Now())
+	idx := m.queueIndexLocked(id)
+	if idx < 0 {
+		return 0, len(m.queue)
+	}
+	m.queue[idx].seen = time.Now()
+	return idx + 1, len(m.queue)
+}
+
+// Watch marks id's place in line as followed by a live connection, which keeps it for
+// as long as the connection lasts, however long that is; the returned func, to be
+// called when the connection ends, unmarks it. Watching an id isn't queued yet (or
+// any more) is harmless: it keeps whatever place Claim gives it meanwhile.
+func (m *Manager) Watch(id string) (unwatch func()) {
+	m.mu.Lock()
+	m.watching[id]++
+	m.mu.Unlock()
+
+	return func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		if m.watching[id]--; m.watching[id] <= 0 {
+			delete(m.watching, id)
+		}
+		// The grace period starts now, should the connection be coming straight back.
+		if idx := m.queueIndexLocked(id); idx >= 0 {
+			m.queue[idx].seen = time.Now()
+		}
+	}
+}
+
+// queueAheadLocked returns how many waiters are ahead of id in line -- everyone, if
+// it isn't in it.
+func (m *Manager) queueAheadLocked(id string) int {
+	if idx := m.queueIndexLocked(id); idx >= 0 {
+		return idx
+	}
+	return len(m.queue)
+}
+
+func (m *Manager) queueIndexLocked(id string) int {
+	return slices.IndexFunc(m.queue, func(w waiter) bool { return w.id == id })
+}
+
+// enqueueLocked joins id to the back of the line, or if it's already in it, notes it
+// was heard from. Once the line holds maxQueued waiters, newcomers aren't added: they
+// still wait, behind everyone, until there's room.
+func (m *Manager) enqueueLocked(id string, now time.Time) {
+	if idx := m.queueIndexLocked(id); idx >= 0 {
+		m.queue[idx].seen = now
+		return
+	}
+	if m.maxQueued > 0 && len(m.queue) >= m.maxQueued {
+		return
+	}
+	m.queue = append(m.queue, waiter{id: id, seen: now})
+	m.notifyLocked()
+}
+
+func (m *Manager) dequeueLocked(id string) {
+	if idx := m.queueIndexLocked(id); idx >= 0 {
+		m.queue = slices.Delete(m.queue, idx, idx+1)
+		m.notifyLocked()
+	}
+}
+
+// pruneQueueLocked drops waiters nothing is watching that haven't been heard from in
+// queueGrace, moving everyone behind them up.
+func (m *Manager) pruneQueueLocked(now time.Time) {
+	waiting := len(m.queue)
+	m.queue = slices.DeleteFunc(m.queue, func(w waiter) bool {
+		return m.watching[w.id] == 0 && now.Sub(w.seen) >= queueGrace
+	})
+	if len(m.queue) < waiting {
+		m.notifyLocked()
+	}
+}
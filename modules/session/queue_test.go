@@ -0,0 +1,102 @@
+package session
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClaimAdmitsWaitersFirstComeFirstServed(t *testing.T) {
+	m := NewManager(testConfig(1))
+
+	sessA, _, _ := m.Claim("a")
+	for _, id := range []string{"b", "c"} {
+		if _, ok, _ := m.Claim(id); ok {
+			t.Fatalf("Claim(%s) while full should have failed", id)
+		}
+	}
+	if pos, total := m.QueuePosition("c"); pos != 2 || total != 2 {
+		t.Fatalf("QueuePosition(c) = %d of %d, want 2 of 2", pos, total)
+	}
+
+	sessA.CreatedAt = time.Now().Add(-2 * time.Hour) // frees the slot, for b
+	if _, ok, _ := m.Claim("c"); ok {
+		t.Fatal("Claim(c) took the freed slot from b, who was ahead of it in line")
+	}
+	if _, ok, _ := m.Claim("newcomer"); ok {
+		t.Fatal("Claim() of a client not in line jumped it")
+	}
+	if _, ok, _ := m.Claim("b"); !ok {
+		t.Fatal("Claim(b) at the head of the line should have got the freed slot")
+	}
+	if pos, total := m.QueuePosition("c"); pos != 1 || total != 2 {
+		t.Fatalf("QueuePosition(c) after b got in = %d of %d, want 1 of 2 (with the newcomer behind)", pos, total)
+	}
+	if pos, _ := m.QueuePosition("b"); pos != 0 {
+		t.Fatalf("QueuePosition(b) with a session = %d, want 0", pos)
+	}
+}
+
+func TestClaimAdmitsWaitersBehindTheHeadWhenThereIsRoom(t *testing.T) {
+	m := NewManager(testConfig(3))
+
+	sessA, _, _ := m.Claim("a")
+	sessB, _, _ := m.Claim("b")
+	m.Claim("c")
+	m.Claim("d")
+	m.Claim("e")
+	sessA.CreatedAt = time.Now().Add(-2 * time.Hour)
+	sessB.CreatedAt = time.Now().Add(-2 * time.Hour)
+
+	// Two slots free up: one each for d and e, so e needn't wait for d to take its own.
+	if _, ok, _ := m.Claim("e"); !ok {
+		t.Fatal("Claim(e) should have got in: two slots freed for the two waiters")
+	}
+	if _, ok, _ := m.Claim("newcomer"); ok {
+		t.Fatal("Claim() of a client not in line took the slot held for d")
+	}
+	if _, ok, _ := m.Claim("d"); !ok {
+		t.Fatal("Claim(d) should have got the slot held for it")
+	}
+}
+
+func TestQueueDropsAbandonedWaiters(t *testing.T) {
+	m := NewManager(testConfig(1))
+
+	m.Claim("a")
+	m.Claim("gone")
+	m.Claim("watched")
+	m.Claim("c")
+	unwatch := m.Watch("watched")
+
+	m.mu.Lock()
+	for i := range m.queue {
+		m.queue[i].seen = time.Now().Add(-queueGrace)
+	}
+	m.mu.Unlock()
+
+	if pos, total := m.QueuePosition("c"); pos != 0 || total != 1 {
+		t.Fatalf("QueuePosition(c) = %d of %d, want c dropped and only the watched waiter kept", pos, total)
+	}
+	if pos, _ := m.QueuePosition("watched"); pos != 1 {
+		t.Fatalf("QueuePosition(watched) = %d, want 1: a watched waiter keeps its place", pos)
+	}
+
+	// Once nothing watches it, its grace period starts over rather than running out.
+	unwatch()
+	if pos, _ := m.QueuePosition("watched"); pos != 1 {
+		t.Fatalf("QueuePosition(watched) just after unwatching = %d, want 1", pos)
+	}
+}
+
+func TestQueueIsCappedAtMaxWaitingConnections(t *testing.T) {
+	config := testConfig(1)
+	config.MaxWaitingConnections = 2
+	m := NewManager(config)
+
+	for _, id := range []string{"a", "b", "c", "d"} {
+		m.Claim(id)
+	}
+	if pos, total := m.QueuePosition("d"); pos != 0 || total != 2 {
+		t.Fatalf("QueuePosition(d) = %d of %d, want it left out of a full line of 2", pos, total)
+	}
+}
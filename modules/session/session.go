@@ -1,6 +1,8 @@
 // Package session implements per-client game sessions: a capacity-bounded,
-// cookie-keyed Manager that lazily purges TTL-expired or idle sessions only when
-// a new one needs a slot.
+// cookie-keyed Manager that purges TTL-expired sessions, and idle ones only when a new
+// one needs a slot, queues clients first come, first served while there's none --
+// waking them the moment one frees up -- and can keep them in a Store across server
+// restarts.
 package session
 
 import (
@@ -14,26 +16,155 @@ import (
 	utils "goSwitch/modules/utils"
 )
 
-// Session holds one client's isolated game state. Dim, Cheat, ToggleSequence, and Game
-// are guarded by the embedded sync.Mutex -- callers must sess.Lock()/sess.Unlock()
-// around any access. CreatedAt and LastUpdatedAt are a different lock domain, owned by
-// Manager: CreatedAt is written once at construction (under m.mu, before the session is
-// ever handed out) and never changes afterward, so reading it is safe without any lock;
-// LastUpdatedAt is repeatedly bumped by Claim under m.mu and must not be read directly
-// from outside the session package -- use Manager.SessionMaxAge for the one thing
-// callers actually need it for (the session's remaining TTL).
+// Session holds one client's isolated game state. Rows, Cols, Lattice, Topology, States,
+// Graph, Difficulty, Target, LitOnly, Cheat, Budget, TimeLimit, ToggleSequence, Game,
+// GameStarted, FirstMoveAt, WonAt, Clicks, HintsUsed, Presses, MoveLimit, Daily and
+// DailySolved are guarded by the embedded sync.Mutex -- callers must
+// sess.Lock()/sess.Unlock() around any access. Graph is nil for a lattice board; the
+// graph it points to is shared config, never modified. GameStarted, FirstMoveAt, WonAt,
+// Clicks, HintsUsed, Presses, MoveLimit and Daily describe the current Game only, so
+// whoever replaces Game must reset them too. TimeLimit is how long each game has from
+// being dealt before it's lost, or 0 for no limit. CreatedAt and LastUpdatedAt are a
+// different lock domain, owned by Manager: CreatedAt is written once at construction
+// (under m.mu, before the session is ever handed out) and never changes afterward, so
+// reading it is safe without any lock; LastUpdatedAt is repeatedly bumped by Claim
+// under m.mu and must not be read directly from outside the session package -- use
+// Manager.SessionMaxAge for the one thing callers actually need it for (the session's
+// remaining TTL).
 type Session struct {
 	ID             string
-	Dim            int
+	Rows           int
+	Cols           int
+	Lattice        grid.Lattice
+	Topology       grid.Topology
+	States         int
+	Graph          *grid.Graph
+	Difficulty     grid.Difficulty
+	Target         grid.Target
+	LitOnly        bool
 	Cheat          bool
+	Budget         utils.MoveBudget
+	TimeLimit      time.Duration
 	ToggleSequence []bool
 	Game           *grid.Grid
-	CreatedAt      time.Time
-	LastUpdatedAt  time.Time
+	// GameStarted is when Game was dealt, and Clicks every move made on it since, in
+	// order -- together with Game.Restart, everything a replay needs.
+	GameStarted time.Time
+	// FirstMoveAt and WonAt are when Game was first pressed and first won, or zero
+	// if it hasn't been yet. Both are set once and kept, so undoing a win doesn't
+	// take back its time.
+	FirstMoveAt time.Time
+	WonAt       time.Time
+	Clicks      []Click
+	HintsUsed   int
+	// Presses counts every Switch on Game, unlike its move history, which drops
+	// presses that cancel out.
+	Presses int
+	// MoveLimit is what Budget came to for the current Game: how many presses it
+	// allows, or 0 for no limit.
+	MoveLimit int
+	// Daily is the UTC date (see DailyDate) of the daily puzzle Game is, or empty if
+	// it isn't one.
+	Daily string
+	// DailySolved is the best result on the most recent daily puzzle this session
+	// solved, or zero if none.
+	DailySolved   DailyResult
+	CreatedAt     time.Time
+	LastUpdatedAt time.Time
+
+	// generation tells s apart from every other session that has had, or will have,
+	// its ID (see Manager.generation), so a Store can ignore a save of s that lands
+	// after its deletion. Set once, when s is created or restored.
+	generation uint64
 
 	sync.Mutex
 }
 
+// Click is one move in a game's replay: a press of Pos, or with Undo, a RevertMove
+// taking one back. A redo is just a press again.
+type Click struct {
+	Pos  int
+	Undo bool
+	At   time.Time
+}
+
+// RecordClick appends a move on s's current Game to s.Clicks, timestamped now. The
+// caller must hold s's lock.
+func (s *Session) RecordClick(pos int, undo bool) {
+	s.Clicks = append(s.Clicks, Click{Pos: pos, Undo: undo, At: time.Now()})
+}
+
+// DailyResult is a solved daily puzzle: its date and the fewest presses it took.
+type DailyResult struct {
+	Date  string
+	Moves int
+}
+
+// DailyDate formats t as a daily puzzle's date: its UTC calendar day.
+func DailyDate(t time.Time) string {
+	return t.UTC().Format(time.DateOnly)
+}
+
+// RecordDailyWin records s's current Game, which must be won, as solved in s.Presses
+// presses, if it's a daily puzzle -- keeping the fewest presses across replays of the
+// same day's. The caller must hold s's lock.
+func (s *Session) RecordDailyWin() {
+	if s.Daily == "" {
+		return
+	}
+	if s.DailySolved.Date == s.Daily && s.DailySolved.Moves <= s.Presses {
+		return
+	}
+	s.DailySolved = DailyResult{Date: s.Daily, Moves: s.Presses}
+}
+
+// MovesLeft returns how many more presses s's current Game allows under its MoveLimit;
+// limited is false if it has none. The caller must hold s's lock.
+func (s *Session) MovesLeft() (left int, limited bool) {
+	if s.MoveLimit == 0 {
+		return 0, false
+	}
+	return max(s.MoveLimit-s.Presses, 0), true
+}
+
+// RecordPressTime notes a press on s's current Game at now, which won it if won: the
+// first press sets FirstMoveAt, and the first win, WonAt. The caller must hold s's lock.
+func (s *Session) RecordPressTime(now time.Time, won bool) {
+	if s.FirstMoveAt.IsZero() {
+		s.FirstMoveAt = now
+	}
+	if won && s.WonAt.IsZero() {
+		s.WonAt = now
+	}
+}
+
+// Elapsed returns how long s's current Game has been played as of now, from being
+// dealt until it was won -- its solve time, once it has been. The caller must hold s's
+// lock.
+func (s *Session) Elapsed(now time.Time) time.Duration {
+	if !s.WonAt.IsZero() {
+		now = s.WonAt
+	}
+	return max(now.Sub(s.GameStarted), 0)
+}
+
+// TimeLeft returns how much of s's TimeLimit its current Game has left as of now,
+// stopped at the win; limited is false if it has none. A game with a limit and no time
+// left is lost. The caller must hold s's lock.
+func (s *Session) TimeLeft(now time.Time) (left time.Duration, limited bool) {
+	if s.TimeLimit == 0 {
+		return 0, false
+	}
+	return max(s.TimeLimit-s.Elapsed(now), 0), true
+}
+
+// TimedOut reports whether s's current Game ran out of time before it was won. The
+// caller must hold s's lock.
+func (s *Session) TimedOut(now time.Time) bool {
+	left, limited := s.TimeLeft(now)
+	return limited && left == 0 && s.WonAt.IsZero()
+}
+
 // NewID returns a random, URL/cookie-safe session identifier. Callers must handle a
 // non-nil error explicitly (e.g. render an error response) rather than relying on a
 // panic + recover-middleware safety net.
@@ -45,8 +176,9 @@ func NewID() (string, error) {
 	return hex.EncodeToString(b), nil
 }
 
-// Manager tracks live sessions and enforces MaxSessions/TTL/idle-timeout, purging
-// lazily: only when a slot is actually needed and the manager is at capacity.
+// Manager tracks live sessions and enforces MaxSessions/TTL/idle-timeout. A session
+// past its TTL is purged by the Janitor as soon as it's due; an idle one only when a
+// slot is actually needed -- a client is waiting -- and the manager is at capacity.
 type Manager struct {
 	mu       sync.Mutex
 	sessions map[string]*Session
@@ -59,38 +191,92 @@ type Manager struct {
 	// sessions" rather than growing for the server's whole lifetime.
 	expiredIDs map[string]time.Time
 
+	// queue is the line of clients waiting for a slot, oldest first, at most maxQueued
+	// long (0 for no limit); watching counts the live connections following each
+	// waiter's place (see Watch). A freed slot goes to the head of the line: Claim only
+	// creates a session for id if fewer waiters are ahead of it than there are free
+	// slots, a client not in line being behind all of them.
+	queue     []waiter
+	watching  map[string]int
+	maxQueued int
+	// changed is closed, and replaced, whenever a slot may have freed up or the queue
+	// moves (see Changed).
+	changed chan struct{}
+
 	maxSessions int
 	ttl         time.Duration
 	idleTimeout time.Duration
 
-	defaultDim            int
+	defaultRows           int
+	defaultCols           int
+	defaultLattice        grid.Lattice
+	defaultTopology       grid.Topology
+	defaultStates         int
+	defaultGraph          *grid.Graph
+	defaultDifficulty     grid.Difficulty
+	defaultTarget         grid.Target
+	defaultLitOnly        bool
 	defaultCheat          bool
+	defaultBudget         utils.MoveBudget
+	defaultTimeLimit      time.Duration
 	defaultToggleSequence []bool
-	defaultNeighborhood   []int
+	defaultNeighborhood   []utils.Pattern
+
+	// patterns and graphs are the config's, for restoring a stored session's board
+	// by name; store is nil unless Persist has been called.
+	patterns []utils.Pattern
+	graphs   []grid.Graph
+	store    Store
+
+	// generation is the last one handed to a session (see Session.generation): it
+	// only ever grows, including past every restored session's.
+	generation uint64
 }
 
 func NewManager(config *utils.Config) *Manager {
+	spec := grid.DefaultSpec(config)
+	// Already validated by utils.ParseJSONConfig; a hand-built config with a bad one
+	// gets no limit.
+	budget, _ := utils.ParseMoveBudget(config.MoveBudget)
+
 	return &Manager{
 		sessions:              make(map[string]*Session),
 		expiredIDs:            make(map[string]time.Time),
+		watching:              make(map[string]int),
+		changed:               make(chan struct{}),
+		maxQueued:             config.MaxWaitingConnections,
 		maxSessions:           config.MaxSessions,
 		ttl:                   time.Duration(config.SessionTTLSeconds) * time.Second,
 		idleTimeout:           time.Duration(config.SessionIdleTimeoutSeconds) * time.Second,
-		defaultDim:            config.Dim,
+		defaultRows:           spec.Rows,
+		defaultCols:           spec.Cols,
+		defaultLattice:        spec.Lattice,
+		defaultTopology:       spec.Topology,
+		defaultStates:         spec.States,
+		defaultGraph:          spec.Graph,
+		defaultDifficulty:     spec.Difficulty,
+		defaultTarget:         spec.Target,
+		defaultLitOnly:        spec.LitOnly,
 		defaultCheat:          config.Cheat,
+		defaultBudget:         budget,
+		defaultTimeLimit:      time.Duration(config.TimeLimitSeconds) * time.Second,
 		defaultToggleSequence: append([]bool(nil), config.ToggleSequence...),
-		defaultNeighborhood:   utils.BuildNeighborhoodFromConfig(config),
+		defaultNeighborhood:   spec.Neighborhood,
+		patterns:              config.Patterns,
+		graphs:                config.Graphs,
 	}
 }
 
 // Claim returns the existing session for id, bumping its LastUpdatedAt (expired=false:
 // an active touch is never an expiry). If no session exists for id, it tries to create
-// one, opportunistically evicting TTL-expired then idle-timed-out sessions if the
-// manager is at capacity. Returns ok=false only when still at capacity after eviction
-// attempts -- the caller must have the client wait. expired reports whether id
-// previously had a real session that was since evicted (as opposed to id being brand
-// new, or having only ever failed to get a session while waiting for a slot) -- the
-// caller can use this to tell a genuinely re-expired client from a first-timer.
+// one, opportunistically evicting TTL-expired then idle-timed-out sessions if there
+// aren't enough free slots left for the clients waiting ahead of id and id itself.
+// Returns ok=false, with id queued (see QueuePosition), only when there still aren't
+// after eviction attempts -- the caller must have the client wait. expired reports
+// whether id previously had a real session that was since evicted (as opposed to id
+// being brand new, or having only ever failed to get a session while waiting for a
+// slot) -- the caller can use this to tell a genuinely re-expired client from a
+// first-timer.
 func (m *Manager) Claim(id string) (sess *Session, ok bool, expired bool) {
 	m.mu.Lock()
 
@@ -102,18 +288,22 @@ func (m *Manager) Claim(id string) (sess *Session, ok bool, expired bool) {
 
 	now := time.Now()
 	m.pruneExpiredIDsLocked(now)
+	m.pruneQueueLocked(now)
 
-	if len(m.sessions) >= m.maxSessions {
+	ahead := m.queueAheadLocked(id)
+	if len(m.sessions)+ahead >= m.maxSessions {
 		m.evictExpiredLocked(now)
 	}
-	if len(m.sessions) >= m.maxSessions {
+	if len(m.sessions)+ahead >= m.maxSessions {
 		m.evictIdleLocked(now)
 	}
-	if len(m.sessions) >= m.maxSessions {
+	if len(m.sessions)+ahead >= m.maxSessions {
+		m.enqueueLocked(id, now)
 		m.mu.Unlock()
 		return nil, false, false
 	}
 
+	m.dequeueLocked(id)
 	_, wasExpired := m.expiredIDs[id]
 	delete(m.expiredIDs, id)
 
@@ -121,17 +311,31 @@ func (m *Manager) Claim(id string) (sess *Session, ok bool, expired bool) {
 	m.mu.Unlock()
 
 	// grid.NewGrid can internally retry up to its own bounded limit for structurally
-	// degenerate (dim, neighborhood) combinations -- built outside m.mu so it can't
+	// degenerate (size, neighborhood) combinations -- built outside m.mu so it can't
 	// stall every other client's session operations for however long that takes.
 	// s is already reserved in the map and locked (see reserveSessionLocked), so a
 	// concurrent evict pass will correctly skip it via TryLock instead of deleting a
 	// still-being-built session out from under this goroutine.
-	s.Game = grid.NewGrid(s.Dim, neighborhood)
+	s.Game = grid.NewGrid(grid.Spec{Rows: s.Rows, Cols: s.Cols, Lattice: s.Lattice, Neighborhood: neighborhood, Topology: s.Topology, States: s.States, Graph: s.Graph, Difficulty: s.Difficulty, Target: s.Target, LitOnly: s.LitOnly})
+	s.GameStarted = time.Now()
+	s.MoveLimit = s.Budget.Limit(s.Game.Rating().Par)
+	m.Save(s)
 	s.Unlock()
 
 	return s, true, wasExpired
 }
 
+// Lookup returns the existing session for id, if there is one. Unlike Claim, it never
+// creates one and doesn't count as activity: it's for a long-lived stream following a
+// session that a page request already claimed.
+func (m *Manager) Lookup(id string) (*Session, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.sessions[id]
+	return s, ok
+}
+
 // Count returns the number of currently live sessions.
 func (m *Manager) Count() int {
 	m.mu.Lock()
@@ -159,20 +363,32 @@ func (m *Manager) SessionMaxAge(sess *Session) time.Duration {
 // manager lock without any other goroutine observing a half-built session (they'd
 // block on sess.Lock() first, per this package's existing locking convention). The
 // caller must set s.Game and call s.Unlock() once construction finishes.
-func (m *Manager) reserveSessionLocked(id string, now time.Time) (s *Session, neighborhood []int) {
+func (m *Manager) reserveSessionLocked(id string, now time.Time) (s *Session, neighborhood []utils.Pattern) {
 	s = &Session{
 		ID:             id,
-		Dim:            m.defaultDim,
+		Rows:           m.defaultRows,
+		Cols:           m.defaultCols,
+		Lattice:        m.defaultLattice,
+		Topology:       m.defaultTopology,
+		States:         m.defaultStates,
+		Graph:          m.defaultGraph,
+		Difficulty:     m.defaultDifficulty,
+		Target:         m.defaultTarget,
+		LitOnly:        m.defaultLitOnly,
 		Cheat:          m.defaultCheat,
+		Budget:         m.defaultBudget,
+		TimeLimit:      m.defaultTimeLimit,
 		ToggleSequence: append([]bool(nil), m.defaultToggleSequence...),
 		CreatedAt:      now,
 		LastUpdatedAt:  now,
 	}
 	s.Lock()
 
+	m.generation++
+	s.generation = m.generation
 	m.sessions[id] = s
 
-	return s, append([]int(nil), m.defaultNeighborhood...)
+	return s, append([]utils.Pattern(nil), m.defaultNeighborhood...)
 }
 
 func (m *Manager) evictExpiredLocked(now time.Time) {
@@ -202,7 +418,9 @@ func (m *Manager) evictLocked(id string, sess *Session) {
 	}
 	delete(m.sessions, id)
 	m.expiredIDs[id] = time.Now()
+	m.deleteStoredLocked(id, sess.generation)
 	sess.Unlock()
+	m.notifyLocked()
 }
 
 // pruneExpiredIDsLocked drops expiredIDs entries old enough that "recently expired" no
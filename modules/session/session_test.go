@@ -16,10 +16,15 @@ const (
 
 func testConfig(maxSessions int) *utils.Config {
 	return &utils.Config{
-		Dim:                       3,
-		Cheat:                     false,
-		ToggleSequence:            []bool{true, false, true},
-		AvailableToggleSequence:   []int{0, 4, 8},
+		Rows:           3,
+		Cols:           4,
+		Cheat:          false,
+		ToggleSequence: []bool{true, false, true},
+		Patterns: []utils.Pattern{
+			{Name: "0", Offsets: [][2]int{{0, 0}}},
+			{Name: "4", Offsets: [][2]int{{1, 0}, {0, 1}, {-1, 0}, {0, -1}}},
+			{Name: "8", Offsets: [][2]int{{1, 1}, {-1, -1}, {1, -1}, {-1, 1}}},
+		},
 		MaxSessions:               maxSessions,
 		SessionTTLSeconds:         testTTLSeconds,
 		SessionIdleTimeoutSeconds: testIdleSeconds,
@@ -55,8 +60,11 @@ func TestClaimCreatesThenTouchesSameSession(t *testing.T) {
 	if !ok {
 		t.Fatal("Claim() failed on an empty manager with capacity available")
 	}
-	if sess.Dim != 3 {
-		t.Errorf("new session Dim = %d, want 3 (from config default)", sess.Dim)
+	if sess.Rows != 3 || sess.Cols != 4 {
+		t.Errorf("new session size = %dx%d, want 3x4 (from config default)", sess.Rows, sess.Cols)
+	}
+	if sess.Game.Rows != 3 || sess.Game.Cols != 4 {
+		t.Errorf("new session Game size = %dx%d, want 3x4 (from config default)", sess.Game.Rows, sess.Game.Cols)
 	}
 
 	// Rewind LastUpdatedAt to prove the second Claim() actually touches it.
@@ -78,6 +86,26 @@ func TestClaimCreatesThenTouchesSameSession(t *testing.T) {
 	}
 }
 
+func TestLookupNeitherCreatesNorTouches(t *testing.T) {
+	m := NewManager(testConfig(10))
+
+	if _, ok := m.Lookup("client-a"); ok || m.Count() != 0 {
+		t.Fatalf("Lookup() found or created a session that was never claimed (Count() = %d)", m.Count())
+	}
+
+	sess, _, _ := m.Claim("client-a")
+	sess.LastUpdatedAt = time.Now().Add(-time.Hour)
+	before := sess.LastUpdatedAt
+
+	found, ok := m.Lookup("client-a")
+	if !ok || found != sess {
+		t.Fatal("Lookup() did not return the claimed session")
+	}
+	if found.LastUpdatedAt != before {
+		t.Fatalf("Lookup() bumped LastUpdatedAt to %v", found.LastUpdatedAt)
+	}
+}
+
 func TestClaimNeverReportsExpiredForLiveSession(t *testing.T) {
 	m := NewManager(testConfig(10))
 
@@ -270,7 +298,7 @@ func TestClaimConcurrentNeverExceedsCapacity(t *testing.T) {
 			for j := 0; j < 20; j++ {
 				if sess, ok, _ := m.Claim(id); ok {
 					sess.Lock()
-					_ = sess.Dim
+					_ = sess.Rows
 					sess.Unlock()
 				}
 			}
@@ -285,3 +313,106 @@ func TestClaimConcurrentNeverExceedsCapacity(t *testing.T) {
 		t.Fatalf("Count() = %d, want <= %d (MaxSessions) after concurrent Claim() calls", got, maxSessions)
 	}
 }
+
+func TestRecordDailyWin(t *testing.T) {
+	s := &Session{Presses: 9}
+	s.RecordDailyWin()
+	if s.DailySolved != (DailyResult{}) {
+		t.Fatalf("RecordDailyWin() on a non-daily game recorded %+v", s.DailySolved)
+	}
+
+	s.Daily = "2026-03-14"
+	s.RecordDailyWin()
+	if want := (DailyResult{Date: "2026-03-14", Moves: 9}); s.DailySolved != want {
+		t.Fatalf("DailySolved = %+v, want %+v", s.DailySolved, want)
+	}
+
+	// A replay of the same day only counts if it took fewer presses.
+	s.Presses = 12
+	s.RecordDailyWin()
+	s.Presses = 7
+	s.RecordDailyWin()
+	if want := (DailyResult{Date: "2026-03-14", Moves: 7}); s.DailySolved != want {
+		t.Fatalf("DailySolved = %+v, want the best replay %+v", s.DailySolved, want)
+	}
+
+	// A later day's replaces it, however many presses it took.
+	s.Daily, s.Presses = "2026-03-15", 20
+	s.RecordDailyWin()
+	if want := (DailyResult{Date: "2026-03-15", Moves: 20}); s.DailySolved != want {
+		t.Fatalf("DailySolved = %+v, want the new day's %+v", s.DailySolved, want)
+	}
+}
+
+func TestMovesLeft(t *testing.T) {
+	s := &Session{Presses: 4}
+	if _, limited := s.MovesLeft(); limited {
+		t.Fatal("MovesLeft() reported a limit on a game without one")
+	}
+
+	s.MoveLimit = 6
+	if left, limited := s.MovesLeft(); !limited || left != 2 {
+		t.Fatalf("MovesLeft() = %d, %v, want 2, true", left, limited)
+	}
+
+	s.Presses = 9
+	if left, _ := s.MovesLeft(); left != 0 {
+		t.Fatalf("MovesLeft() past the limit = %d, want 0", left)
+	}
+}
+
+func TestGameTimes(t *testing.T) {
+	start := time.Date(2026, 3, 14, 12, 0, 0, 0, time.UTC)
+	s := &Session{GameStarted: start}
+	if _, limited := s.TimeLeft(start); limited {
+		t.Fatal("TimeLeft() reported a limit on a game without one")
+	}
+
+	s.RecordPressTime(start.Add(2*time.Second), false)
+	s.RecordPressTime(start.Add(5*time.Second), true)
+	s.RecordPressTime(start.Add(9*time.Second), true)
+	if s.FirstMoveAt != start.Add(2*time.Second) || s.WonAt != start.Add(5*time.Second) {
+		t.Fatalf("FirstMoveAt, WonAt = %v, %v, want the first press and the first win", s.FirstMoveAt, s.WonAt)
+	}
+	if elapsed := s.Elapsed(start.Add(time.Minute)); elapsed != 5*time.Second {
+		t.Fatalf("Elapsed() after the win = %v, want it stopped at 5s", elapsed)
+	}
+
+	s.TimeLimit = 30 * time.Second
+	if left, limited := s.TimeLeft(start.Add(time.Hour)); !limited || left != 25*time.Second || s.TimedOut(start.Add(time.Hour)) {
+		t.Fatalf("TimeLeft() on a won game = %v, %v, want the clock stopped at 25s and not timed out", left, limited)
+	}
+
+	s.WonAt = time.Time{}
+	if left, _ := s.TimeLeft(start.Add(10 * time.Second)); left != 20*time.Second {
+		t.Fatalf("TimeLeft() 10s in = %v, want 20s", left)
+	}
+	if s.TimedOut(start.Add(29 * time.Second)) {
+		t.Fatal("TimedOut() before the limit")
+	}
+	if left, _ := s.TimeLeft(start.Add(time.Minute)); left != 0 || !s.TimedOut(start.Add(time.Minute)) {
+		t.Fatalf("TimeLeft() past the limit = %v, want 0 and timed out", left)
+	}
+}
+
+func TestRecordClick(t *testing.T) {
+	s := &Session{}
+	before := time.Now()
+
+	s.RecordClick(3, false)
+	s.RecordClick(3, true)
+
+	if len(s.Clicks) != 2 || s.Clicks[0] != (Click{Pos: 3, At: s.Clicks[0].At}) || !s.Clicks[1].Undo {
+		t.Fatalf("Clicks = %+v, want a press then an undo of 3", s.Clicks)
+	}
+	if s.Clicks[0].At.Before(before) || s.Clicks[1].At.Before(s.Clicks[0].At) {
+		t.Fatalf("Clicks timestamps = %v, %v, want in order and not before %v", s.Clicks[0].At, s.Clicks[1].At, before)
+	}
+}
+
+func TestDailyDateIsUTC(t *testing.T) {
+	tokyo := time.Date(2026, 3, 15, 8, 0, 0, 0, time.FixedZone("JST", 9*60*60))
+	if got := DailyDate(tokyo); got != "2026-03-14" {
+		t.Fatalf("DailyDate(%v) = %q, want the UTC date 2026-03-14", tokyo, got)
+	}
+}
@@ -0,0 +1,337 @@
+package session
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sort"
+	"time"
+
+	grid "goSwitch/modules/grid"
+	utils "goSwitch/modules/utils"
+)
+
+// Store persists sessions across server restarts, for a Manager to restore on startup
+// (see Manager.Persist). Save replaces whatever was kept for snap.ID. Delete drops it,
+// and from then on ignores any Save for id whose snap.Generation is generation or
+// older -- one of the deleted session, written after its deletion -- while one from a
+// newer session with the same id is kept as usual. Delete of an id with nothing kept is
+// not an error, and holds off those saves all the same. Implementations must be safe
+// for concurrent use: sessions are saved from their own request handlers, each under
+// only its own lock.
+type Store interface {
+	Load() ([]Snapshot, error)
+	Save(snap Snapshot) error
+	Delete(id string, generation uint64) error
+	Close() error
+}
+
+// Snapshot is a Session as a Store keeps it: every setting, and its current game
+// twice over -- exactly, as Game, the grid's binary encoding; and as the board it was
+// dealt from (its seed, with the settings as its Spec) plus the clicks played on it
+// since, which restoring replays should Game be missing or no longer fit. The
+// neighborhood's patterns and the graph are kept by name and looked up again in the
+// config they're restored under, which may since have dropped or changed them.
+type Snapshot struct {
+	ID            string
+	Generation    uint64
+	CreatedAt     time.Time
+	LastUpdatedAt time.Time
+
+	Rows         int
+	Cols         int
+	Lattice      grid.Lattice
+	Topology     grid.Topology
+	States       int
+	Graph        string
+	Difficulty   grid.Difficulty
+	Target       grid.Target
+	LitOnly      bool
+	Cheat        bool
+	Budget       string
+	TimeLimit    time.Duration
+	Neighborhood []string
+
+	Game        []byte
+	Seed        int64
+	GameStarted time.Time
+	FirstMoveAt time.Time
+	WonAt       time.Time
+	Clicks      []Click
+	HintsUsed   int
+	Presses     int
+	MoveLimit   int
+	Daily       string
+	DailySolved DailyResult
+}
+
+// snapshot captures s for a Store. LastUpdatedAt is taken as now rather than read: it
+// belongs to m.mu (see Session), and a session is only ever saved by a request that
+// has just touched it anyway. The caller must hold s's lock.
+func snapshot(s *Session, now time.Time) Snapshot {
+	snap := Snapshot{
+		ID:            s.ID,
+		Generation:    s.generation,
+		CreatedAt:     s.CreatedAt,
+		LastUpdatedAt: now,
+		Rows:          s.Rows,
+		Cols:          s.Cols,
+		Lattice:       s.Lattice,
+		Topology:      s.Topology,
+		States:        s.States,
+		Difficulty:    s.Difficulty,
+		Target:        s.Target,
+		LitOnly:       s.LitOnly,
+		Cheat:         s.Cheat,
+		Budget:        s.Budget.String(),
+		TimeLimit:     s.TimeLimit,
+		Seed:          s.Game.Seed(),
+		GameStarted:   s.GameStarted,
+		FirstMoveAt:   s.FirstMoveAt,
+		WonAt:         s.WonAt,
+		Clicks:        append([]Click(nil), s.Clicks...),
+		HintsUsed:     s.HintsUsed,
+		Presses:       s.Presses,
+		MoveLimit:     s.MoveLimit,
+		Daily:         s.Daily,
+		DailySolved:   s.DailySolved,
+	}
+	if s.Graph != nil {
+		snap.Graph = s.Graph.Name
+	}
+	if game, err := s.Game.MarshalBinary(); err == nil {
+		snap.Game = game
+	} else {
+		slog.Error(fmt.Sprintf("Encoding a session's game failed, so it'll be restored by replay: %v", err), utils.FuncAttrKey, utils.Caller())
+	}
+	for _, pattern := range s.Game.Spec().Neighborhood {
+		snap.Neighborhood = append(snap.Neighborhood, pattern.Name)
+	}
+	return snap
+}
+
+// restore rebuilds the Session snap was taken of. Its game is decoded from snap.Game
+// if that's there and still the game m's config would deal for snap's settings; if
+// not, it's dealt again from the same spec and seed and every click replayed on it --
+// which restores all but a redo's remaining undone moves, as a redo replays as a
+// fresh press that discards them. It fails if snap no longer fits m's config, or isn't
+// a game that could have been played.
+func (m *Manager) restore(snap Snapshot) (*Session, error) {
+	spec := grid.Spec{Rows: snap.Rows, Cols: snap.Cols, LitOnly: snap.LitOnly}
+	var err error
+	if spec.Lattice, err = grid.ParseLattice(string(snap.Lattice)); err != nil {
+		return nil, err
+	}
+	if spec.Topology, err = grid.ParseTopology(string(snap.Topology)); err != nil {
+		return nil, err
+	}
+	if spec.Difficulty, err = grid.ParseDifficulty(string(snap.Difficulty)); err != nil {
+		return nil, err
+	}
+	if spec.Target, err = grid.ParseTarget(string(snap.Target)); err != nil {
+		return nil, err
+	}
+	if snap.States != 0 && !slices.Contains(grid.SupportedStates, snap.States) {
+		return nil, fmt.Errorf("%d states isn't supported", snap.States)
+	}
+	spec.States = snap.States
+	if snap.Graph != "" {
+		idx := slices.IndexFunc(m.graphs, func(g grid.Graph) bool { return g.Name == snap.Graph })
+		if idx < 0 {
+			return nil, fmt.Errorf("graph %q is no longer configured", snap.Graph)
+		}
+		spec.Graph = &m.graphs[idx]
+	} else if snap.Rows < 1 || snap.Cols < 1 {
+		return nil, fmt.Errorf("a %dx%d board has no cells", snap.Rows, snap.Cols)
+	}
+	for _, name := range snap.Neighborhood {
+		idx := slices.IndexFunc(m.patterns, func(p utils.Pattern) bool { return p.Name == name })
+		if idx < 0 {
+			return nil, fmt.Errorf("pattern %q is no longer configured", name)
+		}
+		spec.Neighborhood = append(spec.Neighborhood, m.patterns[idx])
+	}
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+	budget, err := utils.ParseMoveBudget(snap.Budget)
+	if err != nil {
+		return nil, err
+	}
+
+	game, err := restoreGame(spec, snap)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Session{
+		ID:             snap.ID,
+		Rows:           snap.Rows,
+		Cols:           snap.Cols,
+		Lattice:        spec.Lattice,
+		Topology:       spec.Topology,
+		States:         spec.States,
+		Graph:          spec.Graph,
+		Difficulty:     spec.Difficulty,
+		Target:         spec.Target,
+		LitOnly:        spec.LitOnly,
+		Cheat:          snap.Cheat,
+		Budget:         budget,
+		TimeLimit:      snap.TimeLimit,
+		ToggleSequence: utils.BuildToggleSequenceFromRequest(spec.Neighborhood, m.patterns),
+		Game:           game,
+		GameStarted:    snap.GameStarted,
+		FirstMoveAt:    snap.FirstMoveAt,
+		WonAt:          snap.WonAt,
+		Clicks:         snap.Clicks,
+		HintsUsed:      snap.HintsUsed,
+		Presses:        snap.Presses,
+		MoveLimit:      snap.MoveLimit,
+		Daily:          snap.Daily,
+		DailySolved:    snap.DailySolved,
+		CreatedAt:      snap.CreatedAt,
+		LastUpdatedAt:  snap.LastUpdatedAt,
+		generation:     snap.Generation,
+	}, nil
+}
+
+// restoreGame returns snap's game for spec: decoded from snap.Game when that's the
+// game spec and snap.Seed would deal, else replayed click by click.
+func restoreGame(spec grid.Spec, snap Snapshot) (*grid.Grid, error) {
+	if len(snap.Game) > 0 {
+		game := &grid.Grid{}
+		err := game.UnmarshalBinary(snap.Game)
+		if err == nil && game.Seed() == snap.Seed && sameSpec(game.Spec(), spec) {
+			return game, nil
+		}
+		if err == nil {
+			err = errors.New("its settings no longer deal it")
+		}
+		slog.Info(fmt.Sprintf("Replaying a stored session's game rather than decoding it: %v", err), utils.FuncAttrKey, utils.Caller())
+	}
+
+	game := grid.NewGridFromSeed(spec, snap.Seed)
+	for i, click := range snap.Clicks {
+		if click.Undo {
+			if pos, ok := game.PopLastMove(); !ok || pos != click.Pos {
+				return nil, fmt.Errorf("click %d undoes %d, which isn't the last move", i, click.Pos)
+			}
+			game.Unswitch(click.Pos)
+			continue
+		}
+		if !game.Switch(click.Pos) {
+			return nil, fmt.Errorf("click %d presses %d, which can't be pressed", i, click.Pos)
+		}
+		game.RecordMove(click.Pos)
+	}
+	return game, nil
+}
+
+// sameSpec reports whether a and b deal the same games: equal once an empty lattice or
+// topology is read as its default, and with their graphs compared by value.
+func sameSpec(a, b grid.Spec) bool {
+	for _, spec := range []*grid.Spec{&a, &b} {
+		if lattice, err := grid.ParseLattice(string(spec.Lattice)); err == nil {
+			spec.Lattice = lattice
+		}
+		if topology, err := grid.ParseTopology(string(spec.Topology)); err == nil {
+			spec.Topology = topology
+		}
+	}
+	if (a.Graph == nil) != (b.Graph == nil) || a.Graph != nil && fmt.Sprint(*a.Graph) != fmt.Sprint(*b.Graph) {
+		return false
+	}
+	a.Graph, b.Graph = nil, nil
+	return fmt.Sprint(a) == fmt.Sprint(b)
+}
+
+// Persist restores the sessions store holds, then has m keep store up to date from
+// here on: every Save, and every eviction. A session already past SessionTTLSeconds
+// isn't restored but remembered as expired, so its client is told so just as if the
+// server had never restarted; so is one that no longer fits the config, or that would
+// put m over MaxSessions -- the most recently active ones are restored first. It's
+// meant to be called once, before m hands out any session.
+func (m *Manager) Persist(store Store) (restored int, err error) {
+	snaps, err := store.Load()
+	if err != nil {
+		return 0, err
+	}
+	sort.Slice(snaps, func(i, j int) bool { return snaps[i].LastUpdatedAt.After(snaps[j].LastUpdatedAt) })
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.store = store
+	now := time.Now()
+	for _, snap := range snaps {
+		m.generation = max(m.generation, snap.Generation)
+
+		var sess *Session
+		switch {
+		case now.Sub(snap.CreatedAt) >= m.ttl:
+			err = errors.New("past its TTL")
+		case len(m.sessions) >= m.maxSessions:
+			err = errors.New("over MaxSessions")
+		default:
+			sess, err = m.restore(snap)
+		}
+		if err != nil {
+			slog.Info(fmt.Sprintf("Dropping a stored session: %v", err), utils.FuncAttrKey, utils.Caller())
+			m.expiredIDs[snap.ID] = now
+			m.deleteStoredLocked(snap.ID, snap.Generation)
+			continue
+		}
+		m.sessions[snap.ID] = sess
+		restored++
+	}
+
+	return restored, nil
+}
+
+// Save writes sess to m's Store, if it has one (see Persist), and sess is still live.
+// A failure is logged rather than returned: the game carries on in memory regardless,
+// and is saved again on its next move. The caller must hold sess's lock.
+//
+// m.mu is only held to look the store and sess up, not for the snapshot or the write:
+// those are file I/O, sometimes a whole compaction, and every Claim, the waiting room
+// and the janitor would stall behind them. A session evicted since the caller claimed
+// it, before it took sess's lock, is skipped -- writing it would undo the eviction's
+// delete, and bring it back on restart -- and should an eviction still come between
+// this check and the write, the store ignores the save by its generation (see Store).
+func (m *Manager) Save(sess *Session) {
+	m.mu.Lock()
+	store, live := m.store, m.sessions[sess.ID] == sess
+	m.mu.Unlock()
+
+	if store == nil || !live {
+		return
+	}
+	if err := store.Save(snapshot(sess, time.Now())); err != nil {
+		slog.Error(fmt.Sprintf("Saving a session failed: %v", err), utils.FuncAttrKey, utils.Caller())
+	}
+}
+
+// Close closes m's Store, if it has one. Sessions aren't saved after it.
+func (m *Manager) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.store == nil {
+		return nil
+	}
+	err := m.store.Close()
+	m.store = nil
+	return err
+}
+
+// deleteStoredLocked drops id's session of generation from m's Store, if it has one,
+// logging a failure the way Save does.
+func (m *Manager) deleteStoredLocked(id string, generation uint64) {
+	if m.store == nil {
+		return
+	}
+	if err := m.store.Delete(id, generation); err != nil {
+		slog.Error(fmt.Sprintf("Deleting a stored session failed: %v", err), utils.FuncAttrKey, utils.Caller())
+	}
+}
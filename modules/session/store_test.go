@@ -0,0 +1,219 @@
+package session
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// persistedManager returns a Manager restored from, and saving to, the journal at path.
+func persistedManager(t *testing.T, path string) *Manager {
+	t.Helper()
+
+	m := NewManager(testConfig(10))
+	if _, err := m.Persist(openTestFileStore(t, path)); err != nil {
+		t.Fatalf("Persist() failed: %v", err)
+	}
+	return m
+}
+
+func TestPersistRestoresGamesMoveForMove(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal")
+	m := persistedManager(t, path)
+
+	sess, _, _ := m.Claim("client-a")
+	sess.Lock()
+	for _, pos := range []int{5, 2, 5} {
+		sess.Game.Switch(pos)
+		sess.Game.RecordMove(pos)
+		sess.RecordClick(pos, false)
+		sess.Presses++
+	}
+	pos, _ := sess.Game.PopLastMove()
+	sess.Game.Unswitch(pos)
+	sess.RecordClick(pos, true)
+	sess.HintsUsed = 2
+	m.Save(sess)
+	board, moves := fmt.Sprint(sess.Game.GetGrid()), fmt.Sprint(sess.Game.GetPreviousMoves())
+	sess.Unlock()
+	if err := m.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+
+	restarted := persistedManager(t, path)
+	if got := restarted.Count(); got != 1 {
+		t.Fatalf("Count() after restoring = %d, want 1", got)
+	}
+	again, ok, expired := restarted.Claim("client-a")
+	if !ok || expired {
+		t.Fatalf("Claim() of a restored session = ok %v, expired %v, want it live", ok, expired)
+	}
+	if got := fmt.Sprint(again.Game.GetGrid()); got != board {
+		t.Fatalf("restored board = %s, want %s", got, board)
+	}
+	if got := fmt.Sprint(again.Game.GetPreviousMoves()); got != moves || again.Game.RedoDepth() != 1 {
+		t.Fatalf("restored moves = %s (redo depth %d), want %s with one undone move to redo", got, again.Game.RedoDepth(), moves)
+	}
+	if again.Presses != 3 || again.HintsUsed != 2 || len(again.Clicks) != 4 || !again.CreatedAt.Equal(sess.CreatedAt) {
+		t.Fatalf("restored session = %+v, want the saved one's counters, clicks and CreatedAt", again)
+	}
+}
+
+func TestPersistDropsSessionsPastTTL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal")
+	m := persistedManager(t, path)
+
+	sess, _, _ := m.Claim("client-a")
+	sess.Lock()
+	sess.CreatedAt = time.Now().Add(-testTTLSeconds * time.Second)
+	m.Save(sess)
+	sess.Unlock()
+	if err := m.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+
+	restarted := persistedManager(t, path)
+	if got := restarted.Count(); got != 0 {
+		t.Fatalf("Count() after restoring a session past its TTL = %d, want 0", got)
+	}
+	if _, _, expired := restarted.Claim("client-a"); !expired {
+		t.Fatal("Claim() for a session dropped past its TTL should report it expired")
+	}
+}
+
+// TestSaveAfterEvictionIsDropped covers a session evicted between a handler's Claim and
+// its Save: the save mustn't write it back, or it returns from the dead on restart.
+func TestSaveAfterEvictionIsDropped(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal")
+	m := persistedManager(t, path)
+
+	sess, _, _ := m.Claim("client-a")
+	if !m.Evict("client-a") {
+		t.Fatal("Evict() found no session to evict")
+	}
+	sess.Lock()
+	sess.Presses++
+	m.Save(sess)
+	sess.Unlock()
+	if err := m.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+
+	restarted := persistedManager(t, path)
+	if got := restarted.Count(); got != 0 {
+		t.Fatalf("Count() after restoring = %d, want the evicted session to stay gone", got)
+	}
+}
+
+// TestSaveOfReplacementSessionIsKept covers the other side of the store ignoring saves
+// of deleted sessions: a new session that takes an evicted one's ID, restored or not,
+// is a newer generation, and saved as usual.
+func TestSaveOfReplacementSessionIsKept(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal")
+	m := persistedManager(t, path)
+	sess, _, _ := m.Claim("client-a")
+	sess.Lock()
+	m.Save(sess)
+	sess.Unlock()
+	if err := m.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+
+	restarted := persistedManager(t, path)
+	if !restarted.Evict("client-a") {
+		t.Fatal("Evict() found no restored session to evict")
+	}
+	replacement, ok, _ := restarted.Claim("client-a")
+	if !ok {
+		t.Fatal("Claim() after the eviction got no session")
+	}
+	replacement.Lock()
+	replacement.Presses = 7
+	restarted.Save(replacement)
+	replacement.Unlock()
+	if err := restarted.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+
+	again, ok, _ := persistedManager(t, path).Claim("client-a")
+	if !ok || again.Presses != 7 {
+		t.Fatalf("Claim() after restoring = %+v, %v, want the replacement session (7 presses)", again, ok)
+	}
+}
+
+func TestPersistDropsSessionsThatNoLongerFitConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal")
+	store := openTestFileStore(t, path)
+	now := time.Now()
+	for _, snap := range []Snapshot{
+		{ID: "gone-pattern", CreatedAt: now, Rows: 3, Cols: 3, Neighborhood: []string{"0", "moebius"}},
+		{ID: "bad-click", CreatedAt: now, Rows: 3, Cols: 3, Neighborhood: []string{"0", "4"}, Clicks: []Click{{Pos: 99}}},
+		{ID: "fine", CreatedAt: now, Rows: 3, Cols: 3, Neighborhood: []string{"0", "4"}, Clicks: []Click{{Pos: 4}}},
+	} {
+		if err := store.Save(snap); err != nil {
+			t.Fatalf("Save() failed: %v", err)
+		}
+	}
+
+	m := NewManager(testConfig(10))
+	restored, err := m.Persist(store)
+	if err != nil || restored != 1 {
+		t.Fatalf("Persist() = %d, %v, want just the one snapshot that still fits", restored, err)
+	}
+	if ids := loadIDs(t, store); len(ids) != 1 {
+		t.Fatalf("store holds %v after Persist, want the dropped snapshots deleted", ids)
+	}
+}
+
+func TestPersistRestoresRedoStack(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal")
+	m := persistedManager(t, path)
+
+	sess, _, _ := m.Claim("client-a")
+	sess.Lock()
+	for _, pos := range []int{1, 3, 5} {
+		sess.Game.Switch(pos)
+		sess.Game.RecordMove(pos)
+		sess.RecordClick(pos, false)
+	}
+	for range 2 {
+		pos, _ := sess.Game.PopLastMove()
+		sess.Game.Unswitch(pos)
+		sess.RecordClick(pos, true)
+	}
+	pos, _ := sess.Game.RedoMove()
+	sess.Game.Switch(pos)
+	sess.RecordClick(pos, false)
+	m.Save(sess)
+	sess.Unlock()
+	if err := m.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+
+	again, _, _ := persistedManager(t, path).Claim("client-a")
+	if got := again.Game.RedoDepth(); got != 1 {
+		t.Fatalf("restored redo depth = %d, want the one move still undone", got)
+	}
+	if pos, ok := again.Game.RedoMove(); !ok || pos != 5 {
+		t.Fatalf("restored RedoMove() = %d, %v, want 5", pos, ok)
+	}
+}
+
+func TestPersistReplaysGamesItCannotDecode(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal")
+	store := openTestFileStore(t, path)
+	snap := Snapshot{ID: "client-a", CreatedAt: time.Now(), Rows: 3, Cols: 3, Neighborhood: []string{"0", "4"}, Game: []byte("garbage"), Clicks: []Click{{Pos: 4}}}
+	if err := store.Save(snap); err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+
+	m := NewManager(testConfig(10))
+	if restored, err := m.Persist(store); err != nil || restored != 1 {
+		t.Fatalf("Persist() = %d, %v, want the snapshot restored by replay", restored, err)
+	}
+	sess, _, _ := m.Claim("client-a")
+	if got := fmt.Sprint(sess.Game.GetPreviousMoves()); got != "[4]" {
+		t.Fatalf("replayed moves = %s, want [4]", got)
+	}
+}
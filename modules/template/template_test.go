@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/labstack/echo/v4"
 )
@@ -58,19 +59,64 @@ func TestRealTemplatesRenderWithoutError(t *testing.T) {
 		"Waiting":      false,
 		"Expired":      false,
 		"Win":          false,
-		"Board":        [][]int{{0, 1}, {1, 0}},
+		"Budget":       map[string]interface{}{"Limit": 5, "Left": 0, "Failed": true},
+		"Timing":       map[string]interface{}{"Elapsed": "1:02.50", "FirstMove": "0:03.10", "Left": "0:57", "Ticking": true, "TimedOut": false},
+		"Board":        [][]int{{0, 1}, {2, 0}},
+		"Target":       [][]int{{1, 0}, {0, 1}},
 		"Solution":     []int{0, 1},
+		"Solvable":     true,
+		"SolveUnknown": false,
+		"Par":          2,
 		"Moves":        []int{0},
+		"Redoable":     1,
+		"Hint":         map[string]interface{}{"Active": true, "Row": 1, "Col": 0, "Undo": false},
+		"HintsUsed":    1,
+		"Rating":       map[string]interface{}{"Difficulty": "hard", "Score": 0.61},
+		"PuzzleCode":   "AEAAAAAAAAAAAAAAAMAQAAABAEAAJY",
+		"Daily":        map[string]interface{}{"Date": "2026-03-14", "Playing": true, "Solved": true, "Moves": 5},
+		"Replay": map[string]interface{}{
+			"Step": 1, "Total": 2, "Prev": 0, "Next": 2, "Playing": true,
+			"Last": "Pressed row 0, column 0", "HasLast": true, "LastRow": 0, "LastCol": 0,
+			"Elapsed": "3s", "DelayMS": 500,
+		},
 		"Config": map[string]interface{}{
-			"Dim":                     2,
-			"Cheat":                   true,
-			"ToggleSequence":          []bool{true, false, true},
-			"AvailableToggleSequence": []int{0, 4, 8},
+			"Rows":            2,
+			"Cols":            2,
+			"Lattice":         "hex",
+			"Lattices":        []string{"square", "hex"},
+			"Topology":        "torus",
+			"Topologies":      []string{"bounded", "torus"},
+			"States":          3,
+			"AvailableStates": []int{2, 3, 5, 7},
+			"Graph":           "",
+			"Graphs":          []string{"ring", "petersen"},
+			"Difficulty":      "hard",
+			"Difficulties":    []string{"", "easy", "medium", "hard"},
+			"Target":          "heart",
+			"Targets":         []string{"", "random", "heart"},
+			"LitOnly":         true,
+			"Cheat":           true,
+			"Budget":          "par+2",
+			"TimeLimit":       120,
+			"ToggleSequence":  []bool{true, false, true},
+			"AvailablePatterns": []map[string]interface{}{
+				{"Name": "0", "Offsets": [][2]int{{0, 0}}},
+				{"Name": "knight", "Offsets": [][2]int{{1, 2}, {2, 1}}},
+				{"Name": "8", "Offsets": [][2]int{{1, 1}, {-1, -1}}},
+			},
 		},
 		"Response": map[string]interface{}{"Status": "SUCCESS", "Error": ""},
+		"Analysis": map[string]interface{}{
+			"Rank":            3,
+			"Nullity":         1,
+			"QuietPatterns":   [][]int{{0, 3}},
+			"SolvablePercent": "50%",
+			"Degenerate":      false,
+			"Error":           "",
+		},
 	}
 
-	for _, name := range []string{"index", "game", "waiting", "status-header", "help", "configuration", "trivia", "response", "grid"} {
+	for _, name := range []string{"index", "game", "waiting", "status-header", "help", "configuration", "trivia", "response", "grid", "analysis", "replay"} {
 		t.Run(name, func(t *testing.T) {
 			var buf bytes.Buffer
 			if err := e.Renderer.Render(&buf, name, data, nil); err != nil {
@@ -81,6 +127,64 @@ func TestRealTemplatesRenderWithoutError(t *testing.T) {
 			}
 		})
 	}
+
+	// A lit-only board its search gave up on is neither solvable nor known not to be.
+	data["Solvable"], data["SolveUnknown"] = false, true
+	data["Hint"] = map[string]interface{}{"Undo": true}
+	var trivia bytes.Buffer
+	if err := e.Renderer.Render(&trivia, "trivia", data, nil); err != nil {
+		t.Fatalf("rendering the real \"trivia\" template for an unknown board failed: %v", err)
+	}
+	if out := trivia.String(); !strings.Contains(out, `id="trivia-par" value="unknown"`) || strings.Contains(out, "No winning combination") || !strings.Contains(out, `class="hint"`) {
+		t.Fatalf("rendering an unknown board's trivia should say par is unknown and ring Undo, got: %s", out)
+	}
+
+	// A graph board takes grid.html's other branch entirely, so it gets its own render.
+	data["Board"] = [][]int{{0, 1}}
+	data["Graph"] = map[string]interface{}{
+		"Nodes": []map[string]float64{{"X": 10, "Y": 50}, {"X": 90, "Y": 50}},
+		"Edges": []map[string]float64{{"X1": 10, "Y1": 50, "X2": 90, "Y2": 50}},
+	}
+	var buf bytes.Buffer
+	if err := e.Renderer.Render(&buf, "grid", data, nil); err != nil {
+		t.Fatalf("rendering the real \"grid\" template for a graph board failed: %v", err)
+	}
+	if !strings.Contains(buf.String(), `class="grid-graph"`) {
+		t.Fatalf("rendering a graph board did not use the graph layout, got: %s", buf.String())
+	}
+}
+
+func TestAdminTemplatesRender(t *testing.T) {
+	e := echo.New()
+	NewTemplateRenderer(e, filepath.Join("..", "..", "webui", "*.html"))
+
+	data := map[string]interface{}{
+		"Sessions": []map[string]interface{}{
+			{"Handle": "0123456789abcdef", "CreatedAt": time.Date(2026, 3, 14, 15, 9, 26, 0, time.UTC), "IdleSeconds": 42, "Rows": 5, "Cols": 5, "Graph": "", "Moves": 3, "Won": false},
+			{"Handle": "fedcba9876543210", "CreatedAt": time.Date(2026, 3, 14, 15, 10, 0, 0, time.UTC), "IdleSeconds": 0, "Rows": 1, "Cols": 10, "Graph": "petersen", "Moves": 0, "Won": true},
+		},
+		"Count":        2,
+		"MaxSessions":  10,
+		"Waiting":      1,
+		"WaitingConns": 1,
+		"Error":        "Can't set the capacity",
+	}
+
+	for name, want := range map[string]string{
+		"admin":       "2026-03-14 15:09:26",
+		"admin-page":  "petersen (10 nodes)",
+		"admin-login": `action="/admin/login"`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := e.Renderer.Render(&buf, name, data, nil); err != nil {
+				t.Fatalf("rendering the real %q template failed: %v", name, err)
+			}
+			if !strings.Contains(buf.String(), want) {
+				t.Fatalf("rendering the real %q template lacks %q, got: %s", name, want, buf.String())
+			}
+		})
+	}
 }
 
 func TestRenderSubstitutesData(t *testing.T) {
@@ -8,25 +8,109 @@ import (
 	"log/slog"
 	"net/url"
 	"os"
+	"path/filepath"
+	"regexp"
 	"slices"
 	"strconv"
+	"strings"
+	"time"
 
 	"encoding/json"
 
 	"github.com/labstack/echo/v4"
 )
 
-// supportedNeighborhoodPatterns must match the values grid.Grid.Switch understands
-// (0: self, 4: orthogonal, 8: diagonal). Duplicated here rather than imported from the
-// grid package to avoid a circular dependency (grid already imports utils for logging).
-var supportedNeighborhoodPatterns = []int{0, 4, 8}
+// Pattern is a named neighborhood pattern: the (dx, dy) offsets, relative to the
+// switched cell, that switching it also flips -- (0, 0) being the cell itself. Declared
+// in config.json rather than in code, so new puzzle families don't need a rebuild.
+// Lives here rather than in the grid package (which re-exports it as grid.Pattern) so
+// Config can carry it without a circular dependency (grid already imports utils).
+type Pattern struct {
+	Name    string   `json:"Name"`
+	Offsets [][2]int `json:"Offsets"`
+}
+
+// Graph is a board that isn't a lattice at all: Nodes cells, numbered from 0, where
+// switching one also flips every node it shares an edge with. Positions, if present,
+// holds one (x, y) per node for rendering, in any units -- only their relative layout
+// matters. Declared in the file config.json's GraphsFile names, and lives here for the
+// same reason Pattern does (grid re-exports it as grid.Graph).
+type Graph struct {
+	Name      string       `json:"Name"`
+	Nodes     int          `json:"Nodes"`
+	Positions [][2]float64 `json:"Positions,omitempty"`
+	Edges     [][2]int     `json:"Edges"`
+}
+
+// maxGraphNodes caps a graph board at the largest lattice board's cell count, so the
+// solver never sees a bigger system from a graph than it does from the size fields.
+const maxGraphNodes = maxBoardSide * maxBoardSide
+
+// minBoardSide and maxBoardSide bound each of a board's two sides, both in config.json
+// and in the in-game size fields. Rows and columns are bounded independently so wide
+// and tall variants (5x6, 4x7, ...) are allowed alongside square ones. The upper bound
+// is well past anything comfortable to play, for stress and solver experiments; boards
+// with more than two states per cell are capped further, by grid.Spec.Validate.
+const (
+	minBoardSide = 2
+	maxBoardSide = 50
+)
+
+// MaxBoardCells is the most cells any board can have, lattice or graph -- for whatever
+// takes a board in without going through the size fields, like a decoded grid.
+const MaxBoardCells = maxBoardSide * maxBoardSide
+
+// maxPatternReach bounds how far from the switched cell an offset may point. Anything
+// past the largest board's own width could never land in bounds, so it can only be a
+// typo.
+const maxPatternReach = maxBoardSide - 1
+
+// patternNameRe keeps pattern names safe to embed in form values and HTML ids, which
+// configuration.html does with each one.
+var patternNameRe = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
 
 type Config struct {
-	Port                    string `json:"Port"`
-	Cheat                   bool   `json:"Cheat"`
-	Dim                     int    `json:"Dim"`
-	ToggleSequence          []bool `json:"ToggleSequence"`
-	AvailableToggleSequence []int  `json:"AvailableToggleSequence"`
+	Port  string `json:"Port"`
+	Cheat bool   `json:"Cheat"`
+	// Rows and Cols are the default board's height and width.
+	Rows int `json:"Rows"`
+	Cols int `json:"Cols"`
+	// Topology is the default board's edge behavior ("bounded" or "torus"; empty means
+	// bounded). Validated by grid.CheckConfig, which owns the list of topologies.
+	Topology string `json:"Topology"`
+	// Lattice is the default board's cell shape ("square" or "hex"; empty means
+	// square). Validated by grid.CheckConfig, like Topology.
+	Lattice string `json:"Lattice"`
+	// States is the default number of states per cell (0 or 2 for the classic game).
+	// Validated by grid.CheckConfig, which owns the list of supported values.
+	States int `json:"States"`
+	// ToggleSequence is the default pattern selection, parallel to Patterns.
+	ToggleSequence []bool `json:"ToggleSequence"`
+	// Patterns is every neighborhood pattern players can pick from.
+	Patterns []Pattern `json:"Patterns"`
+	// GraphsFile names a JSON file holding a list of Graph boards players can pick
+	// instead of a lattice, relative to config.json's own directory. Empty means none.
+	GraphsFile string `json:"GraphsFile"`
+	// Graph names the default board's Graph, or is empty for a lattice board.
+	Graph string `json:"Graph"`
+	// Graphs is GraphsFile's contents, loaded by ParseJSONConfig.
+	Graphs []Graph `json:"-"`
+	// Difficulty is the default difficulty band boards are dealt in ("easy", "medium"
+	// or "hard"; empty means any). Validated by grid.CheckConfig, like Topology.
+	Difficulty string `json:"Difficulty"`
+	// Target is what the default board has to look like to be won: empty for the
+	// classic uniform board, "random", or one of grid.Pictures by name. Validated by
+	// grid.CheckConfig, like Topology.
+	Target string `json:"Target"`
+	// LitOnly has the default board only let lit cells be pressed. It needs the
+	// classic two States; grid.CheckConfig checks that, and the board's size.
+	LitOnly bool `json:"LitOnly"`
+	// MoveBudget is every new game's default move limit: empty for none, a number of
+	// presses, "par", or "par+N" (see ParseMoveBudget).
+	MoveBudget string `json:"MoveBudget"`
+	// TimeLimitSeconds is every new game's default countdown, after which it's lost:
+	// 0 for none, up to maxTimeLimitSeconds.
+	TimeLimitSeconds int `json:"TimeLimitSeconds"`
 
 	// MaxSessions caps the number of concurrent per-client sessions.
 	MaxSessions int `json:"MaxSessions"`
@@ -35,13 +119,14 @@ type Config struct {
 	// SessionIdleTimeoutSeconds is the max allowed inactivity for a session, enforced
 	// only when MaxSessions has been reached and a new session needs a slot.
 	SessionIdleTimeoutSeconds int `json:"SessionIdleTimeoutSeconds"`
-	// SessionWaitCheckIntervalSeconds is how often a waiting client is re-checked for
-	// a freed-up slot while it holds open its SSE connection.
-	SessionWaitCheckIntervalSeconds int `json:"SessionWaitCheckIntervalSeconds"`
 	// MaxWaitingConnections caps how many clients can hold an open /wait SSE
 	// connection at once, independent of MaxSessions -- without this, a client with no
-	// real session could still hold an unbounded number of idle connections open.
+	// real session could still hold an unbounded number of idle connections open. It
+	// caps the waiting queue's length the same way (see session.Manager).
 	MaxWaitingConnections int `json:"MaxWaitingConnections"`
+	// SessionStorePath is the journal file sessions are saved to, so they survive a
+	// restart (see session.FileStore); empty keeps them in memory only.
+	SessionStorePath string `json:"SessionStorePath"`
 
 	// LogFilePath is where rotated log files are written.
 	LogFilePath string `json:"LogFilePath"`
@@ -66,6 +151,12 @@ type Config struct {
 	// them. Overridable per-deployment via the GOSWITCH_TRUST_PROXY_HEADERS environment
 	// variable without editing this committed file.
 	TrustProxyHeaders bool `json:"TrustProxyHeaders"`
+
+	// AdminToken is the secret the /admin area is unlocked with; empty (the default)
+	// leaves it disabled altogether. At least minAdminTokenLength characters, so it
+	// can't be guessed through the rate limiter. Better set via the GOSWITCH_ADMIN_TOKEN
+	// environment variable than committed to this file.
+	AdminToken string `json:"AdminToken"`
 }
 
 // trustProxyHeadersEnvVar lets a deployment (e.g. Render, which sits behind exactly the
@@ -73,7 +164,17 @@ type Config struct {
 // value without needing a separate config file per environment.
 const trustProxyHeadersEnvVar = "GOSWITCH_TRUST_PROXY_HEADERS"
 
-func ParseJSONConfig(path string) Config {
+// adminTokenEnvVar overrides AdminToken, keeping the secret out of config.json.
+const adminTokenEnvVar = "GOSWITCH_ADMIN_TOKEN"
+
+// minAdminTokenLength is the shortest AdminToken accepted.
+const minAdminTokenLength = 16
+
+// ConfigCheck is an extra validation rule ParseJSONConfig runs after its own, for
+// rules that need a package utils can't import (e.g. grid, which imports utils).
+type ConfigCheck func(*Config) error
+
+func ParseJSONConfig(path string, checks ...ConfigCheck) Config {
 	jsonFile, err := os.Open(path) //nolint:gosec // path is a trusted, operator-supplied startup argument, not user input
 
 	if err != nil {
@@ -89,6 +190,17 @@ func ParseJSONConfig(path string) Config {
 		log.Fatal("Error when parsing JSON file: ", err.Error())
 	}
 
+	if config.GraphsFile != "" {
+		graphsPath := config.GraphsFile
+		if !filepath.IsAbs(graphsPath) {
+			graphsPath = filepath.Join(filepath.Dir(path), graphsPath)
+		}
+		config.Graphs, err = LoadGraphs(graphsPath)
+		if err != nil {
+			log.Fatal("Error when loading graphs: ", err.Error())
+		}
+	}
+
 	if raw, set := os.LookupEnv(trustProxyHeadersEnvVar); set {
 		trust, parseErr := strconv.ParseBool(raw)
 		if parseErr != nil {
@@ -97,14 +209,18 @@ func ParseJSONConfig(path string) Config {
 		config.TrustProxyHeaders = trust
 	}
 
-	if err := validateConfig(&config); err != nil {
+	if token, set := os.LookupEnv(adminTokenEnvVar); set {
+		config.AdminToken = token
+	}
+
+	if err := validateConfig(&config, checks...); err != nil {
 		log.Fatal("Error when validating config: ", err.Error())
 	}
 
 	return config
 }
 
-func validateConfig(config *Config) error {
+func validateConfig(config *Config, checks ...ConfigCheck) error {
 	if config.Port == "" {
 		return fmt.Errorf("'Port' must not be empty")
 	}
@@ -114,25 +230,50 @@ func validateConfig(config *Config) error {
 		return fmt.Errorf("'Port' must be a number in [0, 65535], got %q", config.Port)
 	}
 
-	if config.Dim < 2 || config.Dim > 5 {
-		return fmt.Errorf("'Dim' must be in [2, 5], got %d", config.Dim)
+	for _, side := range []struct {
+		name string
+		val  int
+	}{
+		{"Rows", config.Rows},
+		{"Cols", config.Cols},
+	} {
+		if side.val < minBoardSide || side.val > maxBoardSide {
+			return fmt.Errorf("'%s' must be in [%d, %d], got %d", side.name, minBoardSide, maxBoardSide, side.val)
+		}
+	}
+
+	if len(config.Patterns) == 0 {
+		return fmt.Errorf("'Patterns' must not be empty")
 	}
 
-	if len(config.ToggleSequence) != len(config.AvailableToggleSequence) {
-		return fmt.Errorf("'ToggleSequence' (len %d) must match 'AvailableToggleSequence' (len %d)",
-			len(config.ToggleSequence), len(config.AvailableToggleSequence))
+	if len(config.ToggleSequence) != len(config.Patterns) {
+		return fmt.Errorf("'ToggleSequence' (len %d) must match 'Patterns' (len %d)",
+			len(config.ToggleSequence), len(config.Patterns))
 	}
 
-	seenPatterns := make(map[int]bool, len(config.AvailableToggleSequence))
-	for _, val := range config.AvailableToggleSequence {
-		if !slices.Contains(supportedNeighborhoodPatterns, val) {
-			return fmt.Errorf("'AvailableToggleSequence' value %d is not a supported pattern (must be one of %v)",
-				val, supportedNeighborhoodPatterns)
+	seenPatterns := make(map[string]bool, len(config.Patterns))
+	for _, pattern := range config.Patterns {
+		if err := ValidatePattern(pattern); err != nil {
+			return err
 		}
-		if seenPatterns[val] {
-			return fmt.Errorf("'AvailableToggleSequence' value %d is duplicated", val)
+		if seenPatterns[pattern.Name] {
+			return fmt.Errorf("'Patterns' name %q is duplicated", pattern.Name)
 		}
-		seenPatterns[val] = true
+		seenPatterns[pattern.Name] = true
+	}
+
+	seenGraphs := make(map[string]bool, len(config.Graphs))
+	for _, graph := range config.Graphs {
+		if err := ValidateGraph(graph); err != nil {
+			return err
+		}
+		if seenGraphs[graph.Name] {
+			return fmt.Errorf("'Graphs' name %q is duplicated", graph.Name)
+		}
+		seenGraphs[graph.Name] = true
+	}
+	if config.Graph != "" && !seenGraphs[config.Graph] {
+		return fmt.Errorf("'Graph' %q is not one of the graphs in 'GraphsFile' %q", config.Graph, config.GraphsFile)
 	}
 
 	if config.SessionIdleTimeoutSeconds < 1 || config.SessionIdleTimeoutSeconds > config.SessionTTLSeconds {
@@ -148,6 +289,18 @@ func validateConfig(config *Config) error {
 		return err
 	}
 
+	if _, err := ParseMoveBudget(config.MoveBudget); err != nil {
+		return fmt.Errorf("'MoveBudget': %w", err)
+	}
+
+	if config.TimeLimitSeconds < 0 || config.TimeLimitSeconds > maxTimeLimitSeconds {
+		return fmt.Errorf("'TimeLimitSeconds' must be in [0, %d], got %d", maxTimeLimitSeconds, config.TimeLimitSeconds)
+	}
+
+	if config.AdminToken != "" && len(config.AdminToken) < minAdminTokenLength {
+		return fmt.Errorf("'AdminToken' must be empty or at least %d characters, got %d", minAdminTokenLength, len(config.AdminToken))
+	}
+
 	if config.RateLimitRequestsPerSecond <= 0 {
 		return fmt.Errorf("'RateLimitRequestsPerSecond' must be > 0, got %v", config.RateLimitRequestsPerSecond)
 	}
@@ -160,7 +313,6 @@ func validateConfig(config *Config) error {
 	}{
 		{"MaxSessions", config.MaxSessions},
 		{"SessionTTLSeconds", config.SessionTTLSeconds},
-		{"SessionWaitCheckIntervalSeconds", config.SessionWaitCheckIntervalSeconds},
 		{"MaxWaitingConnections", config.MaxWaitingConnections},
 		{"LogMaxSizeMB", config.LogMaxSizeMB},
 		{"LogMaxBackups", config.LogMaxBackups},
@@ -171,9 +323,103 @@ func validateConfig(config *Config) error {
 		}
 	}
 
+	// Run last, so a check can rely on every structural rule above already holding.
+	for _, check := range checks {
+		if err := check(config); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ValidatePattern checks a single Patterns entry: a safe name, and a non-empty set of
+// distinct offsets within maxPatternReach. A repeated offset would be flipped twice by
+// every switch, i.e. silently not at all, so it's rejected as a mistake rather than
+// accepted as a no-op. Exported for anything else carrying patterns in from outside,
+// like a decoded grid.
+func ValidatePattern(pattern Pattern) error {
+	if !patternNameRe.MatchString(pattern.Name) {
+		return fmt.Errorf("'Patterns' name %q must be 1-32 letters, digits, '_' or '-'", pattern.Name)
+	}
+	if len(pattern.Offsets) == 0 {
+		return fmt.Errorf("'Patterns' entry %q has no offsets", pattern.Name)
+	}
+
+	seen := make(map[[2]int]bool, len(pattern.Offsets))
+	for _, off := range pattern.Offsets {
+		if abs(off[0]) > maxPatternReach || abs(off[1]) > maxPatternReach {
+			return fmt.Errorf("'Patterns' entry %q offset %v is out of range (each component must be in [-%d, %d])",
+				pattern.Name, off, maxPatternReach, maxPatternReach)
+		}
+		if seen[off] {
+			return fmt.Errorf("'Patterns' entry %q offset %v is duplicated", pattern.Name, off)
+		}
+		seen[off] = true
+	}
+
+	return nil
+}
+
+// LoadGraphs reads a list of Graph boards from the JSON file at path. It only decodes
+// them; validateConfig checks they make sense.
+func LoadGraphs(path string) ([]Graph, error) {
+	data, err := os.ReadFile(path) //nolint:gosec // path comes from the trusted, operator-supplied config
+	if err != nil {
+		return nil, err
+	}
+
+	var graphs []Graph
+	if err := json.Unmarshal(data, &graphs); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return graphs, nil
+}
+
+// ValidateGraph checks a single Graphs entry: a safe name (it's embedded in form values
+// just like a pattern's), between 2 and maxGraphNodes nodes, a position per node if
+// any, and edges between distinct, existing nodes, each listed once. As with a
+// pattern's offsets, a repeated edge would cancel itself out, so it's a mistake.
+// Exported for the same reason ValidatePattern is.
+func ValidateGraph(graph Graph) error {
+	if !patternNameRe.MatchString(graph.Name) {
+		return fmt.Errorf("'Graphs' name %q must be 1-32 letters, digits, '_' or '-'", graph.Name)
+	}
+	if graph.Nodes < 2 || graph.Nodes > maxGraphNodes {
+		return fmt.Errorf("'Graphs' entry %q must have [2, %d] nodes, got %d", graph.Name, maxGraphNodes, graph.Nodes)
+	}
+	if len(graph.Positions) != 0 && len(graph.Positions) != graph.Nodes {
+		return fmt.Errorf("'Graphs' entry %q has %d positions for %d nodes", graph.Name, len(graph.Positions), graph.Nodes)
+	}
+
+	seen := make(map[[2]int]bool, len(graph.Edges))
+	for _, edge := range graph.Edges {
+		a, b := edge[0], edge[1]
+		if a < 0 || a >= graph.Nodes || b < 0 || b >= graph.Nodes {
+			return fmt.Errorf("'Graphs' entry %q edge %v names a node outside [0, %d)", graph.Name, edge, graph.Nodes)
+		}
+		if a == b {
+			return fmt.Errorf("'Graphs' entry %q edge %v is a self-loop", graph.Name, edge)
+		}
+		if a > b {
+			a, b = b, a
+		}
+		if seen[[2]int{a, b}] {
+			return fmt.Errorf("'Graphs' entry %q edge %v is duplicated", graph.Name, edge)
+		}
+		seen[[2]int{a, b}] = true
+	}
+
 	return nil
 }
 
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
 // atLeastOne returns an error if val is below 1, naming the offending field.
 func atLeastOne(name string, val int) error {
 	if val < 1 {
@@ -183,33 +429,33 @@ func atLeastOne(name string, val int) error {
 }
 
 // BuildNeighborhoodFromConfig assumes len(config.ToggleSequence) >=
-// len(config.AvailableToggleSequence), which validateConfig guarantees for any Config
-// that's passed validation. Callers constructing a Config by hand (bypassing
+// len(config.Patterns), which validateConfig guarantees for any Config that's passed
+// validation. Callers constructing a Config by hand (bypassing
 // ParseJSONConfig/validateConfig) that violate this get a neighborhood built from
 // however many entries are actually available, rather than a panic.
-func BuildNeighborhoodFromConfig(config *Config) []int {
-	neighborhood := make([]int, 0, len(config.AvailableToggleSequence))
+func BuildNeighborhoodFromConfig(config *Config) []Pattern {
+	neighborhood := make([]Pattern, 0, len(config.Patterns))
 
-	for idx, val := range config.AvailableToggleSequence {
+	for idx, pattern := range config.Patterns {
 		if idx >= len(config.ToggleSequence) {
 			break
 		}
 		if config.ToggleSequence[idx] {
-			neighborhood = append(neighborhood, val)
+			neighborhood = append(neighborhood, pattern)
 		}
 	}
 
 	return neighborhood
 }
 
-func BuildToggleSequenceFromRequest(neighborhood []int, availableToggleSequence []int) []bool {
-	togglesequence := make([]bool, 0, len(availableToggleSequence))
+func BuildToggleSequenceFromRequest(neighborhood []Pattern, availablePatterns []Pattern) []bool {
+	togglesequence := make([]bool, 0, len(availablePatterns))
 
-	for _, val := range availableToggleSequence {
+	for _, pattern := range availablePatterns {
 		valFound := false
 
 		for _, neigh := range neighborhood {
-			valFound = val == neigh
+			valFound = pattern.Name == neigh.Name
 			if valFound {
 				break
 			}
@@ -248,6 +494,12 @@ func ProcessRequestQuery(c echo.Context) map[string]interface{} {
 	return valuesToJSONMap(c.QueryParams())
 }
 
+// ProcessValues reads form fields that didn't come from the request itself (e.g. a
+// decoded puzzle code's), into the same shape ProcessRequestForm returns.
+func ProcessValues(values url.Values) map[string]interface{} {
+	return valuesToJSONMap(values)
+}
+
 // formValues safely extracts all values of a multi-value form/query field (e.g.
 // repeated checkboxes) produced by ProcessRequestForm / ProcessRequestQuery, without
 // panicking if the key is missing or holds an unexpected type (both reachable by
@@ -290,82 +542,319 @@ func fail(resp map[string]interface{}, msg string) string {
 	return msg
 }
 
-func ParseDim(jsonMap map[string]interface{}, resp map[string]interface{}) (int, map[string]interface{}) {
-	raw, ok := firstFormValue(jsonMap, "dim")
+// ParseSize parses the request's 'rows' and 'cols' values, each independently bounded
+// to [minBoardSide, maxBoardSide].
+func ParseSize(jsonMap map[string]interface{}, resp map[string]interface{}) (int, int, map[string]interface{}) {
+	rows, resp := parseSide(jsonMap, resp, "rows")
+	if resp["Status"] == "ERROR" {
+		return -1, -1, resp
+	}
+
+	cols, resp := parseSide(jsonMap, resp, "cols")
+	if resp["Status"] == "ERROR" {
+		return -1, -1, resp
+	}
+
+	return rows, cols, resp
+}
+
+func parseSide(jsonMap map[string]interface{}, resp map[string]interface{}, key string) (int, map[string]interface{}) {
+	raw, ok := firstFormValue(jsonMap, key)
 	if !ok {
-		slog.Warn(fail(resp, "Params error: 'dim' key missing"), FuncAttrKey, Caller())
+		slog.Warn(fail(resp, fmt.Sprintf("Params error: '%s' key missing", key)), FuncAttrKey, Caller())
 		return -1, resp
 	}
 
-	dim, err := strconv.Atoi(raw)
+	side, err := strconv.Atoi(raw)
 
 	if err != nil {
 		slog.Warn(fail(resp, "Params error: "+err.Error()), FuncAttrKey, Caller())
 		return -1, resp
 	}
 
-	if dim < 2 || dim > 5 {
-		slog.Warn(fail(resp, "Params error: dim ∈ [2, 5]"), FuncAttrKey, Caller())
+	if side < minBoardSide || side > maxBoardSide {
+		slog.Warn(fail(resp, fmt.Sprintf("Params error: %s ∈ [%d, %d]", key, minBoardSide, maxBoardSide)), FuncAttrKey, Caller())
 		return -1, resp
 	}
 
-	return dim, resp
+	return side, resp
 }
 
-// ParseNeighborhood parses the request's 'neighborhood' values and validates each one
-// is a member of availableToggleSequence (the server's configured, understood set of
-// patterns), with no duplicates -- otherwise BuildToggleSequenceFromRequest's checkbox
-// state and grid.NewGrid's actual board could silently diverge, or an unrecognized
-// value could make the resulting board permanently unswitchable.
-func ParseNeighborhood(jsonMap map[string]interface{}, resp map[string]interface{}, availableToggleSequence []int) ([]int, map[string]interface{}) {
+// ParseNeighborhood parses the request's 'neighborhood' values -- pattern names -- and
+// resolves each one against availablePatterns (the server's configured set), with no
+// duplicates -- otherwise BuildToggleSequenceFromRequest's checkbox state and
+// grid.NewGrid's actual board could silently diverge, or a client could smuggle in
+// offsets the operator never configured.
+func ParseNeighborhood(jsonMap map[string]interface{}, resp map[string]interface{}, availablePatterns []Pattern) ([]Pattern, map[string]interface{}) {
 	values, ok := formValues(jsonMap, "neighborhood")
 	if !ok {
 		slog.Warn(fail(resp, "Params error: 'neighborhood' key missing"), FuncAttrKey, Caller())
-		return make([]int, 0), resp
+		return make([]Pattern, 0), resp
 	}
 
-	seen := make(map[int]bool, len(values))
-	var neighborhood = []int{}
-	for _, i := range values {
-		j, err := strconv.Atoi(i)
-		if err != nil {
-			slog.Warn(fail(resp, "Params error: "+err.Error()), FuncAttrKey, Caller())
-			return make([]int, 0), resp
-		}
-
-		if !slices.Contains(availableToggleSequence, j) {
-			slog.Warn(fail(resp, fmt.Sprintf("Params error: 'neighborhood' value %d is not a supported pattern", j)), FuncAttrKey, Caller())
-			return make([]int, 0), resp
+	seen := make(map[string]bool, len(values))
+	var neighborhood = []Pattern{}
+	for _, name := range values {
+		idx := slices.IndexFunc(availablePatterns, func(p Pattern) bool { return p.Name == name })
+		if idx < 0 {
+			slog.Warn(fail(resp, fmt.Sprintf("Params error: 'neighborhood' value %q is not a configured pattern", name)), FuncAttrKey, Caller())
+			return make([]Pattern, 0), resp
 		}
-		if seen[j] {
-			slog.Warn(fail(resp, fmt.Sprintf("Params error: 'neighborhood' value %d is duplicated", j)), FuncAttrKey, Caller())
-			return make([]int, 0), resp
+		if seen[name] {
+			slog.Warn(fail(resp, fmt.Sprintf("Params error: 'neighborhood' value %q is duplicated", name)), FuncAttrKey, Caller())
+			return make([]Pattern, 0), resp
 		}
-		seen[j] = true
+		seen[name] = true
 
-		neighborhood = append(neighborhood, j)
+		neighborhood = append(neighborhood, availablePatterns[idx])
 	}
 
 	if len(neighborhood) == 0 {
 		slog.Warn(fail(resp, "Params error: 'neighborhood' value is empty"), FuncAttrKey, Caller())
-		return make([]int, 0), resp
+		return make([]Pattern, 0), resp
 	}
 
 	return neighborhood, resp
 }
 
+// ParseTopology parses the request's 'topology' value against availableTopologies (the
+// names grid supports, which utils can't import). Like 'cheat', it's optional: a
+// missing value means the first available topology.
+func ParseTopology(jsonMap map[string]interface{}, resp map[string]interface{}, availableTopologies []string) (string, map[string]interface{}) {
+	return parseChoice(jsonMap, resp, "topology", availableTopologies)
+}
+
+// ParseDifficulty parses the request's 'difficulty' value against
+// availableDifficulties, the same way ParseTopology does.
+func ParseDifficulty(jsonMap map[string]interface{}, resp map[string]interface{}, availableDifficulties []string) (string, map[string]interface{}) {
+	return parseChoice(jsonMap, resp, "difficulty", availableDifficulties)
+}
+
+// ParseTarget parses the request's 'target' value against availableTargets, the same
+// way ParseTopology does.
+func ParseTarget(jsonMap map[string]interface{}, resp map[string]interface{}, availableTargets []string) (string, map[string]interface{}) {
+	return parseChoice(jsonMap, resp, "target", availableTargets)
+}
+
+// ParseLattice parses the request's 'lattice' value against availableLattices, the
+// same way ParseTopology does.
+func ParseLattice(jsonMap map[string]interface{}, resp map[string]interface{}, availableLattices []string) (string, map[string]interface{}) {
+	return parseChoice(jsonMap, resp, "lattice", availableLattices)
+}
+
+// parseChoice parses an optional single-value field that must be one of choices,
+// defaulting to the first when missing.
+func parseChoice(jsonMap map[string]interface{}, resp map[string]interface{}, key string, choices []string) (string, map[string]interface{}) {
+	raw, ok := firstFormValue(jsonMap, key)
+	if !ok {
+		return choices[0], resp
+	}
+
+	if !slices.Contains(choices, raw) {
+		slog.Warn(fail(resp, fmt.Sprintf("Params error: '%s' value %q is not one of %v", key, raw, choices)), FuncAttrKey, Caller())
+		return "", resp
+	}
+
+	return raw, resp
+}
+
+// ParseGraph parses the request's optional 'graph' value -- a graph name -- and resolves
+// it against availableGraphs, like ParseNeighborhood does for patterns. A missing or
+// empty value means a lattice board, returned as nil.
+func ParseGraph(jsonMap map[string]interface{}, resp map[string]interface{}, availableGraphs []Graph) (*Graph, map[string]interface{}) {
+	raw, ok := firstFormValue(jsonMap, "graph")
+	if !ok || raw == "" {
+		return nil, resp
+	}
+
+	idx := slices.IndexFunc(availableGraphs, func(g Graph) bool { return g.Name == raw })
+	if idx < 0 {
+		slog.Warn(fail(resp, fmt.Sprintf("Params error: 'graph' value %q is not a configured graph", raw)), FuncAttrKey, Caller())
+		return nil, resp
+	}
+
+	return &availableGraphs[idx], resp
+}
+
+// ParseStates parses the request's 'states' value against availableStates (the
+// numbers of states per cell grid supports). Optional, like 'topology': a missing value
+// means the first available one.
+func ParseStates(jsonMap map[string]interface{}, resp map[string]interface{}, availableStates []int) (int, map[string]interface{}) {
+	raw, ok := firstFormValue(jsonMap, "states")
+	if !ok {
+		return availableStates[0], resp
+	}
+
+	states, err := strconv.Atoi(raw)
+	if err != nil {
+		slog.Warn(fail(resp, "Params error: "+err.Error()), FuncAttrKey, Caller())
+		return -1, resp
+	}
+
+	if !slices.Contains(availableStates, states) {
+		slog.Warn(fail(resp, fmt.Sprintf("Params error: 'states' value %d is not one of %v", states, availableStates)), FuncAttrKey, Caller())
+		return -1, resp
+	}
+
+	return states, resp
+}
+
 func ParseCheat(jsonMap map[string]interface{}, resp map[string]interface{}) (bool, map[string]interface{}) {
-	cheat := false
-	if raw, ok := firstFormValue(jsonMap, "cheat"); ok {
-		cheatInt, err := strconv.Atoi(raw)
+	return parseFlag(jsonMap, resp, "cheat")
+}
+
+// ParseLitOnly parses the request's optional 'litonly' checkbox, the same way ParseCheat
+// does 'cheat'.
+func ParseLitOnly(jsonMap map[string]interface{}, resp map[string]interface{}) (bool, map[string]interface{}) {
+	return parseFlag(jsonMap, resp, "litonly")
+}
+
+// maxMoveBudget bounds a MoveBudget's presses and slack: far more than any board needs,
+// while keeping the limit well inside an int.
+const maxMoveBudget = 9999
+
+// MoveBudget is a game's move limit, as a player asks for it: none (the zero value), a
+// fixed number of presses, or the board's par plus Slack -- which only the dealt board
+// can turn into a number (see Limit).
+type MoveBudget struct {
+	Moves   int
+	FromPar bool
+	Slack   int
+}
+
+// ParseMoveBudget parses s as a MoveBudget: empty for none, a number of presses from 1
+// to maxMoveBudget, "par", or "par+N" for N presses of slack.
+func ParseMoveBudget(s string) (MoveBudget, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" {
+		return MoveBudget{}, nil
+	}
+
+	if rest, ok := strings.CutPrefix(s, "par"); ok {
+		if rest == "" {
+			return MoveBudget{FromPar: true}, nil
+		}
+		slack, err := strconv.Atoi(strings.TrimPrefix(rest, "+"))
+		if !strings.HasPrefix(rest, "+") || err != nil || slack < 0 || slack > maxMoveBudget {
+			return MoveBudget{}, fmt.Errorf("%q must be par or par+N, with N in [0, %d]", s, maxMoveBudget)
+		}
+		return MoveBudget{FromPar: true, Slack: slack}, nil
+	}
+
+	moves, err := strconv.Atoi(s)
+	if err != nil || moves < 1 || moves > maxMoveBudget {
+		return MoveBudget{}, fmt.Errorf("%q must be empty, a number of moves in [1, %d], par or par+N", s, maxMoveBudget)
+	}
+	return MoveBudget{Moves: moves}, nil
+}
+
+// String is b as ParseMoveBudget reads it back.
+func (b MoveBudget) String() string {
+	switch {
+	case b.FromPar && b.Slack > 0:
+		return fmt.Sprintf("par+%d", b.Slack)
+	case b.FromPar:
+		return "par"
+	case b.Moves > 0:
+		return strconv.Itoa(b.Moves)
+	}
+	return ""
+}
+
+// Limit returns how many presses b allows on a board of the given par, or 0 for no
+// limit. A par-based budget of a board already at par 0 still allows one press, so it
+// never starts a game out of moves.
+func (b MoveBudget) Limit(par int) int {
+	if !b.FromPar {
+		return b.Moves
+	}
+	return max(par+b.Slack, 1)
+}
+
+// ParseBudget parses the request's optional 'budget' field as a MoveBudget; a missing
+// value means none, like an unticked 'cheat'.
+func ParseBudget(jsonMap map[string]interface{}, resp map[string]interface{}) (MoveBudget, map[string]interface{}) {
+	raw, ok := firstFormValue(jsonMap, "budget")
+	if !ok {
+		return MoveBudget{}, resp
+	}
+
+	budget, err := ParseMoveBudget(raw)
+	if err != nil {
+		slog.Warn(fail(resp, "Params error: 'budget' "+err.Error()), FuncAttrKey, Caller())
+		return MoveBudget{}, resp
+	}
+
+	return budget, resp
+}
+
+// maxTimeLimitSeconds bounds a game's countdown: an hour is past any board's solve time,
+// and a longer one would mostly just outlive the session.
+const maxTimeLimitSeconds = 60 * 60
+
+// ParseTimeLimit parses the request's optional 'timelimit' field, in seconds, as a
+// game's countdown; a missing or empty value, or 0, means none.
+func ParseTimeLimit(jsonMap map[string]interface{}, resp map[string]interface{}) (time.Duration, map[string]interface{}) {
+	raw, ok := firstFormValue(jsonMap, "timelimit")
+	if !ok || strings.TrimSpace(raw) == "" {
+		return 0, resp
+	}
+
+	seconds, err := strconv.Atoi(strings.TrimSpace(raw))
+	if err != nil || seconds < 0 || seconds > maxTimeLimitSeconds {
+		slog.Warn(fail(resp, fmt.Sprintf("Params error: 'timelimit' %q must be a number of seconds in [0, %d]", raw, maxTimeLimitSeconds)), FuncAttrKey, Caller())
+		return 0, resp
+	}
+
+	return time.Duration(seconds) * time.Second, resp
+}
+
+// parseFlag parses an optional checkbox field: an integer, set if non-zero, and unset
+// when missing -- which is how a browser submits an unticked one.
+func parseFlag(jsonMap map[string]interface{}, resp map[string]interface{}, key string) (bool, map[string]interface{}) {
+	flag := false
+	if raw, ok := firstFormValue(jsonMap, key); ok {
+		flagInt, err := strconv.Atoi(raw)
 		if err != nil {
 			slog.Warn(fail(resp, "Params error: "+err.Error()), FuncAttrKey, Caller())
-			return cheat, resp
+			return flag, resp
+		}
+		flag = flagInt != 0
+	}
+
+	return flag, resp
+}
+
+// ParseReplay parses /replay's optional 'step' (how many of the game's clicks to
+// replay; absent means 0, the board as dealt) and 'play' (non-zero to keep stepping
+// forward on its own) fields. step is only bounded below here: its upper bound is the
+// game's click count, which the handler only knows once it holds the session.
+func ParseReplay(jsonMap map[string]interface{}, resp map[string]interface{}) (int, bool, map[string]interface{}) {
+	step := 0
+	if _, found := firstFormValue(jsonMap, "step"); found {
+		val, msg, ok := parseIntField(jsonMap, resp, "step")
+		if !ok {
+			slog.Warn(msg, FuncAttrKey, Caller())
+			return 0, false, resp
+		}
+		if val < 0 {
+			slog.Warn(fail(resp, fmt.Sprintf("Params error: 'step' value %d is negative", val)), FuncAttrKey, Caller())
+			return 0, false, resp
+		}
+		step = val
+	}
+
+	play := false
+	if _, found := firstFormValue(jsonMap, "play"); found {
+		val, msg, ok := parseIntField(jsonMap, resp, "play")
+		if !ok {
+			slog.Warn(msg, FuncAttrKey, Caller())
+			return 0, false, resp
 		}
-		cheat = cheatInt != 0
+		play = val != 0
 	}
 
-	return cheat, resp
+	return step, play, resp
 }
 
 // parseIntField extracts and parses a single required int field. On failure it marks
@@ -2,6 +2,7 @@ package utils
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -10,6 +11,7 @@ import (
 	"slices"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/labstack/echo/v4"
 )
@@ -18,60 +20,81 @@ func freshResp() map[string]interface{} {
 	return map[string]interface{}{"Status": "SUCCESS", "Error": ""}
 }
 
-func TestParseDim(t *testing.T) {
+func TestParseSize(t *testing.T) {
 	tests := []struct {
-		name    string
-		jsonMap map[string]interface{}
-		wantErr bool
-		want    int
+		name     string
+		jsonMap  map[string]interface{}
+		wantErr  bool
+		wantRows int
+		wantCols int
 	}{
-		{"valid", map[string]interface{}{"dim": []string{"3"}}, false, 3},
-		{"missing key", map[string]interface{}{}, true, -1},
-		{"not a string slice", map[string]interface{}{"dim": 3}, true, -1},
-		{"not a number", map[string]interface{}{"dim": []string{"abc"}}, true, -1},
-		{"below range", map[string]interface{}{"dim": []string{"1"}}, true, -1},
-		{"above range", map[string]interface{}{"dim": []string{"6"}}, true, -1},
-		{"overflow (strconv.ErrRange)", map[string]interface{}{"dim": []string{"99999999999999999999"}}, true, -1},
+		{"valid square", map[string]interface{}{"rows": []string{"3"}, "cols": []string{"3"}}, false, 3, 3},
+		{"valid rectangle", map[string]interface{}{"rows": []string{"4"}, "cols": []string{"7"}}, false, 4, 7},
+		{"missing rows", map[string]interface{}{"cols": []string{"3"}}, true, -1, -1},
+		{"missing cols", map[string]interface{}{"rows": []string{"3"}}, true, -1, -1},
+		{"not a string slice", map[string]interface{}{"rows": 3, "cols": []string{"3"}}, true, -1, -1},
+		{"not a number", map[string]interface{}{"rows": []string{"3"}, "cols": []string{"abc"}}, true, -1, -1},
+		{"rows below range", map[string]interface{}{"rows": []string{"1"}, "cols": []string{"3"}}, true, -1, -1},
+		{"cols above range", map[string]interface{}{"rows": []string{"3"}, "cols": []string{"51"}}, true, -1, -1},
+		{"overflow (strconv.ErrRange)", map[string]interface{}{"rows": []string{"99999999999999999999"}, "cols": []string{"3"}}, true, -1, -1},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, resp := ParseDim(tt.jsonMap, freshResp())
-			if got != tt.want {
-				t.Errorf("ParseDim() = %d, want %d", got, tt.want)
+			rows, cols, resp := ParseSize(tt.jsonMap, freshResp())
+			if rows != tt.wantRows || cols != tt.wantCols {
+				t.Errorf("ParseSize() = %d, %d, want %d, %d", rows, cols, tt.wantRows, tt.wantCols)
 			}
 			isErr := resp["Status"] == "ERROR"
 			if isErr != tt.wantErr {
-				t.Errorf("ParseDim() error status = %v, want error = %v (resp=%v)", isErr, tt.wantErr, resp)
+				t.Errorf("ParseSize() error status = %v, want error = %v (resp=%v)", isErr, tt.wantErr, resp)
 			}
 		})
 	}
 }
 
+// classicPatterns returns the three patterns the repo's config.json has always
+// shipped, for tests that just need a realistic Patterns list.
+func classicPatterns() []Pattern {
+	return []Pattern{
+		{Name: "0", Offsets: [][2]int{{0, 0}}},
+		{Name: "4", Offsets: [][2]int{{1, 0}, {0, 1}, {-1, 0}, {0, -1}}},
+		{Name: "8", Offsets: [][2]int{{1, 1}, {-1, -1}, {1, -1}, {-1, 1}}},
+	}
+}
+
+func names(patterns []Pattern) []string {
+	out := make([]string, len(patterns))
+	for i, p := range patterns {
+		out[i] = p.Name
+	}
+	return out
+}
+
 func TestParseNeighborhood(t *testing.T) {
-	available := []int{0, 4, 8}
+	available := classicPatterns()
 
 	tests := []struct {
 		name    string
 		jsonMap map[string]interface{}
 		wantErr bool
-		want    []int
+		want    []string
 	}{
-		{"valid single", map[string]interface{}{"neighborhood": []string{"4"}}, false, []int{4}},
-		{"valid multiple", map[string]interface{}{"neighborhood": []string{"0", "8"}}, false, []int{0, 8}},
-		{"missing key", map[string]interface{}{}, true, []int{}},
-		{"nil value", map[string]interface{}{"neighborhood": nil}, true, []int{}},
-		{"not a number", map[string]interface{}{"neighborhood": []string{"x"}}, true, []int{}},
-		{"empty slice", map[string]interface{}{"neighborhood": []string{}}, true, []int{}},
-		{"unsupported value", map[string]interface{}{"neighborhood": []string{"99"}}, true, []int{}},
-		{"duplicate value", map[string]interface{}{"neighborhood": []string{"4", "4"}}, true, []int{}},
+		{"valid single", map[string]interface{}{"neighborhood": []string{"4"}}, false, []string{"4"}},
+		{"valid multiple", map[string]interface{}{"neighborhood": []string{"0", "8"}}, false, []string{"0", "8"}},
+		{"missing key", map[string]interface{}{}, true, []string{}},
+		{"nil value", map[string]interface{}{"neighborhood": nil}, true, []string{}},
+		{"unknown name", map[string]interface{}{"neighborhood": []string{"x"}}, true, []string{}},
+		{"empty slice", map[string]interface{}{"neighborhood": []string{}}, true, []string{}},
+		{"unsupported value", map[string]interface{}{"neighborhood": []string{"99"}}, true, []string{}},
+		{"duplicate value", map[string]interface{}{"neighborhood": []string{"4", "4"}}, true, []string{}},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			got, resp := ParseNeighborhood(tt.jsonMap, freshResp(), available)
-			if !slices.Equal(got, tt.want) {
-				t.Errorf("ParseNeighborhood() = %v, want %v", got, tt.want)
+			if !slices.Equal(names(got), tt.want) {
+				t.Errorf("ParseNeighborhood() = %v, want %v", names(got), tt.want)
 			}
 			isErr := resp["Status"] == "ERROR"
 			if isErr != tt.wantErr {
@@ -79,6 +102,12 @@ func TestParseNeighborhood(t *testing.T) {
 			}
 		})
 	}
+
+	// The resolved patterns must carry the configured offsets, not just the names.
+	got, _ := ParseNeighborhood(map[string]interface{}{"neighborhood": []string{"8"}}, freshResp(), available)
+	if len(got) != 1 || len(got[0].Offsets) != 4 || got[0].Offsets[0] != [2]int{1, 1} {
+		t.Errorf("ParseNeighborhood() resolved %v, want the configured diagonal offsets", got)
+	}
 }
 
 func TestParseCheat(t *testing.T) {
@@ -108,6 +137,231 @@ func TestParseCheat(t *testing.T) {
 	}
 }
 
+func TestParseLitOnly(t *testing.T) {
+	for _, tt := range []struct {
+		jsonMap map[string]interface{}
+		wantErr bool
+		want    bool
+	}{
+		{map[string]interface{}{}, false, false},
+		{map[string]interface{}{"litonly": []string{"1"}}, false, true},
+		{map[string]interface{}{"litonly": []string{"on"}}, true, false},
+	} {
+		got, resp := ParseLitOnly(tt.jsonMap, freshResp())
+		if got != tt.want || (resp["Status"] == "ERROR") != tt.wantErr {
+			t.Errorf("ParseLitOnly(%v) = %v, %v, want %v, error %v", tt.jsonMap, got, resp, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestParseMoveBudget(t *testing.T) {
+	for _, tt := range []struct {
+		in        string
+		want      MoveBudget
+		wantErr   bool
+		wantLimit int
+	}{
+		{"", MoveBudget{}, false, 0},
+		{"25", MoveBudget{Moves: 25}, false, 25},
+		{"par", MoveBudget{FromPar: true}, false, 7},
+		{" PAR+3 ", MoveBudget{FromPar: true, Slack: 3}, false, 10},
+		{"0", MoveBudget{}, true, 0},
+		{"10000", MoveBudget{}, true, 0},
+		{"par+", MoveBudget{}, true, 0},
+		{"par-1", MoveBudget{}, true, 0},
+		{"par3", MoveBudget{}, true, 0},
+		{"lots", MoveBudget{}, true, 0},
+	} {
+		got, err := ParseMoveBudget(tt.in)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("ParseMoveBudget(%q) = %+v, %v, want %+v, error %v", tt.in, got, err, tt.want, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		if limit := got.Limit(7); limit != tt.wantLimit {
+			t.Errorf("ParseMoveBudget(%q).Limit(7) = %d, want %d", tt.in, limit, tt.wantLimit)
+		}
+		if back, _ := ParseMoveBudget(got.String()); back != got {
+			t.Errorf("ParseMoveBudget(%q).String() = %q, which parses back as %+v", tt.in, got.String(), back)
+		}
+	}
+
+	if limit := (MoveBudget{FromPar: true}).Limit(0); limit != 1 {
+		t.Errorf("a par budget on a par-0 board allows %d presses, want 1", limit)
+	}
+
+	if _, resp := ParseBudget(map[string]interface{}{"budget": []string{"lots"}}, freshResp()); resp["Status"] != "ERROR" {
+		t.Errorf("ParseBudget accepted 'lots', resp=%v", resp)
+	}
+}
+
+func TestParseTimeLimit(t *testing.T) {
+	for _, tt := range []struct {
+		jsonMap map[string]interface{}
+		wantErr bool
+		want    time.Duration
+	}{
+		{map[string]interface{}{}, false, 0},
+		{map[string]interface{}{"timelimit": []string{""}}, false, 0},
+		{map[string]interface{}{"timelimit": []string{"90"}}, false, 90 * time.Second},
+		{map[string]interface{}{"timelimit": []string{"0"}}, false, 0},
+		{map[string]interface{}{"timelimit": []string{"-5"}}, true, 0},
+		{map[string]interface{}{"timelimit": []string{"3601"}}, true, 0},
+		{map[string]interface{}{"timelimit": []string{"1m"}}, true, 0},
+	} {
+		got, resp := ParseTimeLimit(tt.jsonMap, freshResp())
+		if got != tt.want || (resp["Status"] == "ERROR") != tt.wantErr {
+			t.Errorf("ParseTimeLimit(%v) = %v, %v, want %v, error %v", tt.jsonMap, got, resp, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestParseReplay(t *testing.T) {
+	tests := []struct {
+		name     string
+		jsonMap  map[string]interface{}
+		wantErr  bool
+		wantStep int
+		wantPlay bool
+	}{
+		{"absent defaults to the start, paused", map[string]interface{}{}, false, 0, false},
+		{"step and play", map[string]interface{}{"step": []string{"3"}, "play": []string{"1"}}, false, 3, true},
+		{"play 0 is paused", map[string]interface{}{"step": []string{"3"}, "play": []string{"0"}}, false, 3, false},
+		{"negative step", map[string]interface{}{"step": []string{"-1"}}, true, 0, false},
+		{"invalid step", map[string]interface{}{"step": []string{"x"}}, true, 0, false},
+		{"invalid play", map[string]interface{}{"play": []string{"x"}}, true, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, play, resp := ParseReplay(tt.jsonMap, freshResp())
+			if step != tt.wantStep || play != tt.wantPlay {
+				t.Errorf("ParseReplay() = %d, %v, want %d, %v", step, play, tt.wantStep, tt.wantPlay)
+			}
+			isErr := resp["Status"] == "ERROR"
+			if isErr != tt.wantErr {
+				t.Errorf("ParseReplay() error status = %v, want error = %v (resp=%v)", isErr, tt.wantErr, resp)
+			}
+		})
+	}
+}
+
+func TestParseTopology(t *testing.T) {
+	available := []string{"bounded", "torus"}
+	tests := []struct {
+		name    string
+		jsonMap map[string]interface{}
+		wantErr bool
+		want    string
+	}{
+		{"absent defaults to first", map[string]interface{}{}, false, "bounded"},
+		{"valid", map[string]interface{}{"topology": []string{"torus"}}, false, "torus"},
+		{"unknown", map[string]interface{}{"topology": []string{"klein"}}, true, ""},
+		{"not a string slice", map[string]interface{}{"topology": "torus"}, false, "bounded"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, resp := ParseTopology(tt.jsonMap, freshResp(), available)
+			if got != tt.want {
+				t.Errorf("ParseTopology() = %q, want %q", got, tt.want)
+			}
+			isErr := resp["Status"] == "ERROR"
+			if isErr != tt.wantErr {
+				t.Errorf("ParseTopology() error status = %v, want error = %v (resp=%v)", isErr, tt.wantErr, resp)
+			}
+		})
+	}
+}
+
+func TestParseLattice(t *testing.T) {
+	available := []string{"square", "hex"}
+
+	if got, resp := ParseLattice(map[string]interface{}{}, freshResp(), available); got != "square" || resp["Status"] == "ERROR" {
+		t.Errorf("ParseLattice() with no lattice = %q (resp=%v), want \"square\"", got, resp)
+	}
+	if got, resp := ParseLattice(map[string]interface{}{"lattice": []string{"hex"}}, freshResp(), available); got != "hex" || resp["Status"] == "ERROR" {
+		t.Errorf("ParseLattice(hex) = %q (resp=%v), want \"hex\"", got, resp)
+	}
+	if _, resp := ParseLattice(map[string]interface{}{"lattice": []string{"triangle"}}, freshResp(), available); resp["Status"] != "ERROR" {
+		t.Errorf("ParseLattice(triangle) accepted an unknown lattice (resp=%v)", resp)
+	}
+}
+
+func TestParseDifficulty(t *testing.T) {
+	available := []string{"", "easy", "medium", "hard"}
+
+	if got, resp := ParseDifficulty(map[string]interface{}{}, freshResp(), available); got != "" || resp["Status"] == "ERROR" {
+		t.Errorf("ParseDifficulty() with no difficulty = %q (resp=%v), want \"\"", got, resp)
+	}
+	if got, resp := ParseDifficulty(map[string]interface{}{"difficulty": []string{"hard"}}, freshResp(), available); got != "hard" || resp["Status"] == "ERROR" {
+		t.Errorf("ParseDifficulty(hard) = %q (resp=%v), want \"hard\"", got, resp)
+	}
+	if _, resp := ParseDifficulty(map[string]interface{}{"difficulty": []string{"fiendish"}}, freshResp(), available); resp["Status"] != "ERROR" {
+		t.Errorf("ParseDifficulty(fiendish) accepted an unknown difficulty (resp=%v)", resp)
+	}
+}
+
+func TestParseTarget(t *testing.T) {
+	available := []string{"", "random", "heart"}
+
+	if got, resp := ParseTarget(map[string]interface{}{}, freshResp(), available); got != "" || resp["Status"] == "ERROR" {
+		t.Errorf("ParseTarget() with no target = %q (resp=%v), want \"\"", got, resp)
+	}
+	if got, resp := ParseTarget(map[string]interface{}{"target": []string{"heart"}}, freshResp(), available); got != "heart" || resp["Status"] == "ERROR" {
+		t.Errorf("ParseTarget(heart) = %q (resp=%v), want \"heart\"", got, resp)
+	}
+	if _, resp := ParseTarget(map[string]interface{}{"target": []string{"spade"}}, freshResp(), available); resp["Status"] != "ERROR" {
+		t.Errorf("ParseTarget(spade) accepted an unknown target (resp=%v)", resp)
+	}
+}
+
+func TestParseGraph(t *testing.T) {
+	available := []Graph{{Name: "ring", Nodes: 3, Edges: [][2]int{{0, 1}, {1, 2}, {2, 0}}}}
+
+	for _, jsonMap := range []map[string]interface{}{{}, {"graph": []string{""}}} {
+		if got, resp := ParseGraph(jsonMap, freshResp(), available); got != nil || resp["Status"] == "ERROR" {
+			t.Errorf("ParseGraph(%v) = %v (resp=%v), want nil for a lattice board", jsonMap, got, resp)
+		}
+	}
+	if got, resp := ParseGraph(map[string]interface{}{"graph": []string{"ring"}}, freshResp(), available); got != &available[0] || resp["Status"] == "ERROR" {
+		t.Errorf("ParseGraph(ring) = %v (resp=%v), want the configured ring", got, resp)
+	}
+	if _, resp := ParseGraph(map[string]interface{}{"graph": []string{"moebius"}}, freshResp(), available); resp["Status"] != "ERROR" {
+		t.Errorf("ParseGraph(moebius) accepted an unconfigured graph (resp=%v)", resp)
+	}
+}
+
+func TestParseStates(t *testing.T) {
+	available := []int{2, 3, 5, 7}
+	tests := []struct {
+		name    string
+		jsonMap map[string]interface{}
+		wantErr bool
+		want    int
+	}{
+		{"absent defaults to first", map[string]interface{}{}, false, 2},
+		{"valid", map[string]interface{}{"states": []string{"5"}}, false, 5},
+		{"unsupported", map[string]interface{}{"states": []string{"4"}}, true, -1},
+		{"not a number", map[string]interface{}{"states": []string{"three"}}, true, -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, resp := ParseStates(tt.jsonMap, freshResp(), available)
+			if got != tt.want {
+				t.Errorf("ParseStates() = %d, want %d", got, tt.want)
+			}
+			isErr := resp["Status"] == "ERROR"
+			if isErr != tt.wantErr {
+				t.Errorf("ParseStates() error status = %v, want error = %v (resp=%v)", isErr, tt.wantErr, resp)
+			}
+		})
+	}
+}
+
 func TestParseRowCol(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -141,37 +395,38 @@ func TestParseRowCol(t *testing.T) {
 
 func TestBuildNeighborhoodFromConfig(t *testing.T) {
 	config := &Config{
-		ToggleSequence:          []bool{true, false, true},
-		AvailableToggleSequence: []int{0, 4, 8},
+		ToggleSequence: []bool{true, false, true},
+		Patterns:       classicPatterns(),
 	}
 
-	got := BuildNeighborhoodFromConfig(config)
-	want := []int{0, 8}
+	got := names(BuildNeighborhoodFromConfig(config))
+	want := []string{"0", "8"}
 	if !slices.Equal(got, want) {
 		t.Errorf("BuildNeighborhoodFromConfig() = %v, want %v", got, want)
 	}
 }
 
 // TestBuildNeighborhoodFromConfigShorterToggleSequence documents BuildNeighborhoodFromConfig's
-// behavior when ToggleSequence is shorter than AvailableToggleSequence -- only reachable
+// behavior when ToggleSequence is shorter than Patterns -- only reachable
 // if a caller bypasses ParseJSONConfig/validateConfig (which guarantee equal lengths for
 // any Config that passes validation). It now stops at the shorter length instead of
 // panicking with an index-out-of-range.
 func TestBuildNeighborhoodFromConfigShorterToggleSequence(t *testing.T) {
 	config := &Config{
-		ToggleSequence:          []bool{true}, // shorter than AvailableToggleSequence
-		AvailableToggleSequence: []int{0, 4, 8},
+		ToggleSequence: []bool{true}, // shorter than Patterns
+		Patterns:       classicPatterns(),
 	}
 
-	got := BuildNeighborhoodFromConfig(config)
-	want := []int{0}
+	got := names(BuildNeighborhoodFromConfig(config))
+	want := []string{"0"}
 	if !slices.Equal(got, want) {
 		t.Errorf("BuildNeighborhoodFromConfig() = %v, want %v", got, want)
 	}
 }
 
 func TestBuildToggleSequenceFromRequest(t *testing.T) {
-	got := BuildToggleSequenceFromRequest([]int{0, 8}, []int{0, 4, 8})
+	available := classicPatterns()
+	got := BuildToggleSequenceFromRequest([]Pattern{available[0], available[2]}, available)
 	want := []bool{true, false, true}
 
 	if len(got) != len(want) {
@@ -190,7 +445,8 @@ func TestBuildToggleSequenceFromRequest(t *testing.T) {
 // since this function has no validation of its own (in production, ParseNeighborhood
 // already rejects such values before this is ever called).
 func TestBuildToggleSequenceFromRequestSilentlyDropsUnknownValues(t *testing.T) {
-	got := BuildToggleSequenceFromRequest([]int{0, 99}, []int{0, 4, 8})
+	available := classicPatterns()
+	got := BuildToggleSequenceFromRequest([]Pattern{available[0], {Name: "99", Offsets: [][2]int{{0, 0}}}}, available)
 	want := []bool{true, false, false}
 
 	if len(got) != len(want) {
@@ -207,14 +463,14 @@ func TestProcessRequestFormAndQuery(t *testing.T) {
 	e := echo.New()
 
 	form := url.Values{}
-	form.Set("dim", "3")
+	form.Set("rows", "3")
 	req := httptest.NewRequest(http.MethodPost, "/reset", strings.NewReader(form.Encode()))
 	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
 	c := e.NewContext(req, httptest.NewRecorder())
 
 	jsonMap := ProcessRequestForm(c)
-	if v, ok := firstFormValue(jsonMap, "dim"); !ok || v != "3" {
-		t.Errorf("ProcessRequestForm() dim = %v, %v, want \"3\", true", v, ok)
+	if v, ok := firstFormValue(jsonMap, "rows"); !ok || v != "3" {
+		t.Errorf("ProcessRequestForm() rows = %v, %v, want \"3\", true", v, ok)
 	}
 
 	req2 := httptest.NewRequest(http.MethodPost, "/switch?row=1&col=2", nil)
@@ -232,21 +488,23 @@ func TestProcessRequestFormAndQuery(t *testing.T) {
 func TestValidateConfig(t *testing.T) {
 	base := func() Config {
 		return Config{
-			Port:                            "10000",
-			Dim:                             3,
-			ToggleSequence:                  []bool{true, true, false},
-			AvailableToggleSequence:         []int{0, 4, 8},
-			MaxSessions:                     10,
-			SessionTTLSeconds:               1800,
-			SessionIdleTimeoutSeconds:       300,
-			SessionWaitCheckIntervalSeconds: 2,
-			MaxWaitingConnections:           50,
-			LogFilePath:                     "./logs/goswitch.log",
-			LogMaxSizeMB:                    5,
-			LogMaxBackups:                   5,
-			LogLevel:                        "INFO",
-			RateLimitRequestsPerSecond:      5,
-			RateLimitBurst:                  10,
+			Port:                       "10000",
+			Rows:                       3,
+			Cols:                       3,
+			ToggleSequence:             []bool{true, true, false},
+			Patterns:                   classicPatterns(),
+			MaxSessions:                10,
+			SessionTTLSeconds:          1800,
+			SessionIdleTimeoutSeconds:  300,
+			MaxWaitingConnections:      50,
+			LogFilePath:                "./logs/goswitch.log",
+			LogMaxSizeMB:               5,
+			LogMaxBackups:              5,
+			LogLevel:                   "INFO",
+			RateLimitRequestsPerSecond: 5,
+			RateLimitBurst:             10,
+			Graph:                      "ring",
+			Graphs:                     []Graph{{Name: "ring", Nodes: 3, Edges: [][2]int{{0, 1}, {1, 2}, {2, 0}}}},
 		}
 	}
 
@@ -268,23 +526,41 @@ func TestValidateConfig(t *testing.T) {
 		{"non-numeric port", func(c *Config) { c.Port = "abc" }},
 		{"negative port", func(c *Config) { c.Port = "-1" }},
 		{"port out of range", func(c *Config) { c.Port = "99999" }},
-		{"dim too small", func(c *Config) { c.Dim = 1 }},
-		{"dim too large", func(c *Config) { c.Dim = 6 }},
+		{"rows too small", func(c *Config) { c.Rows = 1 }},
+		{"rows too large", func(c *Config) { c.Rows = 51 }},
+		{"cols too small", func(c *Config) { c.Cols = 1 }},
+		{"cols too large", func(c *Config) { c.Cols = 51 }},
 		{"mismatched toggle sequence length", func(c *Config) { c.ToggleSequence = []bool{true} }},
 		{"zero max sessions", func(c *Config) { c.MaxSessions = 0 }},
 		{"zero ttl", func(c *Config) { c.SessionTTLSeconds = 0 }},
 		{"idle timeout exceeds ttl", func(c *Config) { c.SessionIdleTimeoutSeconds = c.SessionTTLSeconds + 1 }},
 		{"zero idle timeout", func(c *Config) { c.SessionIdleTimeoutSeconds = 0 }},
-		{"zero wait check interval", func(c *Config) { c.SessionWaitCheckIntervalSeconds = 0 }},
 		{"zero max waiting connections", func(c *Config) { c.MaxWaitingConnections = 0 }},
-		{"unsupported available toggle sequence value", func(c *Config) { c.AvailableToggleSequence = []int{0, 4, 99} }},
-		{"duplicate available toggle sequence value", func(c *Config) { c.AvailableToggleSequence = []int{0, 0, 4} }},
+		{"no patterns", func(c *Config) { c.Patterns, c.ToggleSequence = nil, nil }},
+		{"duplicate pattern name", func(c *Config) { c.Patterns[2].Name = "0" }},
+		{"empty pattern name", func(c *Config) { c.Patterns[0].Name = "" }},
+		{"unsafe pattern name", func(c *Config) { c.Patterns[0].Name = `"><script>` }},
+		{"pattern without offsets", func(c *Config) { c.Patterns[0].Offsets = nil }},
+		{"pattern offset out of reach", func(c *Config) { c.Patterns[0].Offsets = [][2]int{{0, 50}} }},
+		{"duplicate pattern offset", func(c *Config) { c.Patterns[0].Offsets = [][2]int{{1, 0}, {1, 0}} }},
 		{"empty log file path", func(c *Config) { c.LogFilePath = "" }},
 		{"zero log max size", func(c *Config) { c.LogMaxSizeMB = 0 }},
 		{"zero log max backups", func(c *Config) { c.LogMaxBackups = 0 }},
 		{"invalid log level", func(c *Config) { c.LogLevel = "VERBOSE" }},
 		{"zero rate limit", func(c *Config) { c.RateLimitRequestsPerSecond = 0 }},
 		{"zero rate limit burst", func(c *Config) { c.RateLimitBurst = 0 }},
+		{"unsafe graph name", func(c *Config) { c.Graphs[0].Name = "a b" }},
+		{"duplicate graph name", func(c *Config) { c.Graphs = append(c.Graphs, c.Graphs[0]) }},
+		{"graph with one node", func(c *Config) { c.Graphs[0].Nodes, c.Graphs[0].Edges = 1, nil }},
+		{"graph too large", func(c *Config) { c.Graphs[0].Nodes = maxGraphNodes + 1 }},
+		{"graph missing positions", func(c *Config) { c.Graphs[0].Positions = [][2]float64{{0, 0}} }},
+		{"graph edge out of range", func(c *Config) { c.Graphs[0].Edges[0] = [2]int{0, 3} }},
+		{"graph self-loop", func(c *Config) { c.Graphs[0].Edges[0] = [2]int{1, 1} }},
+		{"graph duplicate edge", func(c *Config) { c.Graphs[0].Edges[1] = [2]int{1, 0} }},
+		{"unknown default graph", func(c *Config) { c.Graph = "moebius" }},
+		{"invalid move budget", func(c *Config) { c.MoveBudget = "par-1" }},
+		{"negative time limit", func(c *Config) { c.TimeLimitSeconds = -1 }},
+		{"short admin token", func(c *Config) { c.AdminToken = "hunter2" }},
 	}
 
 	for _, tt := range tests {
@@ -298,6 +574,39 @@ func TestValidateConfig(t *testing.T) {
 	}
 }
 
+// TestValidateConfigRunsExtraChecks covers the ConfigCheck hook ParseJSONConfig
+// exposes for rules utils can't implement itself (e.g. grid.CheckConfig).
+func TestValidateConfigRunsExtraChecks(t *testing.T) {
+	config := Config{
+		Port:                       "10000",
+		Rows:                       3,
+		Cols:                       3,
+		ToggleSequence:             []bool{true, true, false},
+		Patterns:                   classicPatterns(),
+		MaxSessions:                10,
+		SessionTTLSeconds:          1800,
+		SessionIdleTimeoutSeconds:  300,
+		MaxWaitingConnections:      50,
+		LogFilePath:                "./logs/goswitch.log",
+		LogMaxSizeMB:               5,
+		LogMaxBackups:              5,
+		LogLevel:                   "INFO",
+		RateLimitRequestsPerSecond: 5,
+		RateLimitBurst:             10,
+	}
+
+	called := false
+	pass := func(*Config) error { called = true; return nil }
+	if err := validateConfig(&config, pass); err != nil || !called {
+		t.Fatalf("validateConfig() with a passing check = %v (called=%v), want nil (called=true)", err, called)
+	}
+
+	reject := func(*Config) error { return errors.New("rejected") }
+	if err := validateConfig(&config, pass, reject); err == nil {
+		t.Fatal("validateConfig() ignored a failing extra check")
+	}
+}
+
 func TestParseJSONConfigValidFile(t *testing.T) {
 	dir := t.TempDir()
 	path := filepath.Join(dir, "config.json")
@@ -305,31 +614,50 @@ func TestParseJSONConfigValidFile(t *testing.T) {
 	content := `{
 		"Port": "10000",
 		"Cheat": false,
-		"Dim": 3,
+		"Rows": 3,
+		"Cols": 4,
 		"ToggleSequence": [true, true, false],
-		"AvailableToggleSequence": [0, 4, 8],
+		"Patterns": [
+			{"Name": "0", "Offsets": [[0, 0]]},
+			{"Name": "4", "Offsets": [[1, 0], [0, 1], [-1, 0], [0, -1]]},
+			{"Name": "8", "Offsets": [[1, 1], [-1, -1], [1, -1], [-1, 1]]}
+		],
 		"MaxSessions": 10,
 		"SessionTTLSeconds": 1800,
 		"SessionIdleTimeoutSeconds": 300,
-		"SessionWaitCheckIntervalSeconds": 2,
 		"MaxWaitingConnections": 50,
 		"LogFilePath": "./logs/goswitch.log",
 		"LogMaxSizeMB": 5,
 		"LogMaxBackups": 5,
 		"LogLevel": "INFO",
 		"RateLimitRequestsPerSecond": 5,
-		"RateLimitBurst": 10
+		"RateLimitBurst": 10,
+		"GraphsFile": "graphs.json"
 	}`
 
 	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
 		t.Fatalf("failed to write temp config: %v", err)
 	}
+	// Resolved against config.json's own directory, not the working directory.
+	graphs := `[{"Name": "ring", "Nodes": 3, "Edges": [[0, 1], [1, 2], [2, 0]]}]`
+	if err := os.WriteFile(filepath.Join(dir, "graphs.json"), []byte(graphs), 0o600); err != nil {
+		t.Fatalf("failed to write temp graphs: %v", err)
+	}
+
+	// The secret comes from the environment, not the committed file.
+	t.Setenv(adminTokenEnvVar, "correct-horse-battery-staple")
 
 	config := ParseJSONConfig(path)
 
-	if config.Port != "10000" || config.Dim != 3 || config.MaxSessions != 10 {
+	if config.AdminToken != "correct-horse-battery-staple" {
+		t.Errorf("ParseJSONConfig() AdminToken = %q, want %s's", config.AdminToken, adminTokenEnvVar)
+	}
+	if config.Port != "10000" || config.Rows != 3 || config.Cols != 4 || config.MaxSessions != 10 || len(config.Patterns) != 3 || config.Patterns[1].Offsets[3] != [2]int{0, -1} {
 		t.Errorf("ParseJSONConfig() = %+v, unexpected values", config)
 	}
+	if len(config.Graphs) != 1 || config.Graphs[0].Name != "ring" || config.Graphs[0].Edges[2] != [2]int{2, 0} {
+		t.Errorf("ParseJSONConfig() loaded graphs %+v, want the ring from graphs.json", config.Graphs)
+	}
 }
 
 // TestRealConfigJSONIsValid guards against the repo's own committed config.json
@@ -347,6 +675,11 @@ func TestRealConfigJSONIsValid(t *testing.T) {
 		t.Fatalf("repo-root config.json failed to parse: %v", err)
 	}
 
+	config.Graphs, err = LoadGraphs(filepath.Join("..", "..", config.GraphsFile))
+	if err != nil {
+		t.Fatalf("repo-root config.json's GraphsFile failed to load: %v", err)
+	}
+
 	if err := validateConfig(&config); err != nil {
 		t.Fatalf("repo-root config.json is no longer valid: %v", err)
 	}
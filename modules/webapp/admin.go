@@ -0,0 +1,211 @@
+package webapp
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	session "goSwitch/modules/session"
+	utils "goSwitch/modules/utils"
+)
+
+const adminCookieName = "goswitch_admin"
+
+// adminCookieMaxAge is how long a dashboard login lasts: the browser drops the cookie
+// then, and the server refuses it from then on even if it's kept (see
+// adminCookieValid).
+const adminCookieMaxAge = 12 * time.Hour
+
+// adminView is the /admin dashboard's render data, and the admin API's JSON body:
+// every live session, capacity, and the waiting room -- Waiting clients in line, and
+// WaitingConns of them holding a /wait stream open. Error is set by a rejected action.
+type adminView struct {
+	Sessions     []adminSessionView
+	Count        int
+	MaxSessions  int
+	Waiting      int
+	WaitingConns int
+	Error        string `json:",omitempty"`
+}
+
+// adminSessionView is one session on the dashboard: its summary, plus how long since
+// it was last active.
+type adminSessionView struct {
+	session.Summary
+	IdleSeconds int
+}
+
+func (wx *WebAppX) adminState(now time.Time) adminView {
+	summaries := wx.Sessions.Summaries()
+	view := adminView{
+		Sessions:     make([]adminSessionView, 0, len(summaries)),
+		Count:        len(summaries),
+		MaxSessions:  wx.Sessions.MaxSessions(),
+		Waiting:      wx.Sessions.Waiting(),
+		WaitingConns: int(wx.waitingConns.Load()),
+	}
+	for _, summary := range summaries {
+		idle := int(max(now.Sub(summary.LastUpdatedAt), 0) / time.Second)
+		view.Sessions = append(view.Sessions, adminSessionView{Summary: summary, IdleSeconds: idle})
+	}
+	return view
+}
+
+// adminCookieValue is what the dashboard's login cookie holds for token when issued
+// at issued: the time, in Unix seconds, and an HMAC of it keyed by the token. That
+// proves its holder had the token then without keeping the secret in the browser, and
+// unlike a fixed digest of the token, a leaked cookie goes stale, and one with its time
+// moved on fails the HMAC.
+func adminCookieValue(token string, issued time.Time) string {
+	stamp := strconv.FormatInt(issued.Unix(), 10)
+	return stamp + "." + hex.EncodeToString(adminCookieMAC(token, stamp))
+}
+
+// adminCookieMAC is the HMAC adminCookieValue signs stamp with.
+func adminCookieMAC(token, stamp string) []byte {
+	mac := hmac.New(sha256.New, []byte(token))
+	_, _ = mac.Write([]byte("goswitch-admin:" + stamp)) // a hash.Hash's Write never fails
+	return mac.Sum(nil)
+}
+
+// adminCookieValid reports whether value is a cookie adminCookieValue issued for token
+// less than adminCookieMaxAge before now. Changing the token revokes every cookie
+// issued for the old one.
+func adminCookieValid(token, value string, now time.Time) bool {
+	stamp, sig, ok := strings.Cut(value, ".")
+	if !ok {
+		return false
+	}
+	issued, err := strconv.ParseInt(stamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	mac, err := hex.DecodeString(sig)
+	if err != nil || !hmac.Equal(mac, adminCookieMAC(token, stamp)) {
+		return false
+	}
+	age := now.Sub(time.Unix(issued, 0))
+	return age >= 0 && age < adminCookieMaxAge
+}
+
+// adminAuthorized reports whether c carries the admin token: as a bearer token in its
+// Authorization header (for API clients), or by the cookie AdminLogin sets (for the
+// dashboard), while it's fresh. Neither is sent along by a cross-site request -- the
+// cookie is SameSite=Strict -- so the admin actions need no further CSRF protection.
+func (wx *WebAppX) adminAuthorized(c echo.Context) bool {
+	token := wx.Config.AdminToken
+	if bearer, ok := strings.CutPrefix(c.Request().Header.Get(echo.HeaderAuthorization), "Bearer "); ok {
+		return subtle.ConstantTimeCompare([]byte(bearer), []byte(token)) == 1
+	}
+	if cookie, err := c.Cookie(adminCookieName); err == nil {
+		return adminCookieValid(token, cookie.Value, time.Now())
+	}
+	return false
+}
+
+// RequireAdmin guards the /admin area's handlers: with no AdminToken configured the
+// area doesn't exist (404), and without the token a request is refused (401) -- the
+// dashboard with its login form, anything else bare.
+func (wx *WebAppX) RequireAdmin(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if wx.Config.AdminToken == "" {
+			return c.NoContent(http.StatusNotFound)
+		}
+		if !wx.adminAuthorized(c) {
+			slog.Warn(fmt.Sprintf("Admin -- unauthorized %s %s", c.Request().Method, c.Path()), utils.FuncAttrKey, utils.Caller())
+			if c.Request().Method == http.MethodGet && c.Path() == "/admin" {
+				return c.Render(http.StatusUnauthorized, "admin-login", adminView{})
+			}
+			return c.NoContent(http.StatusUnauthorized)
+		}
+		return next(c)
+	}
+}
+
+// AdminLogin checks the token posted from the dashboard's login form and, if it's
+// right, sets the cookie that keeps the dashboard unlocked and sends it there.
+func (wx *WebAppX) AdminLogin(c echo.Context) error {
+	token := wx.Config.AdminToken
+	if token == "" {
+		return c.NoContent(http.StatusNotFound)
+	}
+	if subtle.ConstantTimeCompare([]byte(c.FormValue("token")), []byte(token)) != 1 {
+		slog.Warn("Admin -- failed login", utils.FuncAttrKey, utils.Caller())
+		return c.Render(http.StatusUnauthorized, "admin-login", adminView{Error: "Wrong token."})
+	}
+
+	c.SetCookie(&http.Cookie{
+		Name:     adminCookieName,
+		Value:    adminCookieValue(token, time.Now()),
+		Path:     "/admin",
+		MaxAge:   int(adminCookieMaxAge / time.Second),
+		HttpOnly: true,
+		Secure:   wx.secureRequest(c),
+		SameSite: http.SameSiteStrictMode,
+	})
+	slog.Info("Admin -- logged in", utils.FuncAttrKey, utils.Caller())
+	return c.Redirect(http.StatusSeeOther, "/admin")
+}
+
+// Admin serves the dashboard: the whole page, or for htmx's refresh just the
+// dashboard itself.
+func (wx *WebAppX) Admin(c echo.Context) error {
+	return wx.adminRespond(c, http.StatusOK, "")
+}
+
+// AdminSessions serves the dashboard's data as JSON, for scripts and monitoring.
+func (wx *WebAppX) AdminSessions(c echo.Context) error {
+	return c.JSON(http.StatusOK, wx.adminState(time.Now()))
+}
+
+// AdminEvict force-evicts the session named by the :handle path parameter (see
+// session.Handle and session.Manager.Evict).
+func (wx *WebAppX) AdminEvict(c echo.Context) error {
+	handle := c.Param("handle")
+	if !wx.Sessions.EvictHandle(handle) {
+		return wx.adminRespond(c, http.StatusNotFound, "No such session: it may have just gone.")
+	}
+	slog.Info(fmt.Sprintf("Admin -- evicted session %s", handle), utils.FuncAttrKey, utils.Caller())
+	return wx.adminRespond(c, http.StatusOK, "")
+}
+
+// AdminCapacity sets MaxSessions, until the server restarts, from the "maxsessions"
+// form value (see session.Manager.SetMaxSessions).
+func (wx *WebAppX) AdminCapacity(c echo.Context) error {
+	n, err := strconv.Atoi(strings.TrimSpace(c.FormValue("maxsessions")))
+	if err == nil {
+		err = wx.Sessions.SetMaxSessions(n)
+	}
+	if err != nil {
+		return wx.adminRespond(c, http.StatusBadRequest, fmt.Sprintf("Can't set the capacity: %v", err))
+	}
+	slog.Info(fmt.Sprintf("Admin -- MaxSessions set to %d", n), utils.FuncAttrKey, utils.Caller())
+	return wx.adminRespond(c, http.StatusOK, "")
+}
+
+// adminRespond answers an admin request with the dashboard as it now stands, errMsg
+// included: to htmx, as the re-rendered dashboard -- always 200, since htmx won't
+// swap in an error response -- and to the API, as JSON with status; a plain browser
+// request gets the whole page.
+func (wx *WebAppX) adminRespond(c echo.Context, status int, errMsg string) error {
+	view := wx.adminState(time.Now())
+	view.Error = errMsg
+
+	switch {
+	case c.Request().Header.Get("HX-Request") == "true":
+		return c.Render(http.StatusOK, "admin", view)
+	case c.Request().Method == http.MethodGet:
+		return c.Render(status, "admin-page", view)
+	default:
+		return c.JSON(status, view)
+	}
+}
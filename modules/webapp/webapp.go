@@ -7,8 +7,11 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"log"
 	"log/slog"
+	"math"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -19,6 +22,7 @@ import (
 	"golang.org/x/time/rate"
 
 	grid "goSwitch/modules/grid"
+	puzzle "goSwitch/modules/puzzle"
 	session "goSwitch/modules/session"
 	template "goSwitch/modules/template"
 	utils "goSwitch/modules/utils"
@@ -54,15 +58,322 @@ type WebAppX struct {
 	// even have) a real session could still hold an unbounded number of open SSE
 	// connections/goroutines.
 	waitingConns atomic.Int32
+
+	// clocks holds each session's open Clock() stream, keyed by session ID: one per
+	// session is all a countdown needs, so opening another -- a reload, a second tab --
+	// ends the one before. Without that, a client could open any number, and since each
+	// needs a live session, this caps them at MaxSessions in all.
+	clocksMu sync.Mutex
+	clocks   map[string]chan struct{}
+
+	// analyzing holds the IDs of sessions with an Analyze() preview in flight. A
+	// preview of a new configuration pays for its toggle matrix's elimination, so each
+	// session gets one at a time -- the configuration form queues its own -- which,
+	// like clocks, caps them at MaxSessions in all.
+	analyzingMu sync.Mutex
+	analyzing   map[string]struct{}
+
+	// done is closed once Server starts shutting down, which ends the session janitor
+	// and every open Wait() and Clock() stream -- graceful shutdown waits for in-flight
+	// requests to finish, and those would otherwise only finish on their own time.
+	done <-chan struct{}
 }
 
 // configView adapts a session's live game settings plus the app-wide list of
 // available patterns into the shape the existing templates expect at .Config.
 type configView struct {
-	Dim                     int
-	Cheat                   bool
-	ToggleSequence          []bool
-	AvailableToggleSequence []int
+	Rows              int
+	Cols              int
+	Lattice           grid.Lattice
+	Lattices          []grid.Lattice
+	Topology          grid.Topology
+	Topologies        []grid.Topology
+	States            int
+	AvailableStates   []int
+	Graph             string
+	Graphs            []string
+	Difficulty        string
+	Difficulties      []string
+	Target            string
+	Targets           []string
+	LitOnly           bool
+	Cheat             bool
+	Budget            string
+	TimeLimit         int
+	ToggleSequence    []bool
+	AvailablePatterns []utils.Pattern
+}
+
+// analysisView is grid.Analysis as the configuration panel shows it. Error is set
+// instead of the rest when the configuration being previewed couldn't be parsed.
+type analysisView struct {
+	Rank            int
+	Nullity         int
+	QuietPatterns   [][]int
+	SolvablePercent string
+	Degenerate      bool
+	Error           string
+}
+
+// latticeNames returns grid.Lattices as the plain strings utils.ParseLattice validates
+// against.
+func latticeNames() []string {
+	names := make([]string, len(grid.Lattices))
+	for i, l := range grid.Lattices {
+		names[i] = string(l)
+	}
+	return names
+}
+
+// topologyNames returns grid.Topologies as the plain strings utils.ParseTopology
+// validates against.
+func topologyNames() []string {
+	names := make([]string, len(grid.Topologies))
+	for i, t := range grid.Topologies {
+		names[i] = string(t)
+	}
+	return names
+}
+
+// difficultyNames returns grid.Difficulties as the plain strings utils.ParseDifficulty
+// validates against and the configuration panel offers, "" (any) first.
+func difficultyNames() []string {
+	names := make([]string, len(grid.Difficulties))
+	for i, d := range grid.Difficulties {
+		names[i] = string(d)
+	}
+	return names
+}
+
+// targetNames returns grid.Targets as the plain strings utils.ParseTarget validates
+// against and the configuration panel offers, "" (uniform) first.
+func targetNames() []string {
+	names := make([]string, len(grid.Targets))
+	for i, t := range grid.Targets {
+		names[i] = string(t)
+	}
+	return names
+}
+
+// graphNames returns the names of the configured graph boards, in config order.
+func graphNames(graphs []utils.Graph) []string {
+	names := make([]string, len(graphs))
+	for i, g := range graphs {
+		names[i] = g.Name
+	}
+	return names
+}
+
+// graphView lays out a graph board for grid.html: where each node and edge goes, as
+// percentages of the board's drawing area.
+type graphView struct {
+	Nodes []graphPoint
+	Edges []graphEdge
+}
+
+type graphPoint struct {
+	X float64
+	Y float64
+}
+
+type graphEdge struct {
+	X1, Y1, X2, Y2 float64
+}
+
+// graphMargin is how far, in percent of the drawing area, nodes are kept from its
+// edges, so the outermost ones aren't clipped.
+const graphMargin = 10
+
+// newGraphView scales graph's Positions into the drawing area, keeping their aspect
+// ratio and centering them, or spaces its nodes evenly round a circle if it has none.
+func newGraphView(graph *grid.Graph) *graphView {
+	positions := graph.Positions
+	if len(positions) == 0 {
+		positions = make([][2]float64, graph.Nodes)
+		for i := range positions {
+			angle := 2*math.Pi*float64(i)/float64(graph.Nodes) - math.Pi/2
+			positions[i] = [2]float64{math.Cos(angle), math.Sin(angle)}
+		}
+	}
+
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, p := range positions {
+		minX, maxX = math.Min(minX, p[0]), math.Max(maxX, p[0])
+		minY, maxY = math.Min(minY, p[1]), math.Max(maxY, p[1])
+	}
+	extent := math.Max(maxX-minX, maxY-minY)
+	scale := 0.0
+	if extent > 0 {
+		scale = (100 - 2*graphMargin) / extent
+	}
+	place := func(v, lo, hi float64) float64 {
+		return math.Round((50+(v-(lo+hi)/2)*scale)*100) / 100
+	}
+
+	view := &graphView{Nodes: make([]graphPoint, len(positions))}
+	for i, p := range positions {
+		view.Nodes[i] = graphPoint{X: place(p[0], minX, maxX), Y: place(p[1], minY, maxY)}
+	}
+	for _, e := range graph.Edges {
+		a, b := view.Nodes[e[0]], view.Nodes[e[1]]
+		view.Edges = append(view.Edges, graphEdge{X1: a.X, Y1: a.Y, X2: b.X, Y2: b.Y})
+	}
+	return view
+}
+
+func newAnalysisView(a grid.Analysis) analysisView {
+	return analysisView{
+		Rank:            a.Rank,
+		Nullity:         a.Nullity(),
+		QuietPatterns:   a.QuietPatterns,
+		SolvablePercent: fmt.Sprintf("%.4g%%", 100*a.SolvableFraction),
+		Degenerate:      a.Degenerate,
+	}
+}
+
+// hintView marks the one cell grid.html should highlight after a Hint request -- or,
+// with Undo, has trivia.html highlight its Undo button instead (see grid.Grid.Hint).
+type hintView struct {
+	Active bool
+	Row    int
+	Col    int
+	Undo   bool
+}
+
+// budgetView is the current game's move limit, for the trivia panel's countdown and
+// game.html's out-of-moves banner. Limit is 0 for a game without one, which hides
+// both.
+type budgetView struct {
+	Limit  int
+	Left   int
+	Failed bool
+}
+
+// timingView is the current game's clock, as the server keeps it: Elapsed since it was
+// dealt (its solve time, once won) and FirstMove, how long its first press took --
+// empty until there's been one. Left is the countdown's time left, empty for a game
+// without one; Ticking has the page follow it live over /clock, until the game's won
+// or TimedOut.
+type timingView struct {
+	Elapsed   string
+	FirstMove string
+	Left      string
+	Ticking   bool
+	TimedOut  bool
+}
+
+// newTimingView describes sess's current game's clock as of now. The caller must hold
+// sess's lock.
+func newTimingView(sess *session.Session, now time.Time) timingView {
+	v := timingView{Elapsed: formatElapsed(sess.Elapsed(now))}
+	if !sess.FirstMoveAt.IsZero() {
+		v.FirstMove = formatElapsed(sess.FirstMoveAt.Sub(sess.GameStarted))
+	}
+	if left, limited := sess.TimeLeft(now); limited {
+		v.Left = formatCountdown(left)
+		v.TimedOut = sess.TimedOut(now)
+		v.Ticking = left > 0 && sess.WonAt.IsZero()
+	}
+	return v
+}
+
+// queueView is a waiting client's place in line for a session slot: its 1-based
+// Position of Total waiting, or Position 0 if it isn't in line (the line was full).
+type queueView struct {
+	Position int
+	Total    int
+}
+
+// String is the place in line as the waiting page shows it, and /wait pushes it.
+func (q queueView) String() string {
+	if q.Position == 0 {
+		return fmt.Sprintf("The line is full (%d waiting); you'll get in once it moves up.", q.Total)
+	}
+	return fmt.Sprintf("You're number %d of %d in line.", q.Position, q.Total)
+}
+
+// formatElapsed formats d as a speedrun time, minutes:seconds to the hundredth.
+func formatElapsed(d time.Duration) string {
+	d = d.Round(10 * time.Millisecond)
+	return fmt.Sprintf("%d:%05.2f", int(d/time.Minute), (d % time.Minute).Seconds())
+}
+
+// formatCountdown formats d as a countdown's minutes:seconds, rounded up, so it only
+// reads 0:00 once time has actually run out.
+func formatCountdown(d time.Duration) string {
+	seconds := int((d + time.Second - 1) / time.Second)
+	return fmt.Sprintf("%d:%02d", seconds/60, seconds%60)
+}
+
+// dailyView is the status header's daily puzzle indicator. Date is empty while
+// waiting for a session, which hides it.
+type dailyView struct {
+	Date    string
+	Playing bool
+	Solved  bool
+	Moves   int
+}
+
+// replayView is the replay page's position in the game: the board after Step of its
+// Total clicks, and whether it's playing on by itself. Prev and Next are the steps
+// either side, since templates can't do arithmetic.
+type replayView struct {
+	Step    int
+	Total   int
+	Prev    int
+	Next    int
+	Playing bool
+	// Last is the click that led to Step's board, described for the page, with its
+	// cell (LastRow, LastCol) outlined on the board; HasLast is false at step 0.
+	Last    string
+	HasLast bool
+	LastRow int
+	LastCol int
+	// Elapsed is how far into the game Last was made.
+	Elapsed string
+	// DelayMS is how long to wait before playing the next step: the time the player
+	// actually took over it, clamped to [replayMinDelay, replayMaxDelay].
+	DelayMS int64
+}
+
+// replayMinDelay and replayMaxDelay bound how fast a replay plays: fast enough not to
+// sit through a long think, slow enough to see a quick double click happen.
+const (
+	replayMinDelay = 250 * time.Millisecond
+	replayMaxDelay = 2 * time.Second
+)
+
+// newReplayView describes step of sess's clicks. The caller must hold sess's lock.
+func newReplayView(sess *session.Session, step int, play bool) *replayView {
+	clicks := sess.Clicks
+	v := &replayView{Step: step, Total: len(clicks), Prev: max(step-1, 0), Next: min(step+1, len(clicks)), Playing: play && step < len(clicks)}
+
+	if step > 0 {
+		click := clicks[step-1]
+		v.HasLast = true
+		v.LastRow, v.LastCol = click.Pos/sess.Game.Cols, click.Pos%sess.Game.Cols
+
+		cell := fmt.Sprintf("row %d, column %d", v.LastRow, v.LastCol)
+		if sess.Graph != nil {
+			cell = fmt.Sprintf("node %d", click.Pos)
+		}
+		v.Last = "Pressed " + cell
+		if click.Undo {
+			v.Last = "Undid " + cell
+		}
+		v.Elapsed = click.At.Sub(sess.GameStarted).Round(time.Second).String()
+	}
+
+	if step < len(clicks) {
+		since := sess.GameStarted
+		if step > 0 {
+			since = clicks[step-1].At
+		}
+		v.DelayMS = min(max(clicks[step].At.Sub(since), replayMinDelay), replayMaxDelay).Milliseconds()
+	}
+
+	return v
 }
 
 // pageResponse is the outcome of the request that produced a pageState -- whether it
@@ -84,12 +395,47 @@ type pageState struct {
 	Version      string
 
 	Config   configView
+	Analysis analysisView
 	Board    [][]int
+	// Target is the board to match, in Board's layout, or nil for the classic uniform
+	// win.
+	Target   [][]int
 	Solution []int
-	Moves    []int
+	Solvable bool
+	// SolveUnknown is set when Solvable is false only because the lit-only search ran
+	// out of budget, so whether the board can be won isn't known.
+	SolveUnknown bool
+	Par          int
+	Moves        []int
+	// Redoable is how many undone moves Redo can replay.
+	Redoable int
 	Win      bool
+	// Budget is the move limit, if the game has one.
+	Budget budgetView
+	// Timing is the game's clock, and its countdown if it has one.
+	Timing timingView
+
+	Hint      hintView
+	HintsUsed int
+	// Rating is how hard the board was as dealt.
+	Rating grid.Rating
+
+	// Replay is set only on the replay page, whose Board and Win are then the replayed
+	// game's at Replay.Step rather than the live one's.
+	Replay *replayView
 
-	Waiting  bool
+	// PuzzleCode names the current board, for /play/:code links; empty if it couldn't
+	// be encoded.
+	PuzzleCode string
+
+	Daily dailyView
+
+	// Graph is the layout of a graph board, or nil for a lattice board.
+	Graph *graphView
+
+	Waiting bool
+	// Queue is the client's place in line, while Waiting.
+	Queue    queueView
 	Expired  bool
 	Response pageResponse
 }
@@ -107,7 +453,7 @@ func responseFromMap(resp map[string]interface{}) pageResponse {
 // WebApp
 
 func NewWebApp(configPath string) *WebAppX {
-	config := utils.ParseJSONConfig(configPath)
+	config := utils.ParseJSONConfig(configPath, grid.CheckConfig)
 	logCloser := utils.SetupLogging(&config)
 
 	server := echo.New()
@@ -122,8 +468,9 @@ func NewWebApp(configPath string) *WebAppX {
 		server.IPExtractor = echo.ExtractIPDirect()
 	}
 	server.Use(middleware.Recover())
-	// The only form fields this app ever reads (dim, neighborhood, cheat, row, col) are
-	// a handful of short values -- 1M is generous headroom over that, while still
+	// The only form fields this app ever reads (rows, cols, lattice, neighborhood,
+	// topology, states, graph, difficulty, target, litonly, cheat, row, col) are a
+	// handful of short values -- 1M is generous headroom over that, while still
 	// bounding how much body an attacker can make the server read/parse per request.
 	server.Use(middleware.BodyLimit("1M"))
 	server.Use(middleware.RateLimiter(middleware.NewRateLimiterMemoryStoreWithConfig(
@@ -139,11 +486,32 @@ func NewWebApp(configPath string) *WebAppX {
 
 	template.NewTemplateRenderer(server, "webui/*.html")
 
+	sessions := session.NewManager(&config)
+	if config.SessionStorePath != "" {
+		store, err := session.OpenFileStore(config.SessionStorePath)
+		if err != nil {
+			log.Fatal("Error when opening the session store: ", err.Error())
+		}
+		restored, err := sessions.Persist(store)
+		if err != nil {
+			log.Fatal("Error when restoring sessions: ", err.Error())
+		}
+		slog.Info(fmt.Sprintf("Restored %d sessions from %s", restored, config.SessionStorePath), utils.FuncAttrKey, utils.Caller())
+	}
+
+	// Echo's Shutdown shuts down server.Server, which runs this as it begins.
+	ctx, stop := context.WithCancel(context.Background())
+	server.Server.RegisterOnShutdown(stop)
+	go sessions.Janitor(ctx)
+
 	webApp := &WebAppX{
 		Config:    &config,
-		Sessions:  session.NewManager(&config),
+		Sessions:  sessions,
 		Server:    server,
 		LogCloser: logCloser,
+		clocks:    make(map[string]chan struct{}),
+		analyzing: make(map[string]struct{}),
+		done:      ctx.Done(),
 	}
 
 	return webApp
@@ -163,17 +531,18 @@ func readSessionCookie(c echo.Context) (id string, ok bool) {
 // when the client has none. The cookie is always (re)written, even when the manager is
 // at capacity, so a waiting client's later /wait SSE connection can claim the same ID
 // once a slot frees up. expired reports whether this id previously had a real session
-// that was since purged for TTL/idle-timeout under capacity pressure (as opposed to id
+// that was since purged for its TTL or idle-timeout (as opposed to id
 // being brand new, or having only ever failed to get a session while waiting for a
 // slot) -- worth telling a genuinely-expired client, since otherwise their board just
-// silently resets with no explanation. err is non-nil only if a new id could not be
-// generated at all (e.g. the OS entropy source failed).
-func (wx *WebAppX) resolveSession(c echo.Context) (sess *session.Session, ok bool, expired bool, err error) {
+// silently resets with no explanation. id is the one the cookie now carries, whether
+// or not it has a session yet. err is non-nil only if a new id could not be generated
+// at all (e.g. the OS entropy source failed).
+func (wx *WebAppX) resolveSession(c echo.Context) (sess *session.Session, id string, ok bool, expired bool, err error) {
 	id, hadCookie := readSessionCookie(c)
 	if !hadCookie {
 		id, err = session.NewID()
 		if err != nil {
-			return nil, false, false, err
+			return nil, "", false, false, err
 		}
 	}
 
@@ -197,21 +566,25 @@ func (wx *WebAppX) resolveSession(c echo.Context) (sess *session.Session, ok boo
 		HttpOnly: true,
 		SameSite: http.SameSiteLaxMode,
 	}
-	// c.Scheme() trusts the X-Forwarded-Proto header, which is only safe to rely on
-	// behind a real reverse proxy (Config.TrustProxyHeaders) -- otherwise a direct
-	// client could set that header itself and force Secure false on a real TLS
-	// connection. Without a trusted proxy, fall back to checking whether TLS is
-	// actually terminated in this process, which can't be spoofed by a header.
-	secure := c.Request().TLS != nil
-	if wx.Config.TrustProxyHeaders {
-		secure = c.Scheme() == "https"
-	}
-	if secure {
+	if wx.secureRequest(c) {
 		cookie.Secure = true
 	}
 	c.SetCookie(cookie)
 
-	return sess, ok, expired, nil
+	return sess, id, ok, expired, nil
+}
+
+// secureRequest reports whether c came in over TLS, for marking cookies Secure.
+// c.Scheme() trusts the X-Forwarded-Proto header, which is only safe to rely on behind
+// a real reverse proxy (Config.TrustProxyHeaders) -- otherwise a direct client could
+// set that header itself and force Secure false on a real TLS connection. Without a
+// trusted proxy, fall back to checking whether TLS is actually terminated in this
+// process, which can't be spoofed by a header.
+func (wx *WebAppX) secureRequest(c echo.Context) bool {
+	if wx.Config.TrustProxyHeaders {
+		return c.Scheme() == "https"
+	}
+	return c.Request().TLS != nil
 }
 
 // baseState holds the fields every rendered page needs regardless of whether a client
@@ -220,7 +593,7 @@ func (wx *WebAppX) resolveSession(c echo.Context) (sess *session.Session, ok boo
 func (wx *WebAppX) baseState() pageState {
 	return pageState{
 		SessionCount: wx.Sessions.Count(),
-		MaxSessions:  wx.Config.MaxSessions,
+		MaxSessions:  wx.Sessions.MaxSessions(),
 		Version:      wx.Version,
 	}
 }
@@ -228,15 +601,58 @@ func (wx *WebAppX) baseState() pageState {
 func (wx *WebAppX) gameState(sess *session.Session, expired bool) pageState {
 	state := wx.baseState()
 	state.Config = configView{
-		Dim:                     sess.Dim,
-		Cheat:                   sess.Cheat,
-		ToggleSequence:          sess.ToggleSequence,
-		AvailableToggleSequence: wx.Config.AvailableToggleSequence,
+		Rows:              sess.Rows,
+		Cols:              sess.Cols,
+		Lattice:           sess.Lattice,
+		Lattices:          grid.Lattices,
+		Topology:          sess.Topology,
+		Topologies:        grid.Topologies,
+		States:            sess.States,
+		AvailableStates:   grid.SupportedStates,
+		Graphs:            graphNames(wx.Config.Graphs),
+		Difficulty:        string(sess.Difficulty),
+		Difficulties:      difficultyNames(),
+		Target:            string(sess.Target),
+		Targets:           targetNames(),
+		LitOnly:           sess.LitOnly,
+		Cheat:             sess.Cheat,
+		Budget:            sess.Budget.String(),
+		TimeLimit:         int(sess.TimeLimit / time.Second),
+		ToggleSequence:    sess.ToggleSequence,
+		AvailablePatterns: wx.Config.Patterns,
+	}
+	if sess.Graph != nil {
+		state.Config.Graph = sess.Graph.Name
+		state.Graph = newGraphView(sess.Graph)
 	}
+	state.Analysis = newAnalysisView(sess.Game.Analysis())
 	state.Board = sess.Game.GetGrid()
-	state.Solution = sess.Game.GetPossibleSolution()
+	state.Target = sess.Game.Target()
+	// Solved from the live board, not GetPossibleSolution's initGame-time scramble, so
+	// the cheat panel stays correct after the player moves -- and optimally, so its
+	// length doubles as an honest par score. The game keeps the answer until the next
+	// move, so a render that changes nothing doesn't search again.
+	state.Solution, state.Solvable = sess.Game.OptimalSolution()
+	_, known := sess.Game.Solvable()
+	state.SolveUnknown = !known
+	state.Par = len(state.Solution)
 	state.Moves = sess.Game.GetPreviousMoves()
+	state.Redoable = sess.Game.RedoDepth()
 	state.Win = sess.Game.CheckWin()
+	if left, limited := sess.MovesLeft(); limited {
+		state.Budget = budgetView{Limit: sess.MoveLimit, Left: left, Failed: left == 0 && !state.Win}
+	}
+	state.Timing = newTimingView(sess, time.Now())
+	state.HintsUsed = sess.HintsUsed
+	state.Rating = sess.Game.Rating()
+	state.PuzzleCode = wx.puzzleCode(sess)
+	today := session.DailyDate(time.Now())
+	state.Daily = dailyView{
+		Date:    today,
+		Playing: sess.Daily == today,
+		Solved:  sess.DailySolved.Date == today,
+		Moves:   sess.DailySolved.Moves,
+	}
 	state.Response = pageResponse{Status: "SUCCESS", Error: ""}
 	state.Waiting = false
 	state.Expired = expired
@@ -244,9 +660,39 @@ func (wx *WebAppX) gameState(sess *session.Session, expired bool) pageState {
 	return state
 }
 
-func (wx *WebAppX) waitState() pageState {
+// puzzleCode encodes sess's current board: its configuration, as the panel shows it,
+// and the seed its game was dealt from. The caller must hold sess's lock.
+func (wx *WebAppX) puzzleCode(sess *session.Session) string {
+	p := puzzle.Puzzle{
+		Seed:       sess.Game.Seed(),
+		Rows:       sess.Rows,
+		Cols:       sess.Cols,
+		Lattice:    sess.Lattice,
+		Topology:   sess.Topology,
+		States:     sess.States,
+		Difficulty: sess.Difficulty,
+		Target:     sess.Target,
+		LitOnly:    sess.LitOnly,
+	}
+	for _, pattern := range sess.Game.Spec().Neighborhood {
+		p.Neighborhood = append(p.Neighborhood, pattern.Name)
+	}
+	if sess.Graph != nil {
+		p.Graph = sess.Graph.Name
+	}
+
+	code, err := puzzle.Encode(p, wx.Config.Patterns, wx.Config.Graphs)
+	if err != nil {
+		slog.Error(fmt.Sprintf("puzzleCode failed: %v", err), utils.FuncAttrKey, utils.Caller())
+		return ""
+	}
+	return code
+}
+
+func (wx *WebAppX) waitState(id string) pageState {
 	state := wx.baseState()
 	state.Waiting = true
+	state.Queue.Position, state.Queue.Total = wx.Sessions.QueuePosition(id)
 
 	return state
 }
@@ -257,14 +703,14 @@ func (wx *WebAppX) waitState() pageState {
 // just `return err` immediately. Only when handled is false does the caller have a real
 // sess to work with.
 func (wx *WebAppX) withSession(c echo.Context) (sess *session.Session, expired bool, handled bool, err error) {
-	sess, ok, expired, resolveErr := wx.resolveSession(c)
+	sess, id, ok, expired, resolveErr := wx.resolveSession(c)
 	if resolveErr != nil {
 		slog.Error(fmt.Sprintf("resolveSession failed: %v", resolveErr), utils.FuncAttrKey, utils.Caller())
 		return nil, false, true, c.NoContent(http.StatusInternalServerError)
 	}
 	if !ok {
 		slog.Info("Client waiting for a session slot", utils.FuncAttrKey, utils.Caller())
-		return nil, false, true, c.Render(http.StatusOK, "index", wx.waitState())
+		return nil, false, true, c.Render(http.StatusOK, "index", wx.waitState(id))
 	}
 	return sess, expired, false, nil
 }
@@ -313,28 +759,206 @@ func (wx *WebAppX) Reset(c echo.Context) error {
 		slog.Debug(fmt.Sprintf("Data received: %v", jsonMap), utils.FuncAttrKey, utils.Caller())
 	}
 
-	dim, resp := utils.ParseDim(jsonMap, resp)
+	spec, resp := wx.parseSpec(jsonMap, resp)
 	if resp["Status"] == "ERROR" {
 		return wx.renderSession(c, sess, expired, resp)
 	}
 
-	neighborhood, resp := utils.ParseNeighborhood(jsonMap, resp, wx.Config.AvailableToggleSequence)
+	cheat, resp := utils.ParseCheat(jsonMap, resp)
 	if resp["Status"] == "ERROR" {
 		return wx.renderSession(c, sess, expired, resp)
 	}
 
-	cheat, resp := utils.ParseCheat(jsonMap, resp)
+	budget, resp := utils.ParseBudget(jsonMap, resp)
 	if resp["Status"] == "ERROR" {
 		return wx.renderSession(c, sess, expired, resp)
 	}
 
-	sess.Lock()
+	timeLimit, resp := utils.ParseTimeLimit(jsonMap, resp)
+	if resp["Status"] == "ERROR" {
+		return wx.renderSession(c, sess, expired, resp)
+	}
+
+	return wx.startGame(c, sess, expired, spec, grid.NewGrid, resp, func(sess *session.Session) {
+		sess.Cheat = cheat
+		sess.Budget = budget
+		sess.TimeLimit = timeLimit
+	})
+}
+
+// Play starts the session on the exact board a puzzle code names (see package puzzle),
+// for /play/:code links. The code's configuration goes through the same parsing and
+// checks as Reset's form; the session's Cheat, Budget and TimeLimit settings are left as
+// they were.
+func (wx *WebAppX) Play(c echo.Context) error {
+	sess, expired, handled, err := wx.withSession(c)
+	if handled {
+		return err
+	}
+
+	resp := utils.OKResp()
+
+	p, err := puzzle.Decode(c.Param("code"), wx.Config.Patterns, wx.Config.Graphs)
+	if err != nil {
+		errMsg := "Params error: " + err.Error()
+		resp["Status"] = "ERROR"
+		resp["Error"] = errMsg
 
-	sess.Dim = dim
-	sess.ToggleSequence = utils.BuildToggleSequenceFromRequest(neighborhood, wx.Config.AvailableToggleSequence)
-	sess.Cheat = cheat
+		slog.Warn(errMsg, utils.FuncAttrKey, utils.Caller())
+
+		return wx.renderSession(c, sess, expired, resp)
+	}
 
-	sess.Game = grid.NewGrid(dim, neighborhood)
+	if debugEnabled() {
+		slog.Debug(fmt.Sprintf("Puzzle decoded: %+v", p), utils.FuncAttrKey, utils.Caller())
+	}
+
+	spec, resp := wx.parseSpec(utils.ProcessValues(p.Form()), resp)
+	if resp["Status"] == "ERROR" {
+		return wx.renderSession(c, sess, expired, resp)
+	}
+
+	return wx.startGame(c, sess, expired, spec, func(spec grid.Spec) *grid.Grid {
+		return grid.NewGridFromSeed(spec, p.Seed)
+	}, resp, nil)
+}
+
+// Daily starts the session on today's daily puzzle: the config's default board, dealt
+// from a seed derived from the UTC date (see grid.DailySeed), so it's the same board
+// for every client until midnight UTC. Solving it is recorded on the session, and
+// shown in the status header.
+func (wx *WebAppX) Daily(c echo.Context) error {
+	sess, expired, handled, err := wx.withSession(c)
+	if handled {
+		return err
+	}
+
+	now := time.Now()
+	today := session.DailyDate(now)
+
+	if debugEnabled() {
+		slog.Debug(fmt.Sprintf("Daily puzzle for %s", today), utils.FuncAttrKey, utils.Caller())
+	}
+
+	return wx.startGame(c, sess, expired, grid.DefaultSpec(wx.Config), func(spec grid.Spec) *grid.Grid {
+		return grid.NewGridFromSeed(spec, grid.DailySeed(now))
+	}, utils.OKResp(), func(sess *session.Session) {
+		sess.Daily = today
+	})
+}
+
+// parseSpec parses the board configuration fields Reset, Play and Analyze share --
+// rows, cols, lattice, neighborhood, topology, states, graph, difficulty, target and
+// litonly -- into a Spec. Its
+// Rows and Cols are the size fields even on a graph board, where the board itself
+// ignores them, so the configuration panel keeps showing what was submitted.
+func (wx *WebAppX) parseSpec(jsonMap map[string]interface{}, resp map[string]interface{}) (grid.Spec, map[string]interface{}) {
+	rows, cols, resp := utils.ParseSize(jsonMap, resp)
+	if resp["Status"] == "ERROR" {
+		return grid.Spec{}, resp
+	}
+
+	lattice, resp := utils.ParseLattice(jsonMap, resp, latticeNames())
+	if resp["Status"] == "ERROR" {
+		return grid.Spec{}, resp
+	}
+
+	neighborhood, resp := utils.ParseNeighborhood(jsonMap, resp, wx.Config.Patterns)
+	if resp["Status"] == "ERROR" {
+		return grid.Spec{}, resp
+	}
+
+	topology, resp := utils.ParseTopology(jsonMap, resp, topologyNames())
+	if resp["Status"] == "ERROR" {
+		return grid.Spec{}, resp
+	}
+
+	states, resp := utils.ParseStates(jsonMap, resp, grid.SupportedStates)
+	if resp["Status"] == "ERROR" {
+		return grid.Spec{}, resp
+	}
+
+	graph, resp := utils.ParseGraph(jsonMap, resp, wx.Config.Graphs)
+	if resp["Status"] == "ERROR" {
+		return grid.Spec{}, resp
+	}
+
+	difficulty, resp := utils.ParseDifficulty(jsonMap, resp, difficultyNames())
+	if resp["Status"] == "ERROR" {
+		return grid.Spec{}, resp
+	}
+
+	target, resp := utils.ParseTarget(jsonMap, resp, targetNames())
+	if resp["Status"] == "ERROR" {
+		return grid.Spec{}, resp
+	}
+
+	litOnly, resp := utils.ParseLitOnly(jsonMap, resp)
+	if resp["Status"] == "ERROR" {
+		return grid.Spec{}, resp
+	}
+
+	return grid.Spec{
+		Rows: rows, Cols: cols, Lattice: grid.Lattice(lattice), Neighborhood: neighborhood, Topology: grid.Topology(topology),
+		States: states, Graph: graph, Difficulty: grid.Difficulty(difficulty), Target: grid.Target(target), LitOnly: litOnly,
+	}, resp
+}
+
+// startGame checks spec is one the server will deal, then replaces sess's game with
+// newGame(spec) and renders it. setup, if not nil, is run on the session, still locked,
+// once the new game is in place, for anything else the caller needs to change.
+func (wx *WebAppX) startGame(c echo.Context, sess *session.Session, expired bool, spec grid.Spec,
+	newGame func(grid.Spec) *grid.Grid, resp map[string]interface{}, setup func(*session.Session),
+) error {
+	if err := spec.Validate(); err != nil {
+		errMsg := "Params error: " + err.Error()
+		resp["Status"] = "ERROR"
+		resp["Error"] = errMsg
+
+		slog.Warn(errMsg, utils.FuncAttrKey, utils.Caller())
+
+		return wx.renderSession(c, sess, expired, resp)
+	}
+
+	// Rejected rather than dealt: NewGrid would have to fake an unsolved board with a
+	// raw flip no real move can undo (see grid.Analysis.Degenerate).
+	if grid.Analyze(spec).Degenerate {
+		const errMsg = "Params error: this grid size and pattern can never produce an unsolved board"
+		resp["Status"] = "ERROR"
+		resp["Error"] = errMsg
+
+		slog.Warn(errMsg, utils.FuncAttrKey, utils.Caller())
+
+		return wx.renderSession(c, sess, expired, resp)
+	}
+
+	sess.Lock()
+
+	sess.Rows = spec.Rows
+	sess.Cols = spec.Cols
+	sess.Lattice = spec.Lattice
+	sess.Topology = spec.Topology
+	sess.States = spec.States
+	sess.Graph = spec.Graph
+	sess.Difficulty = spec.Difficulty
+	sess.Target = spec.Target
+	sess.LitOnly = spec.LitOnly
+	sess.ToggleSequence = utils.BuildToggleSequenceFromRequest(spec.Neighborhood, wx.Config.Patterns)
+
+	sess.Game = newGame(spec)
+	sess.GameStarted = time.Now()
+	sess.FirstMoveAt, sess.WonAt = time.Time{}, time.Time{}
+	sess.Clicks = nil
+	sess.HintsUsed = 0
+	sess.Presses = 0
+	sess.Daily = ""
+	if setup != nil {
+		setup(sess)
+	}
+	// After setup, which may have just changed Budget: a par-based limit is only known
+	// now that the board is dealt.
+	sess.MoveLimit = sess.Budget.Limit(sess.Game.Rating().Par)
+	wx.Sessions.Save(sess)
 	if debugEnabled() {
 		slog.Debug(fmt.Sprintf("Possible solution: %v", sess.Game.GetPossibleSolution()), utils.FuncAttrKey, utils.Caller())
 		sess.Game.PrettyPrintGrid()
@@ -347,6 +971,75 @@ func (wx *WebAppX) Reset(c echo.Context) error {
 	return c.Render(http.StatusOK, "index", state)
 }
 
+// Analyze renders the configuration panel's analysis fragment for the board size and
+// patterns in the request's query, so the panel can preview a configuration before
+// it's applied via Reset. It reads no session state, but it still needs a claimed
+// session, as the game endpoints do: a new configuration costs an elimination over its
+// toggle matrix, which a client without one shouldn't be able to demand, and a session
+// may only have one preview running at a time (see WebAppX.analyzing).
+func (wx *WebAppX) Analyze(c echo.Context) error {
+	sess, _, ok, _, err := wx.resolveSession(c)
+	if err != nil {
+		slog.Error(fmt.Sprintf("resolveSession failed: %v", err), utils.FuncAttrKey, utils.Caller())
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	state := pageState{}
+
+	if !ok {
+		state.Analysis.Error = "Not allowed: waiting for a session slot"
+		return c.Render(http.StatusOK, "analysis", state)
+	}
+
+	if !wx.claimAnalysis(sess.ID) {
+		const errMsg = "Not allowed: an analysis is already running for this session"
+
+		slog.Info(errMsg, utils.FuncAttrKey, utils.Caller())
+
+		state.Analysis.Error = errMsg
+		return c.Render(http.StatusTooManyRequests, "analysis", state)
+	}
+	defer wx.releaseAnalysis(sess.ID)
+
+	jsonMap := utils.ProcessRequestQuery(c)
+	resp := utils.OKResp()
+
+	spec, resp := wx.parseSpec(jsonMap, resp)
+	if resp["Status"] == "ERROR" {
+		state.Analysis.Error = responseFromMap(resp).Error
+		return c.Render(http.StatusOK, "analysis", state)
+	}
+
+	if err := spec.Validate(); err != nil {
+		state.Analysis.Error = "Params error: " + err.Error()
+		return c.Render(http.StatusOK, "analysis", state)
+	}
+	state.Analysis = newAnalysisView(grid.Analyze(spec))
+
+	return c.Render(http.StatusOK, "analysis", state)
+}
+
+// claimAnalysis registers an Analyze() preview for session id, unless it has one in
+// flight already.
+func (wx *WebAppX) claimAnalysis(id string) bool {
+	wx.analyzingMu.Lock()
+	defer wx.analyzingMu.Unlock()
+
+	if _, busy := wx.analyzing[id]; busy {
+		return false
+	}
+	wx.analyzing[id] = struct{}{}
+	return true
+}
+
+// releaseAnalysis unregisters the preview claimAnalysis registered for session id.
+func (wx *WebAppX) releaseAnalysis(id string) {
+	wx.analyzingMu.Lock()
+	defer wx.analyzingMu.Unlock()
+
+	delete(wx.analyzing, id)
+}
+
 func (wx *WebAppX) RevertMove(c echo.Context) error {
 	sess, expired, handled, err := wx.withSession(c)
 	if handled {
@@ -355,6 +1048,12 @@ func (wx *WebAppX) RevertMove(c echo.Context) error {
 
 	sess.Lock()
 
+	if state, timedOut := wx.timedOutState(sess, expired, time.Now()); timedOut {
+		sess.Unlock()
+
+		return c.Render(http.StatusOK, "index", state)
+	}
+
 	pos, ok := sess.Game.PopLastMove()
 	if !ok {
 		const errMsg = "Not allowed: Nothing to revert to"
@@ -368,7 +1067,9 @@ func (wx *WebAppX) RevertMove(c echo.Context) error {
 		return c.Render(http.StatusOK, "index", state)
 	}
 
-	sess.Game.Switch(pos)
+	sess.Game.Unswitch(pos)
+	sess.RecordClick(pos, true)
+	wx.Sessions.Save(sess)
 
 	if debugEnabled() {
 		slog.Debug(fmt.Sprintf("Move History: %v", sess.Game.GetPreviousMoves()), utils.FuncAttrKey, utils.Caller())
@@ -384,6 +1085,153 @@ func (wx *WebAppX) RevertMove(c echo.Context) error {
 	return c.Render(http.StatusOK, "index", state)
 }
 
+// timedOutState reports whether sess's game has run out of time at now, and if so,
+// the state to render refusing a move for it. Checked by every handler that changes
+// the board, not just by Clock: the countdown is the server's, whatever a page left
+// open without its stream still shows. The caller must hold sess's lock.
+func (wx *WebAppX) timedOutState(sess *session.Session, expired bool, now time.Time) (pageState, bool) {
+	if !sess.TimedOut(now) {
+		return pageState{}, false
+	}
+
+	errMsg := fmt.Sprintf("Params error: out of time -- this game's %s limit has run out", sess.TimeLimit)
+
+	slog.Warn(errMsg, utils.FuncAttrKey, utils.Caller())
+
+	state := wx.gameState(sess, expired)
+	state.Response = pageResponse{Status: "ERROR", Error: errMsg}
+	return state, true
+}
+
+// Redo replays the last move RevertMove undid. Like RevertMove it doesn't count toward
+// Session.Presses: the daily puzzle's count is of clicks on the board.
+func (wx *WebAppX) Redo(c echo.Context) error {
+	sess, expired, handled, err := wx.withSession(c)
+	if handled {
+		return err
+	}
+
+	sess.Lock()
+
+	if state, timedOut := wx.timedOutState(sess, expired, time.Now()); timedOut {
+		sess.Unlock()
+
+		return c.Render(http.StatusOK, "index", state)
+	}
+
+	pos, ok := sess.Game.RedoMove()
+	if !ok {
+		const errMsg = "Not allowed: Nothing to redo"
+
+		slog.Info(errMsg, utils.FuncAttrKey, utils.Caller())
+
+		state := wx.gameState(sess, expired)
+		state.Response = pageResponse{Status: "ERROR", Error: errMsg}
+		sess.Unlock()
+
+		return c.Render(http.StatusOK, "index", state)
+	}
+
+	sess.Game.Switch(pos)
+	sess.RecordClick(pos, false)
+	wx.Sessions.Save(sess)
+
+	if debugEnabled() {
+		slog.Debug(fmt.Sprintf("Move History: %v", sess.Game.GetPreviousMoves()), utils.FuncAttrKey, utils.Caller())
+		sess.Game.PrettyPrintGrid()
+	}
+
+	state := wx.gameState(sess, expired)
+	sess.Unlock()
+
+	return c.Render(http.StatusOK, "index", state)
+}
+
+// Replay shows the current game as it stood after its first 'step' clicks, replayed on
+// the board as it was dealt -- with controls to step through it, or with 'play', to
+// play on through the rest at the pace it was played, by htmx polling the next step.
+func (wx *WebAppX) Replay(c echo.Context) error {
+	sess, expired, handled, err := wx.withSession(c)
+	if handled {
+		return err
+	}
+
+	jsonMap := utils.ProcessRequestQuery(c)
+	resp := utils.OKResp()
+
+	step, play, resp := utils.ParseReplay(jsonMap, resp)
+	if resp["Status"] == "ERROR" {
+		return wx.renderSession(c, sess, expired, resp)
+	}
+
+	sess.Lock()
+
+	// Past the end is clamped rather than rejected: a replay page left open goes stale
+	// once a new game starts, and showing that game's end beats an error.
+	step = min(step, len(sess.Clicks))
+
+	replay := sess.Game.Restart()
+	for _, click := range sess.Clicks[:step] {
+		if click.Undo {
+			replay.Unswitch(click.Pos)
+		} else {
+			replay.Switch(click.Pos)
+		}
+	}
+
+	state := wx.gameState(sess, expired)
+	state.Board = replay.GetGrid()
+	state.Win = replay.CheckWin()
+	state.Replay = newReplayView(sess, step, play)
+	sess.Unlock()
+
+	return c.Render(http.StatusOK, "index", state)
+}
+
+// Hint highlights a single cell worth pressing next, without revealing the rest of the
+// solution the way the Cheat flag does, and counts it against the current game.
+func (wx *WebAppX) Hint(c echo.Context) error {
+	sess, expired, handled, err := wx.withSession(c)
+	if handled {
+		return err
+	}
+
+	sess.Lock()
+
+	pos, undo, ok := sess.Game.Hint()
+	if !ok {
+		errMsg := "Not allowed: No winning move from this board"
+		if sess.Game.CheckWin() {
+			errMsg = "Not allowed: The board is already solved"
+		}
+
+		slog.Info(errMsg, utils.FuncAttrKey, utils.Caller())
+
+		state := wx.gameState(sess, expired)
+		state.Response = pageResponse{Status: "ERROR", Error: errMsg}
+		sess.Unlock()
+
+		return c.Render(http.StatusOK, "index", state)
+	}
+
+	sess.HintsUsed++
+	wx.Sessions.Save(sess)
+
+	if debugEnabled() {
+		slog.Debug(fmt.Sprintf("Hint: %d, undo %t (hints used: %d)", pos, undo, sess.HintsUsed), utils.FuncAttrKey, utils.Caller())
+	}
+
+	state := wx.gameState(sess, expired)
+	if undo {
+		state.Hint = hintView{Undo: true}
+	} else {
+		state.Hint = hintView{Active: true, Row: pos / sess.Game.Cols, Col: pos % sess.Game.Cols}
+	}
+	sess.Unlock()
+
+	return c.Render(http.StatusOK, "index", state)
+}
+
 func (wx *WebAppX) Switch(c echo.Context) error {
 	sess, expired, handled, err := wx.withSession(c)
 	if handled {
@@ -406,7 +1254,7 @@ func (wx *WebAppX) Switch(c echo.Context) error {
 
 	// Bounds-checked here (rather than in ParseRowCol) since the valid range depends
 	// on this session's current board size, which isn't known/lockable until now.
-	if row < 0 || row >= sess.Game.Dim || col < 0 || col >= sess.Game.Dim {
+	if row < 0 || row >= sess.Game.Rows || col < 0 || col >= sess.Game.Cols {
 		const errMsg = "Params error: row/col out of bounds for the current board"
 		resp["Status"] = "ERROR"
 		resp["Error"] = errMsg
@@ -420,10 +1268,55 @@ func (wx *WebAppX) Switch(c echo.Context) error {
 		return c.Render(http.StatusOK, "index", state)
 	}
 
-	pos := (sess.Game.Dim * row) + col
+	pos := (sess.Game.Cols * row) + col
 
-	sess.Game.Switch(pos)
+	// Undo doesn't give presses back, so once the budget's spent the game is lost --
+	// short of Redo, which only replays presses already paid for.
+	if left, limited := sess.MovesLeft(); limited && left == 0 {
+		errMsg := fmt.Sprintf("Params error: out of moves -- all %d in this game's budget are used up", sess.MoveLimit)
+		resp["Status"] = "ERROR"
+		resp["Error"] = errMsg
+
+		slog.Warn(errMsg, utils.FuncAttrKey, utils.Caller())
+
+		state := wx.gameState(sess, expired)
+		state.Response = responseFromMap(resp)
+		sess.Unlock()
+
+		return c.Render(http.StatusOK, "index", state)
+	}
+
+	now := time.Now()
+	if state, timedOut := wx.timedOutState(sess, expired, now); timedOut {
+		sess.Unlock()
+
+		return c.Render(http.StatusOK, "index", state)
+	}
+
+	// The only press Switch refuses once the bounds check above has passed: an unlit
+	// cell on a lit-only board.
+	if !sess.Game.Switch(pos) {
+		const errMsg = "Params error: only lit cells can be switched on a lit-only board"
+		resp["Status"] = "ERROR"
+		resp["Error"] = errMsg
+
+		slog.Warn(errMsg, utils.FuncAttrKey, utils.Caller())
+
+		state := wx.gameState(sess, expired)
+		state.Response = responseFromMap(resp)
+		sess.Unlock()
+
+		return c.Render(http.StatusOK, "index", state)
+	}
 	sess.Game.RecordMove(pos)
+	sess.RecordClick(pos, false)
+	sess.Presses++
+	won := sess.Game.CheckWin()
+	sess.RecordPressTime(now, won)
+	if won {
+		sess.RecordDailyWin()
+	}
+	wx.Sessions.Save(sess)
 
 	if debugEnabled() {
 		slog.Debug(fmt.Sprintf("Move History: %v", sess.Game.GetPreviousMoves()), utils.FuncAttrKey, utils.Caller())
@@ -439,9 +1332,14 @@ func (wx *WebAppX) Switch(c echo.Context) error {
 	return c.Render(http.StatusOK, "index", state)
 }
 
-// Wait serves an SSE stream for a client that couldn't get a session slot. It rechecks
-// at SessionWaitCheckIntervalSeconds and, once a slot frees up for this client's ID,
-// pushes a single "ready" event containing the rendered game fragment, then closes.
+// Wait serves an SSE stream for a client that couldn't get a session slot. It pushes a
+// "position" event with the client's place in line (see
+// session.Manager.QueuePosition) whenever it changes, and once a slot frees up for
+// this client's ID -- it reached the head of the line -- a single "ready" event
+// containing the rendered game fragment, then closes. It rechecks only when the
+// session manager signals a change (session.Manager.Changed), not on a timer, so the
+// client is let in the instant there's room. The open stream holds the client's place
+// in line however long it waits.
 func (wx *WebAppX) Wait(c echo.Context) error {
 	id, ok := readSessionCookie(c)
 	if !ok {
@@ -455,15 +1353,131 @@ func (wx *WebAppX) Wait(c echo.Context) error {
 	}
 	defer wx.waitingConns.Add(-1)
 
+	unwatch := wx.Sessions.Watch(id)
+	defer unwatch()
+
 	resp := c.Response()
 	resp.Header().Set(echo.HeaderContentType, "text/event-stream")
 	resp.Header().Set("Cache-Control", "no-cache")
 	resp.Header().Set("Connection", "keep-alive")
 	resp.WriteHeader(http.StatusOK)
 
-	interval := time.Duration(wx.Config.SessionWaitCheckIntervalSeconds) * time.Second
-	ticker := time.NewTicker(interval)
+	ctx := c.Request().Context()
+	var shown queueView
+
+	for {
+		changed := wx.Sessions.Changed()
+
+		sess, ok, _ := wx.Sessions.Claim(id)
+		if ok {
+			sess.Lock()
+			state := wx.gameState(sess, false)
+			sess.Unlock()
+
+			var buf bytes.Buffer
+			if err := c.Echo().Renderer.Render(&buf, "game", state, c); err != nil {
+				return err
+			}
+
+			if err := writeSSEEvent(resp, "ready", buf.String()); err != nil {
+				slog.Warn(fmt.Sprintf("Wait -- failed writing SSE event (client likely disconnected): %v", err), utils.FuncAttrKey, utils.Caller())
+				return nil
+			}
+			resp.Flush()
+
+			return nil
+		}
+
+		var queue queueView
+		queue.Position, queue.Total = wx.Sessions.QueuePosition(id)
+		if queue != shown {
+			if err := writeSSEEvent(resp, "position", queue.String()); err != nil {
+				slog.Warn(fmt.Sprintf("Wait -- failed writing SSE event (client likely disconnected): %v", err), utils.FuncAttrKey, utils.Caller())
+				return nil
+			}
+			resp.Flush()
+			shown = queue
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-wx.done:
+			return nil
+		case <-changed:
+		}
+	}
+}
+
+// claimClock registers a Clock() stream as id's one open stream, ending whichever was
+// open before; the returned channel is closed if another replaces this one in turn.
+func (wx *WebAppX) claimClock(id string) <-chan struct{} {
+	wx.clocksMu.Lock()
+	defer wx.clocksMu.Unlock()
+
+	if old, ok := wx.clocks[id]; ok {
+		close(old)
+		slog.Debug("Clock -- replacing the session's open stream", utils.FuncAttrKey, utils.Caller())
+	}
+	replaced := make(chan struct{})
+	wx.clocks[id] = replaced
+	return replaced
+}
+
+// releaseClock unregisters the stream claimClock returned replaced for, unless it has
+// been replaced already.
+func (wx *WebAppX) releaseClock(id string, replaced <-chan struct{}) {
+	wx.clocksMu.Lock()
+	defer wx.clocksMu.Unlock()
+
+	if wx.clocks[id] == replaced {
+		delete(wx.clocks, id)
+	}
+}
+
+// Clock serves an SSE stream following the session's current game's countdown: a
+// "tick" event every second with the time left, then, once it runs out, a single
+// "timeout" event containing the rendered game fragment -- now lost -- and it closes.
+// It closes early, without either, if the game is won or replaced in the meantime,
+// since the page that opened it will have been re-rendered anyway, or if the server
+// shuts down; and with 204 No
+// Content, which tells the browser not to reconnect, if there's no countdown running.
+func (wx *WebAppX) Clock(c echo.Context) error {
+	id, ok := readSessionCookie(c)
+	if !ok {
+		return c.NoContent(http.StatusBadRequest)
+	}
+
+	sess, ok := wx.Sessions.Lookup(id)
+	if !ok {
+		return c.NoContent(http.StatusNoContent)
+	}
+
+	sess.Lock()
+	started := sess.GameStarted
+	left, limited := sess.TimeLeft(time.Now())
+	running := limited && left > 0 && sess.WonAt.IsZero()
+	sess.Unlock()
+	if !running {
+		return c.NoContent(http.StatusNoContent)
+	}
+
+	replaced := wx.claimClock(id)
+	defer wx.releaseClock(id, replaced)
+
+	resp := c.Response()
+	resp.Header().Set(echo.HeaderContentType, "text/event-stream")
+	resp.Header().Set("Cache-Control", "no-cache")
+	resp.Header().Set("Connection", "keep-alive")
+	resp.WriteHeader(http.StatusOK)
+	// Sent now rather than with the first tick, so the client knows at once it's
+	// connected.
+	resp.Flush()
+
+	ticker := time.NewTicker(time.Second)
 	defer ticker.Stop()
+	timer := time.NewTimer(left)
+	defer timer.Stop()
 
 	ctx := c.Request().Context()
 
@@ -472,13 +1486,39 @@ func (wx *WebAppX) Wait(c echo.Context) error {
 		case <-ctx.Done():
 			return nil
 
+		case <-wx.done:
+			return nil
+
+		case <-replaced:
+			return nil
+
 		case <-ticker.C:
-			sess, ok, _ := wx.Sessions.Claim(id)
-			if !ok {
-				continue
+			sess.Lock()
+			current := sess.GameStarted.Equal(started) && sess.WonAt.IsZero()
+			left, _ = sess.TimeLeft(time.Now())
+			sess.Unlock()
+			if !current {
+				return nil
 			}
 
+			if err := writeSSEEvent(resp, "tick", formatCountdown(left)); err != nil {
+				slog.Warn(fmt.Sprintf("Clock -- failed writing SSE event (client likely disconnected): %v", err), utils.FuncAttrKey, utils.Caller())
+				return nil
+			}
+			resp.Flush()
+
+		case <-timer.C:
 			sess.Lock()
+			if !sess.GameStarted.Equal(started) || !sess.WonAt.IsZero() {
+				sess.Unlock()
+				return nil
+			}
+			// The timer and the session's clock needn't agree to the nanosecond.
+			if left, _ = sess.TimeLeft(time.Now()); left > 0 {
+				sess.Unlock()
+				timer.Reset(left)
+				continue
+			}
 			state := wx.gameState(sess, false)
 			sess.Unlock()
 
@@ -487,8 +1527,8 @@ func (wx *WebAppX) Wait(c echo.Context) error {
 				return err
 			}
 
-			if err := writeSSEEvent(resp, "ready", buf.String()); err != nil {
-				slog.Warn(fmt.Sprintf("Wait -- failed writing SSE event (client likely disconnected): %v", err), utils.FuncAttrKey, utils.Caller())
+			if err := writeSSEEvent(resp, "timeout", buf.String()); err != nil {
+				slog.Warn(fmt.Sprintf("Clock -- failed writing SSE event (client likely disconnected): %v", err), utils.FuncAttrKey, utils.Caller())
 				return nil
 			}
 			resp.Flush()